@@ -162,6 +162,9 @@ type (
 		// AdvancedVisibilityStore is the name of the datastore to be used for visibility records
 		// Must provide one of VisibilityStore and AdvancedVisibilityStore
 		AdvancedVisibilityStore string `yaml:"advancedVisibilityStore"`
+		// SecondaryStore is the name of the datastore that execution, history, task and shard writes are mirrored to
+		// while migrating to a new datastore, optional
+		SecondaryStore string `yaml:"secondaryStore"`
 		// HistoryMaxConns is the desired number of conns to history store. Value specified
 		// here overrides the MaxConns config specified as part of datastore
 		HistoryMaxConns int `yaml:"historyMaxConns"`
@@ -179,6 +182,23 @@ type (
 		// TODO: move dynamic config out of static config
 		// ErrorInjectionRate is the the rate for injecting random error
 		ErrorInjectionRate dynamicconfig.FloatPropertyFn `yaml:"-" json:"-"`
+		// TODO: move dynamic config out of static config
+		// DualWriteEnabled is whether writes are mirrored to the SecondaryStore
+		DualWriteEnabled dynamicconfig.BoolPropertyFn `yaml:"-" json:"-"`
+		// TODO: move dynamic config out of static config
+		// ShadowReadEnabled is whether reads are also served by the SecondaryStore and compared with the DefaultStore
+		ShadowReadEnabled dynamicconfig.BoolPropertyFn `yaml:"-" json:"-"`
+		// Encryption enables envelope encryption of the history events at rest, optional
+		Encryption *Encryption `yaml:"encryption"`
+	}
+
+	// Encryption is the config for envelope encryption of persisted payloads
+	Encryption struct {
+		// KeyringFile is the path of the keyring file holding the master keys
+		KeyringFile string `yaml:"keyringFile" validate:"nonzero"`
+		// DataKeyRotationInterval is how long a domain data key is used to encrypt before a new one is generated.
+		// Default is 24 hours
+		DataKeyRotationInterval time.Duration `yaml:"dataKeyRotationInterval"`
 	}
 
 	// DataStore is the configuration for a single datastore
@@ -85,6 +85,13 @@ func (c *Persistence) Validate() error {
 		useAdvancedVisibilityOnly = true
 	}
 
+	if c.SecondaryStore != "" {
+		if c.SecondaryStore == c.DefaultStore {
+			return fmt.Errorf("persistence config: secondaryStore must be different from defaultStore")
+		}
+		dbStoreKeys = append(dbStoreKeys, c.SecondaryStore)
+	}
+
 	for _, st := range dbStoreKeys {
 		ds, ok := c.DataStores[st]
 		if !ok {
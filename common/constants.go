@@ -66,6 +66,13 @@ const (
 	EncodingTypeUnknown  EncodingType = "unknow"
 	EncodingTypeEmpty    EncodingType = ""
 	EncodingTypeProto    EncodingType = "proto3"
+
+	// EncodingTypeThriftRWSnappy is thriftrw compressed with snappy
+	EncodingTypeThriftRWSnappy EncodingType = "thriftrw-snappy"
+	// EncodingTypeThriftRWZstd is thriftrw compressed with zstd
+	EncodingTypeThriftRWZstd EncodingType = "thriftrw-zstd"
+	// EncodingTypeEncrypted is a blob of any other encoding sealed by envelope encryption
+	EncodingTypeEncrypted EncodingType = "encrypted"
 )
 
 type (
@@ -162,6 +169,9 @@ const (
 	DomainDataKeyForReadGroups = "READ_GROUPS"
 	// DomainDataKeyForWriteGroups stores which groups have write permission of the domain API
 	DomainDataKeyForWriteGroups = "WRITE_GROUPS"
+	// DomainDataKeyForCrossDomainCallers stores which domains can start child workflows, signal, cancel or
+	// schedule activities in the domain, separated by space
+	DomainDataKeyForCrossDomainCallers = "CROSS_DOMAIN_CALLERS"
 )
 
 type (
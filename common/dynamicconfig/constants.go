@@ -148,6 +148,18 @@ const (
 	// Default value: 0
 	// Allowed filters: N/A
 	PersistenceErrorInjectionRate
+	// PersistenceDualWriteEnabled is whether writes to execution, history, task and shard managers are mirrored to the secondary store
+	// KeyName: system.persistenceDualWriteEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	PersistenceDualWriteEnabled
+	// PersistenceShadowReadEnabled is whether reads are also served by the secondary store and compared with the primary store
+	// KeyName: system.persistenceShadowReadEnabled
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: N/A
+	PersistenceShadowReadEnabled
 	// MaxRetentionDays is the maximum allowed retention days for domain
 	// KeyName: system.maxRetentionDays
 	// Value type: Int
@@ -212,25 +224,25 @@ const (
 	// KeyName: limit.historySize.error
 	// Value type: Int
 	// Default value: 209715200 (200*1024*1024)
-	// Allowed filters: DomainName
+	// Allowed filters: DomainName, WorkflowType
 	HistorySizeLimitError
 	// HistorySizeLimitWarn is the per workflow execution history size limit for warning
 	// KeyName: limit.historySize.warn
 	// Value type: Int
 	// Default value: 52428800 (50*1024*1024)
-	// Allowed filters: DomainName
+	// Allowed filters: DomainName, WorkflowType
 	HistorySizeLimitWarn
 	// HistoryCountLimitError is the per workflow execution history event count limit
 	// KeyName: limit.historyCount.error
 	// Value type: Int
 	// Default value: 204800 (200*1024)
-	// Allowed filters: DomainName
+	// Allowed filters: DomainName, WorkflowType
 	HistoryCountLimitError
 	// HistoryCountLimitWarn is the per workflow execution history event count limit for warning
 	// KeyName: limit.historyCount.warn
 	// Value type: Int
 	// Default value: 51200 (50*1024)
-	// Allowed filters: DomainName
+	// Allowed filters: DomainName, WorkflowType
 	HistoryCountLimitWarn
 	// DomainNameMaxLength is the length limit for domain name
 	// KeyName: limit.domainNameLength
@@ -1386,7 +1398,8 @@ const (
 	// Default value: 5m (5*time.Minute)
 	// Allowed filters: N/A
 	ShardSyncMinInterval
-	// DefaultEventEncoding is the encoding type for history events
+	// DefaultEventEncoding is the encoding type for history events and the blobs of workflow mutable state,
+	// thriftrw-snappy and thriftrw-zstd compress new writes, blobs already written keep their encoding
 	// KeyName: history.defaultEventEncoding
 	// Value type: String
 	// Default value: string(common.EncodingTypeThriftRW)
@@ -1507,6 +1520,13 @@ const (
 	// Default value: false
 	// Allowed filters: DomainName
 	EnableCrossClusterOperations
+	// EnforceCrossDomainAllowlist indicates if calls from other domains into a domain are denied unless the caller is
+	// listed in the CROSS_DOMAIN_CALLERS domain data. The list is enforced whenever it is set, regardless of this value
+	// KeyName: history.enforceCrossDomainAllowlist
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: DomainName
+	EnforceCrossDomainAllowlist
 	// MaxBufferedQueryCount indicates the maximum number of queries which can be buffered at a given time for a single workflow
 	// KeyName: history.MaxBufferedQueryCount
 	// Value type: Int
@@ -1549,6 +1569,14 @@ const (
 	// Default value: false
 	// Allowed filters: DomainName
 	EnableActivityLocalDispatchByDomain
+	// EnableHistorySizeWarningMarker is whether a HistorySizeWarning marker is recorded in the history when it crosses
+	// limit.historyCount.warn. Client libraries that do not know the marker fail to replay it, so only enable it for
+	// domains whose workers handle it
+	// KeyName: history.enableHistorySizeWarningMarker
+	// Value type: Bool
+	// Default value: false
+	// Allowed filters: DomainName
+	EnableHistorySizeWarningMarker
 	// HistoryErrorInjectionRate is rate for injecting random error in history client
 	// KeyName: history.errorInjectionRate
 	// Value type: Float64
@@ -1934,6 +1962,12 @@ const (
 	// Default value: true
 	// Allowed filters: N/A
 	EnableWorkflowShadower
+	// EnableScheduler indicates if the scheduler running the schedule workflows is enabled
+	// KeyName: worker.enableScheduler
+	// Value type: Bool
+	// Default value: true
+	// Allowed filters: N/A
+	EnableScheduler
 	// ConcreteExecutionFixerDomainAllow is which domains are allowed to be fixed by concrete fixer workflow
 	// KeyName: worker.concreteExecutionFixerDomainAllow
 	// Value type: Bool
@@ -2142,6 +2176,8 @@ var Keys = map[Key]string{
 	EnableGracefulFailover:              "system.enableGracefulFailover",
 	TransactionSizeLimit:                "system.transactionSizeLimit",
 	PersistenceErrorInjectionRate:       "system.persistenceErrorInjectionRate",
+	PersistenceDualWriteEnabled:         "system.persistenceDualWriteEnabled",
+	PersistenceShadowReadEnabled:        "system.persistenceShadowReadEnabled",
 	MaxRetentionDays:                    "system.maxRetentionDays",
 	MinRetentionDays:                    "system.minRetentionDays",
 	MaxDecisionStartToCloseSeconds:      "system.maxDecisionStartToCloseSeconds",
@@ -2151,6 +2187,7 @@ var Keys = map[Key]string{
 	EnableESAnalyzer:                    "system.enableESAnalyzer",
 	EnableFailoverManager:               "system.enableFailoverManager",
 	EnableWorkflowShadower:              "system.enableWorkflowShadower",
+	EnableScheduler:                     "worker.enableScheduler",
 	EnableStickyQuery:                   "system.enableStickyQuery",
 	EnableDebugMode:                     "system.enableDebugMode",
 	RequiredDomainDataKeys:              "system.requiredDomainDataKeys",
@@ -2408,6 +2445,7 @@ var Keys = map[Key]string{
 	EnableConsistentQuery:                              "history.EnableConsistentQuery",
 	EnableConsistentQueryByDomain:                      "history.EnableConsistentQueryByDomain",
 	EnableCrossClusterOperations:                       "history.enableCrossClusterOperations",
+	EnforceCrossDomainAllowlist:                        "history.enforceCrossDomainAllowlist",
 	MaxBufferedQueryCount:                              "history.MaxBufferedQueryCount",
 	MutableStateChecksumGenProbability:                 "history.mutableStateChecksumGenProbability",
 	MutableStateChecksumVerifyProbability:              "history.mutableStateChecksumVerifyProbability",
@@ -2416,6 +2454,7 @@ var Keys = map[Key]string{
 	NotifyFailoverMarkerTimerJitterCoefficient:         "history.NotifyFailoverMarkerTimerJitterCoefficient",
 	EnableDropStuckTaskByDomainID:                      "history.DropStuckTaskByDomain",
 	EnableActivityLocalDispatchByDomain:                "history.enableActivityLocalDispatchByDomain",
+	EnableHistorySizeWarningMarker:                     "history.enableHistorySizeWarningMarker",
 	HistoryErrorInjectionRate:                          "history.errorInjectionRate",
 	HistoryEnableTaskInfoLogByDomainID:                 "history.enableTaskInfoLogByDomainID",
 	ActivityMaxScheduleToStartTimeoutForRetry:          "history.activityMaxScheduleToStartTimeoutForRetry",
@@ -0,0 +1,208 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/uber/cadence/common/clock"
+)
+
+const (
+	envelopeVersion       byte = 1
+	dataKeySize                = 32
+	maxMasterKeyIDLength       = math.MaxUint8
+	unwrappedKeyCacheSize      = 1000
+)
+
+type (
+	dataKey struct {
+		aead        cipher.AEAD
+		header      []byte
+		createdTime time.Time
+	}
+
+	envelopeImpl struct {
+		sync.Mutex
+		provider         KeyProvider
+		rotationInterval time.Duration
+		timeSource       clock.TimeSource
+		domainKeys       map[string]*dataKey
+		// unwrappedKeys caches the data keys of ciphertexts read back, keyed by master key id and wrapped key
+		unwrappedKeys map[string]cipher.AEAD
+	}
+)
+
+// NewEnvelope returns an Envelope generating a data key per domain, which is replaced by a new one
+// every rotationInterval. A ciphertext is laid out as
+//
+//	version | len(masterKeyID) | masterKeyID | len(wrappedKey) | wrappedKey | nonce | sealed payload
+func NewEnvelope(
+	provider KeyProvider,
+	rotationInterval time.Duration,
+) Envelope {
+	return &envelopeImpl{
+		provider:         provider,
+		rotationInterval: rotationInterval,
+		timeSource:       clock.NewRealTimeSource(),
+		domainKeys:       make(map[string]*dataKey),
+		unwrappedKeys:    make(map[string]cipher.AEAD),
+	}
+}
+
+func (e *envelopeImpl) Encrypt(ctx context.Context, domainID string, plaintext []byte) ([]byte, error) {
+	key, err := e.getDomainKey(ctx, domainID)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(key.aead, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := make([]byte, 0, len(key.header)+len(sealed))
+	ciphertext = append(ciphertext, key.header...)
+	return append(ciphertext, sealed...), nil
+}
+
+func (e *envelopeImpl) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	masterKeyID, wrapped, sealed, err := parseCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := masterKeyID + "/" + string(wrapped)
+	e.Lock()
+	aead, ok := e.unwrappedKeys[cacheKey]
+	e.Unlock()
+	if !ok {
+		key, err := e.provider.UnwrapKey(ctx, masterKeyID, wrapped)
+		if err != nil {
+			return nil, err
+		}
+		if aead, err = newAEAD(key); err != nil {
+			return nil, err
+		}
+		e.Lock()
+		if len(e.unwrappedKeys) >= unwrappedKeyCacheSize {
+			e.unwrappedKeys = make(map[string]cipher.AEAD)
+		}
+		e.unwrappedKeys[cacheKey] = aead
+		e.Unlock()
+	}
+	return open(aead, sealed)
+}
+
+func (e *envelopeImpl) Rewrap(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	masterKeyID, wrapped, sealed, err := parseCiphertext(ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	key, err := e.provider.UnwrapKey(ctx, masterKeyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	newMasterKeyID, rewrapped, err := e.provider.WrapKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	header, err := newHeader(newMasterKeyID, rewrapped)
+	if err != nil {
+		return nil, err
+	}
+	return append(header, sealed...), nil
+}
+
+func (e *envelopeImpl) getDomainKey(ctx context.Context, domainID string) (*dataKey, error) {
+	now := e.timeSource.Now()
+	e.Lock()
+	key, ok := e.domainKeys[domainID]
+	e.Unlock()
+	if ok && now.Sub(key.createdTime) < e.rotationInterval {
+		return key, nil
+	}
+
+	// the key provider may be a remote service, don't hold the lock while calling it
+	plainKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(plainKey); err != nil {
+		return nil, err
+	}
+	masterKeyID, wrapped, err := e.provider.WrapKey(ctx, plainKey)
+	if err != nil {
+		return nil, err
+	}
+	header, err := newHeader(masterKeyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(plainKey)
+	if err != nil {
+		return nil, err
+	}
+	key = &dataKey{
+		aead:        aead,
+		header:      header,
+		createdTime: now,
+	}
+	e.Lock()
+	e.domainKeys[domainID] = key
+	e.Unlock()
+	return key, nil
+}
+
+func newHeader(masterKeyID string, wrapped []byte) ([]byte, error) {
+	if len(masterKeyID) > maxMasterKeyIDLength {
+		return nil, fmt.Errorf("master key id %q is longer than %v bytes", masterKeyID, maxMasterKeyIDLength)
+	}
+	if len(wrapped) > math.MaxUint16 {
+		return nil, fmt.Errorf("wrapped data key is longer than %v bytes", math.MaxUint16)
+	}
+	header := make([]byte, 0, 4+len(masterKeyID)+len(wrapped))
+	header = append(header, envelopeVersion, byte(len(masterKeyID)))
+	header = append(header, masterKeyID...)
+	header = append(header, 0, 0)
+	binary.BigEndian.PutUint16(header[len(header)-2:], uint16(len(wrapped)))
+	return append(header, wrapped...), nil
+}
+
+func parseCiphertext(ciphertext []byte) (masterKeyID string, wrapped []byte, sealed []byte, err error) {
+	if len(ciphertext) < 2 || ciphertext[0] != envelopeVersion {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	idLength := int(ciphertext[1])
+	rest := ciphertext[2:]
+	if len(rest) < idLength+2 {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	masterKeyID = string(rest[:idLength])
+	rest = rest[idLength:]
+	wrappedLength := int(binary.BigEndian.Uint16(rest))
+	rest = rest[2:]
+	if len(rest) < wrappedLength {
+		return "", nil, nil, ErrMalformedCiphertext
+	}
+	return masterKeyID, rest[:wrappedLength], rest[wrappedLength:], nil
+}
@@ -0,0 +1,170 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common/clock"
+)
+
+type (
+	envelopeSuite struct {
+		suite.Suite
+		keyring *KeyringFile
+	}
+)
+
+func TestEnvelopeSuite(t *testing.T) {
+	suite.Run(t, new(envelopeSuite))
+}
+
+func (s *envelopeSuite) SetupTest() {
+	s.keyring = &KeyringFile{
+		ActiveKey: "key-1",
+		Keys: map[string]string{
+			"key-1": s.newMasterKey(),
+		},
+	}
+}
+
+func (s *envelopeSuite) newMasterKey() string {
+	key := make([]byte, dataKeySize)
+	_, err := rand.Read(key)
+	s.NoError(err)
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+func (s *envelopeSuite) newEnvelope(rotationInterval time.Duration) *envelopeImpl {
+	provider, err := newKeyProvider(s.keyring)
+	s.NoError(err)
+	return NewEnvelope(provider, rotationInterval).(*envelopeImpl)
+}
+
+func (s *envelopeSuite) TestEncryptDecrypt() {
+	envelope := s.newEnvelope(time.Hour)
+	plaintext := []byte("workflow input")
+
+	ciphertext, err := envelope.Encrypt(context.Background(), "domain-1", plaintext)
+	s.NoError(err)
+	s.NotContains(string(ciphertext), string(plaintext))
+
+	decrypted, err := envelope.Decrypt(context.Background(), ciphertext)
+	s.NoError(err)
+	s.Equal(plaintext, decrypted)
+
+	// a fresh envelope only needs the keyring to decrypt
+	decrypted, err = s.newEnvelope(time.Hour).Decrypt(context.Background(), ciphertext)
+	s.NoError(err)
+	s.Equal(plaintext, decrypted)
+
+	ciphertext[len(ciphertext)-1] ^= 0xff
+	_, err = envelope.Decrypt(context.Background(), ciphertext)
+	s.Error(err)
+
+	_, err = envelope.Decrypt(context.Background(), []byte("not encrypted"))
+	s.Equal(ErrMalformedCiphertext, err)
+}
+
+func (s *envelopeSuite) TestDataKeyPerDomain() {
+	envelope := s.newEnvelope(time.Hour)
+
+	_, err := envelope.Encrypt(context.Background(), "domain-1", []byte("payload"))
+	s.NoError(err)
+	_, err = envelope.Encrypt(context.Background(), "domain-2", []byte("payload"))
+	s.NoError(err)
+	s.Len(envelope.domainKeys, 2)
+	s.NotEqual(envelope.domainKeys["domain-1"].header, envelope.domainKeys["domain-2"].header)
+}
+
+func (s *envelopeSuite) TestDataKeyRotation() {
+	envelope := s.newEnvelope(time.Hour)
+	timeSource := clock.NewEventTimeSource().Update(time.Now())
+	envelope.timeSource = timeSource
+
+	_, err := envelope.Encrypt(context.Background(), "domain-1", []byte("payload"))
+	s.NoError(err)
+	header := envelope.domainKeys["domain-1"].header
+
+	timeSource.Update(time.Now().Add(30 * time.Minute))
+	_, err = envelope.Encrypt(context.Background(), "domain-1", []byte("payload"))
+	s.NoError(err)
+	s.Equal(header, envelope.domainKeys["domain-1"].header)
+
+	timeSource.Update(time.Now().Add(2 * time.Hour))
+	_, err = envelope.Encrypt(context.Background(), "domain-1", []byte("payload"))
+	s.NoError(err)
+	s.NotEqual(header, envelope.domainKeys["domain-1"].header)
+}
+
+func (s *envelopeSuite) TestRewrap() {
+	ciphertext, err := s.newEnvelope(time.Hour).Encrypt(context.Background(), "domain-1", []byte("payload"))
+	s.NoError(err)
+
+	s.keyring.Keys["key-2"] = s.newMasterKey()
+	s.keyring.ActiveKey = "key-2"
+	rewrapped, err := s.newEnvelope(time.Hour).Rewrap(context.Background(), ciphertext)
+	s.NoError(err)
+	masterKeyID, _, _, err := parseCiphertext(rewrapped)
+	s.NoError(err)
+	s.Equal("key-2", masterKeyID)
+
+	// once every ciphertext is re-wrapped the old master key can be retired
+	delete(s.keyring.Keys, "key-1")
+	envelope := s.newEnvelope(time.Hour)
+	decrypted, err := envelope.Decrypt(context.Background(), rewrapped)
+	s.NoError(err)
+	s.Equal([]byte("payload"), decrypted)
+	_, err = envelope.Decrypt(context.Background(), ciphertext)
+	s.Equal(ErrUnknownMasterKey, err)
+}
+
+func (s *envelopeSuite) TestFileKeyProvider() {
+	dir, err := ioutil.TempDir("", "keyring")
+	s.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "keyring.yaml")
+	content := "activeKey: key-1\nkeys:\n  key-1: " + s.keyring.Keys["key-1"] + "\n"
+	s.NoError(ioutil.WriteFile(path, []byte(content), 0600))
+
+	provider, err := NewFileKeyProvider(path)
+	s.NoError(err)
+	masterKeyID, wrapped, err := provider.WrapKey(context.Background(), []byte("data key"))
+	s.NoError(err)
+	s.Equal("key-1", masterKeyID)
+	unwrapped, err := provider.UnwrapKey(context.Background(), masterKeyID, wrapped)
+	s.NoError(err)
+	s.Equal([]byte("data key"), unwrapped)
+
+	s.keyring.ActiveKey = "missing"
+	_, err = newKeyProvider(s.keyring)
+	s.Error(err)
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encryption
+
+import (
+	"context"
+	"errors"
+)
+
+type (
+	// KeyProvider wraps and unwraps data keys with master keys it never hands out
+	KeyProvider interface {
+		// WrapKey encrypts a data key with the active master key, it returns the id of that master key along with the wrapped key
+		WrapKey(ctx context.Context, dataKey []byte) (masterKeyID string, wrapped []byte, err error)
+		// UnwrapKey decrypts a data key previously wrapped with the given master key
+		UnwrapKey(ctx context.Context, masterKeyID string, wrapped []byte) ([]byte, error)
+	}
+
+	// Envelope encrypts payloads with per domain data keys wrapped by a KeyProvider.
+	// Every ciphertext carries its wrapped data key, so decryption needs no domain.
+	Envelope interface {
+		// Encrypt seals plaintext with the current data key of the domain
+		Encrypt(ctx context.Context, domainID string, plaintext []byte) ([]byte, error)
+		// Decrypt opens a ciphertext produced by Encrypt
+		Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+		// Rewrap re-wraps the data key carried by a ciphertext with the active master key,
+		// the payload is left as is. It is how stored data follows master key rotation.
+		Rewrap(ctx context.Context, ciphertext []byte) ([]byte, error)
+	}
+)
+
+var (
+	// ErrUnknownMasterKey indicates the master key a data key was wrapped with is not known to the KeyProvider
+	ErrUnknownMasterKey = errors.New("unknown master key")
+	// ErrMalformedCiphertext indicates the ciphertext was not produced by an Envelope
+	ErrMalformedCiphertext = errors.New("malformed ciphertext")
+)
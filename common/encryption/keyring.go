@@ -0,0 +1,129 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+
+	"gopkg.in/yaml.v2"
+)
+
+type (
+	// KeyringFile is the layout of the file read by NewFileKeyProvider, keys are base64 encoded AES-256 keys.
+	// Rotating the master key means adding a key and making it active, retired keys must stay
+	// in the keyring until every data key wrapped with them has been re-wrapped.
+	KeyringFile struct {
+		ActiveKey string            `yaml:"activeKey"`
+		Keys      map[string]string `yaml:"keys"`
+	}
+
+	fileKeyProvider struct {
+		activeKey string
+		keys      map[string]cipher.AEAD
+	}
+)
+
+// NewFileKeyProvider returns a KeyProvider holding its master keys in a keyring file
+func NewFileKeyProvider(path string) (KeyProvider, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring file %v: %v", path, err)
+	}
+	var keyring KeyringFile
+	if err := yaml.Unmarshal(content, &keyring); err != nil {
+		return nil, fmt.Errorf("failed to decode keyring file %v: %v", path, err)
+	}
+	return newKeyProvider(&keyring)
+}
+
+func newKeyProvider(keyring *KeyringFile) (KeyProvider, error) {
+	if _, ok := keyring.Keys[keyring.ActiveKey]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", keyring.ActiveKey)
+	}
+	keys := make(map[string]cipher.AEAD, len(keyring.Keys))
+	for id, encoded := range keyring.Keys {
+		if len(id) > maxMasterKeyIDLength {
+			return nil, fmt.Errorf("key id %q is longer than %v bytes", id, maxMasterKeyIDLength)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %v", id, err)
+		}
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("key %q is %v bytes long, want %v", id, len(key), dataKeySize)
+		}
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = aead
+	}
+	return &fileKeyProvider{
+		activeKey: keyring.ActiveKey,
+		keys:      keys,
+	}, nil
+}
+
+func (p *fileKeyProvider) WrapKey(_ context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := seal(p.keys[p.activeKey], dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.activeKey, wrapped, nil
+}
+
+func (p *fileKeyProvider) UnwrapKey(_ context.Context, masterKeyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[masterKeyID]
+	if !ok {
+		return nil, ErrUnknownMasterKey
+	}
+	return open(aead, wrapped)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext under a random nonce, which is prepended to the result
+func seal(aead cipher.AEAD, plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(aead cipher.AEAD, sealed []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedCiphertext
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+}
@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package encryption
+
+import (
+	"context"
+)
+
+type (
+	// KMSClient is the part of a key management service used to wrap data keys,
+	// implement it on top of the client of the KMS in use
+	KMSClient interface {
+		Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error)
+		Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+	}
+
+	kmsKeyProvider struct {
+		client KMSClient
+		keyID  string
+	}
+)
+
+// NewKMSKeyProvider returns a KeyProvider wrapping data keys with the given key of a KMS.
+// Rotating the master key means passing a new keyID, the KMS must keep the old key usable for decryption.
+func NewKMSKeyProvider(client KMSClient, keyID string) KeyProvider {
+	return &kmsKeyProvider{
+		client: client,
+		keyID:  keyID,
+	}
+}
+
+func (p *kmsKeyProvider) WrapKey(ctx context.Context, dataKey []byte) (string, []byte, error) {
+	wrapped, err := p.client.Encrypt(ctx, p.keyID, dataKey)
+	if err != nil {
+		return "", nil, err
+	}
+	return p.keyID, wrapped, nil
+}
+
+func (p *kmsKeyProvider) UnwrapKey(ctx context.Context, masterKeyID string, wrapped []byte) ([]byte, error) {
+	return p.client.Decrypt(ctx, masterKeyID, wrapped)
+}
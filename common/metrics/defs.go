@@ -1781,6 +1781,9 @@ const (
 	PersistenceErrDomainAlreadyExistsCounter
 	PersistenceErrBadRequestCounter
 	PersistenceSampledCounter
+	PersistenceDualWriteFailures
+	PersistenceShadowReadFailures
+	PersistenceShadowReadMismatches
 
 	CadenceClientRequests
 	CadenceClientFailures
@@ -1985,6 +1988,11 @@ const (
 	ActivityResurrectionCounter
 	AutoResetPointsLimitExceededCounter
 	AutoResetPointCorruptionCounter
+	HistorySizeWarnLimitExceededCounter
+	HistorySizeErrorLimitExceededCounter
+	HistoryCountWarnLimitExceededCounter
+	HistoryCountErrorLimitExceededCounter
+	CrossDomainCallDeniedCounter
 	ConcurrencyUpdateFailureCounter
 	CadenceErrEventAlreadyStartedCounter
 	CadenceErrShardOwnershipLostCounter
@@ -2165,6 +2173,7 @@ const (
 	PollerPerTaskListCounter
 	TaskListManagersGauge
 	TaskLagPerTaskListGauge
+	BacklogHeadAgePerTaskListGauge
 
 	NumMatchingMetrics
 )
@@ -2303,6 +2312,9 @@ var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 		PersistenceErrDomainAlreadyExistsCounter:            {metricName: "persistence_errors_domain_already_exists", metricType: Counter},
 		PersistenceErrBadRequestCounter:                     {metricName: "persistence_errors_bad_request", metricType: Counter},
 		PersistenceSampledCounter:                           {metricName: "persistence_sampled", metricType: Counter},
+		PersistenceDualWriteFailures:                        {metricName: "persistence_dual_write_errors", metricType: Counter},
+		PersistenceShadowReadFailures:                       {metricName: "persistence_shadow_read_errors", metricType: Counter},
+		PersistenceShadowReadMismatches:                     {metricName: "persistence_shadow_read_mismatches", metricType: Counter},
 		CadenceClientRequests:                               {metricName: "cadence_client_requests", metricType: Counter},
 		CadenceClientFailures:                               {metricName: "cadence_client_errors", metricType: Counter},
 		CadenceClientLatency:                                {metricName: "cadence_client_latency", metricType: Timer},
@@ -2521,6 +2533,11 @@ var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 		ActivityResurrectionCounter:                       {metricName: "activity_resurrection", metricType: Counter},
 		AutoResetPointsLimitExceededCounter:               {metricName: "auto_reset_points_exceed_limit", metricType: Counter},
 		AutoResetPointCorruptionCounter:                   {metricName: "auto_reset_point_corruption", metricType: Counter},
+		HistorySizeWarnLimitExceededCounter:               {metricName: "history_size_exceed_warn_limit", metricType: Counter},
+		HistorySizeErrorLimitExceededCounter:              {metricName: "history_size_exceed_error_limit", metricType: Counter},
+		HistoryCountWarnLimitExceededCounter:              {metricName: "history_count_exceed_warn_limit", metricType: Counter},
+		HistoryCountErrorLimitExceededCounter:             {metricName: "history_count_exceed_error_limit", metricType: Counter},
+		CrossDomainCallDeniedCounter:                      {metricName: "cross_domain_call_denied", metricType: Counter},
 		ConcurrencyUpdateFailureCounter:                   {metricName: "concurrency_update_failure", metricType: Counter},
 		CadenceErrShardOwnershipLostCounter:               {metricName: "cadence_errors_shard_ownership_lost", metricType: Counter},
 		CadenceErrEventAlreadyStartedCounter:              {metricName: "cadence_errors_event_already_started", metricType: Counter},
@@ -2697,6 +2714,7 @@ var MetricDefs = map[ServiceIdx]map[int]metricDefinition{
 		PollerPerTaskListCounter:                 {metricName: "poller_count_per_tl", metricRollupName: "poller_count"},
 		TaskListManagersGauge:                    {metricName: "tasklist_managers", metricType: Gauge},
 		TaskLagPerTaskListGauge:                  {metricName: "task_lag_per_tl", metricType: Gauge},
+		BacklogHeadAgePerTaskListGauge:           {metricName: "backlog_head_age_per_tl", metricType: Gauge},
 	},
 	Worker: {
 		ReplicatorMessages:                            {metricName: "replicator_messages"},
@@ -22,11 +22,13 @@ package client
 
 import (
 	"sync"
+	"time"
 
 	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/config"
 	"github.com/uber/cadence/common/dynamicconfig"
 	es "github.com/uber/cadence/common/elasticsearch"
+	"github.com/uber/cadence/common/encryption"
 	"github.com/uber/cadence/common/log"
 	"github.com/uber/cadence/common/log/tag"
 	"github.com/uber/cadence/common/messaging"
@@ -80,9 +82,9 @@ type (
 		// NewExecutionStore returns an execution store for given shardID
 		NewExecutionStore(shardID int) (p.ExecutionStore, error)
 		// NewVisibilityStore returns a new visibility store,
-		// TODO We temporarily using sortByCloseTime to determine whether or not ListClosedWorkflowExecutions should
+		// TODO We temporarily using EnableReadDBVisibilityFromClosedExecutionV2 to determine whether or not ListClosedWorkflowExecutions should
 		// be ordering by CloseTime. This will be removed when implementing https://github.com/uber/cadence/issues/3621
-		NewVisibilityStore(sortByCloseTime bool) (p.VisibilityStore, error)
+		NewVisibilityStore(cfg *service.Config) (p.VisibilityStore, error)
 		NewQueue(queueType p.QueueType) (p.Queue, error)
 		// NewConfigStore returns a new config store
 		NewConfigStore() (p.ConfigStore, error)
@@ -99,12 +101,18 @@ type (
 		metricsClient metrics.Client
 		logger        log.Logger
 		datastores    map[storeType]Datastore
+		secondary     *Datastore
 		clusterName   string
+		envelope      encryption.Envelope
 	}
 
 	storeType int
 )
 
+const (
+	defaultDataKeyRotationInterval = 24 * time.Hour
+)
+
 const (
 	storeTypeHistory storeType = iota + 1
 	storeTypeTask
@@ -160,6 +168,24 @@ func (f *factoryImpl) NewTaskManager() (p.TaskManager, error) {
 		return nil, err
 	}
 	result := p.NewTaskManager(store)
+	if f.secondary != nil {
+		secondaryStore, err := f.secondary.factory.NewTaskStore()
+		if err != nil {
+			return nil, err
+		}
+		secondary := p.NewTaskManager(secondaryStore)
+		if f.secondary.ratelimit != nil {
+			secondary = p.NewTaskPersistenceRateLimitedClient(secondary, f.secondary.ratelimit, f.logger)
+		}
+		result = p.NewTaskPersistenceDualWriteClient(
+			result,
+			secondary,
+			f.config.DualWriteEnabled,
+			f.config.ShadowReadEnabled,
+			f.getMetricsClient(),
+			f.logger,
+		)
+	}
 	if errorRate := f.config.ErrorInjectionRate(); errorRate != 0 {
 		result = p.NewTaskPersistenceErrorInjectionClient(result, errorRate, f.logger)
 	}
@@ -180,6 +206,24 @@ func (f *factoryImpl) NewShardManager() (p.ShardManager, error) {
 		return nil, err
 	}
 	result := p.NewShardManager(store)
+	if f.secondary != nil {
+		secondaryStore, err := f.secondary.factory.NewShardStore()
+		if err != nil {
+			return nil, err
+		}
+		secondary := p.NewShardManager(secondaryStore)
+		if f.secondary.ratelimit != nil {
+			secondary = p.NewShardPersistenceRateLimitedClient(secondary, f.secondary.ratelimit, f.logger)
+		}
+		result = p.NewShardPersistenceDualWriteClient(
+			result,
+			secondary,
+			f.config.DualWriteEnabled,
+			f.config.ShadowReadEnabled,
+			f.getMetricsClient(),
+			f.logger,
+		)
+	}
 	if errorRate := f.config.ErrorInjectionRate(); errorRate != 0 {
 		result = p.NewShardPersistenceErrorInjectionClient(result, errorRate, f.logger)
 	}
@@ -199,7 +243,29 @@ func (f *factoryImpl) NewHistoryManager() (p.HistoryManager, error) {
 	if err != nil {
 		return nil, err
 	}
-	result := p.NewHistoryV2ManagerImpl(store, f.logger, f.config.TransactionSizeLimit)
+	envelope, err := f.getEnvelope()
+	if err != nil {
+		return nil, err
+	}
+	result := p.NewHistoryV2ManagerImpl(store, f.logger, f.config.TransactionSizeLimit, envelope)
+	if f.secondary != nil {
+		secondaryStore, err := f.secondary.factory.NewHistoryStore()
+		if err != nil {
+			return nil, err
+		}
+		secondary := p.NewHistoryV2ManagerImpl(secondaryStore, f.logger, f.config.TransactionSizeLimit, envelope)
+		if f.secondary.ratelimit != nil {
+			secondary = p.NewHistoryPersistenceRateLimitedClient(secondary, f.secondary.ratelimit, f.logger)
+		}
+		result = p.NewHistoryPersistenceDualWriteClient(
+			result,
+			secondary,
+			f.config.DualWriteEnabled,
+			f.config.ShadowReadEnabled,
+			f.getMetricsClient(),
+			f.logger,
+		)
+	}
 	if errorRate := f.config.ErrorInjectionRate(); errorRate != 0 {
 		result = p.NewHistoryPersistenceErrorInjectionClient(result, errorRate, f.logger)
 	}
@@ -212,6 +278,36 @@ func (f *factoryImpl) NewHistoryManager() (p.HistoryManager, error) {
 	return result, nil
 }
 
+// getEnvelope returns the envelope encrypting persisted payloads, nil when encryption is not configured
+func (f *factoryImpl) getEnvelope() (encryption.Envelope, error) {
+	cfg := f.config.Encryption
+	if cfg == nil {
+		return nil, nil
+	}
+	f.Lock()
+	defer f.Unlock()
+	if f.envelope == nil {
+		provider, err := encryption.NewFileKeyProvider(cfg.KeyringFile)
+		if err != nil {
+			return nil, err
+		}
+		rotationInterval := cfg.DataKeyRotationInterval
+		if rotationInterval <= 0 {
+			rotationInterval = defaultDataKeyRotationInterval
+		}
+		f.envelope = encryption.NewEnvelope(provider, rotationInterval)
+	}
+	return f.envelope, nil
+}
+
+// getMetricsClient returns the metrics client, a noop one when metrics are not configured
+func (f *factoryImpl) getMetricsClient() metrics.Client {
+	if f.metricsClient == nil {
+		return metrics.NewNoopMetricsClient()
+	}
+	return f.metricsClient
+}
+
 // NewDomainManager returns a new metadata manager
 func (f *factoryImpl) NewDomainManager() (p.DomainManager, error) {
 	var err error
@@ -242,6 +338,24 @@ func (f *factoryImpl) NewExecutionManager(shardID int) (p.ExecutionManager, erro
 		return nil, err
 	}
 	result := p.NewExecutionManagerImpl(store, f.logger)
+	if f.secondary != nil {
+		secondaryStore, err := f.secondary.factory.NewExecutionStore(shardID)
+		if err != nil {
+			return nil, err
+		}
+		secondary := p.NewExecutionManagerImpl(secondaryStore, f.logger)
+		if f.secondary.ratelimit != nil {
+			secondary = p.NewWorkflowExecutionPersistenceRateLimitedClient(secondary, f.secondary.ratelimit, f.logger)
+		}
+		result = p.NewWorkflowExecutionPersistenceDualWriteClient(
+			result,
+			secondary,
+			f.config.DualWriteEnabled,
+			f.config.ShadowReadEnabled,
+			f.getMetricsClient(),
+			f.logger,
+		)
+	}
 	if errorRate := f.config.ErrorInjectionRate(); errorRate != 0 {
 		result = p.NewWorkflowExecutionPersistenceErrorInjectionClient(result, errorRate, f.logger)
 	}
@@ -325,15 +439,12 @@ func newESVisibilityManager(
 func (f *factoryImpl) newDBVisibilityManager(
 	visibilityConfig *service.Config,
 ) (p.VisibilityManager, error) {
-	enableReadFromClosedExecutionV2 := false
-	if visibilityConfig.EnableReadDBVisibilityFromClosedExecutionV2 != nil {
-		enableReadFromClosedExecutionV2 = visibilityConfig.EnableReadDBVisibilityFromClosedExecutionV2()
-	} else {
+	if visibilityConfig.EnableReadDBVisibilityFromClosedExecutionV2 == nil {
 		f.logger.Warn("missing visibility and EnableReadFromClosedExecutionV2 config", tag.Value(visibilityConfig))
 	}
 
 	ds := f.datastores[storeTypeVisibility]
-	store, err := ds.factory.NewVisibilityStore(enableReadFromClosedExecutionV2)
+	store, err := ds.factory.NewVisibilityStore(visibilityConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -402,6 +513,9 @@ func (f *factoryImpl) NewConfigStoreManager() (p.ConfigStoreManager, error) {
 func (f *factoryImpl) Close() {
 	ds := f.datastores[storeTypeExecution]
 	ds.factory.Close()
+	if f.secondary != nil {
+		f.secondary.factory.Close()
+	}
 }
 
 func (f *factoryImpl) init(clusterName string, limiters map[string]quotas.Limiter) {
@@ -442,6 +556,13 @@ func (f *factoryImpl) init(clusterName string, limiters map[string]quotas.Limite
 		}
 	}
 
+	if secondaryCfg, ok := f.config.DataStores[f.config.SecondaryStore]; ok {
+		f.secondary = &Datastore{
+			factory:   f.newDataStoreFactory("secondaryStore", secondaryCfg, clusterName),
+			ratelimit: limiters[f.config.SecondaryStore],
+		}
+	}
+
 	visibilityCfg, ok := f.config.DataStores[f.config.VisibilityStore]
 	if !ok {
 		f.logger.Info("no visibilityStore is configured, will use advancedVisibilityStore")
@@ -473,6 +594,29 @@ func (f *factoryImpl) init(clusterName string, limiters map[string]quotas.Limite
 	f.datastores[storeTypeVisibility] = visibilityDataStore
 }
 
+func (f *factoryImpl) newDataStoreFactory(name string, cfg config.DataStore, clusterName string) DataStoreFactory {
+	if cfg.Cassandra != nil {
+		f.logger.Warn("Cassandra config is deprecated, please use NoSQL with pluginName of cassandra.")
+	}
+	switch {
+	case cfg.NoSQL != nil:
+		return nosql.NewFactory(*cfg.NoSQL, clusterName, f.logger)
+	case cfg.SQL != nil:
+		var decodingTypes []common.EncodingType
+		for _, dt := range cfg.SQL.DecodingTypes {
+			decodingTypes = append(decodingTypes, common.EncodingType(dt))
+		}
+		return sql.NewFactory(
+			*cfg.SQL,
+			clusterName,
+			f.logger,
+			getSQLParser(f.logger, common.EncodingType(cfg.SQL.EncodingType), decodingTypes...))
+	default:
+		f.logger.Fatal("invalid config: one of nosql or sql params must be specified for " + name)
+		return nil
+	}
+}
+
 func getSQLParser(logger log.Logger, encodingType common.EncodingType, decodingTypes ...common.EncodingType) serialization.Parser {
 	parser, err := serialization.NewParser(encodingType, decodingTypes...)
 	if err != nil {
@@ -0,0 +1,123 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package persistence
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/uber/cadence/common"
+)
+
+type (
+	// compressionCodec compresses the payload of a blob on top of its serialization encoding
+	compressionCodec interface {
+		compress(data []byte) ([]byte, error)
+		decompress(data []byte) ([]byte, error)
+	}
+
+	snappyCodec struct{}
+
+	zstdCodec struct {
+		once    sync.Once
+		err     error
+		encoder *zstd.Encoder
+		decoder *zstd.Decoder
+	}
+)
+
+// compressionCodecs maps every compressed encoding type to its codec,
+// all of them compress thriftrw payloads
+var compressionCodecs = map[common.EncodingType]compressionCodec{
+	common.EncodingTypeThriftRWSnappy: snappyCodec{},
+	common.EncodingTypeThriftRWZstd:   &zstdCodec{},
+}
+
+// IsCompressedEncoding returns true if blobs of the given encoding type are compressed
+func IsCompressedEncoding(encodingType common.EncodingType) bool {
+	_, ok := compressionCodecs[encodingType]
+	return ok
+}
+
+// DecompressDataBlob returns the thriftrw blob a compressed blob was made of.
+// Blobs that are not compressed are returned as is.
+func DecompressDataBlob(blob *DataBlob) (*DataBlob, error) {
+	if blob == nil || !IsCompressedEncoding(blob.Encoding) {
+		return blob, nil
+	}
+	data, err := decompress(blob.Encoding, blob.Data)
+	if err != nil {
+		return nil, NewCadenceDeserializationError(fmt.Sprintf("DecompressDataBlob encoding: \"%v\", error: %v", blob.Encoding, err.Error()))
+	}
+	return NewDataBlob(data, common.EncodingTypeThriftRW), nil
+}
+
+func compress(encodingType common.EncodingType, data []byte) ([]byte, error) {
+	codec, ok := compressionCodecs[encodingType]
+	if !ok {
+		return nil, NewUnknownEncodingTypeError(encodingType)
+	}
+	return codec.compress(data)
+}
+
+func decompress(encodingType common.EncodingType, data []byte) ([]byte, error) {
+	codec, ok := compressionCodecs[encodingType]
+	if !ok {
+		return nil, NewUnknownEncodingTypeError(encodingType)
+	}
+	return codec.decompress(data)
+}
+
+func (snappyCodec) compress(data []byte) ([]byte, error) {
+	return snappy.Encode(nil, data), nil
+}
+
+func (snappyCodec) decompress(data []byte) ([]byte, error) {
+	return snappy.Decode(nil, data)
+}
+
+// init creates the encoder and decoder on first use, both are safe for concurrent EncodeAll and DecodeAll calls
+func (c *zstdCodec) init() error {
+	c.once.Do(func() {
+		if c.encoder, c.err = zstd.NewWriter(nil); c.err != nil {
+			return
+		}
+		c.decoder, c.err = zstd.NewReader(nil)
+	})
+	return c.err
+}
+
+func (c *zstdCodec) compress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.encoder.EncodeAll(data, nil), nil
+}
+
+func (c *zstdCodec) decompress(data []byte) ([]byte, error) {
+	if err := c.init(); err != nil {
+		return nil, err
+	}
+	return c.decoder.DecodeAll(data, nil)
+}
@@ -1515,6 +1515,8 @@ type (
 		Encoding common.EncodingType
 		// The shard to get history node data
 		ShardID *int
+		// optional domain of the events, the events are encrypted with its data key when encryption is enabled
+		DomainID string
 	}
 
 	// AppendHistoryNodesResponse is a response to AppendHistoryNodesRequest
@@ -1588,6 +1590,8 @@ type (
 		// Application must provide a void forking nodeID, it must be a valid nodeID in that branch. A valid nodeID is the firstEventID of a valid batch of events.
 		// And ForkNodeID > 1 because forking from 1 doesn't make any sense.
 		ForkNodeID int64
+		// NewBranchID is the ID of the new branch, optional, a random one is generated when empty
+		NewBranchID string
 		// the info for clean up data in background
 		Info string
 		// The shard to get history branch data
@@ -890,7 +890,7 @@ func NewDataBlob(data []byte, encodingType common.EncodingType) *DataBlob {
 	if data == nil || len(data) == 0 {
 		return nil
 	}
-	if encodingType != "thriftrw" && data[0] == 'Y' {
+	if encodingType != "thriftrw" && !IsCompressedEncoding(encodingType) && data[0] == 'Y' {
 		panic(fmt.Sprintf("Invalid incoding: \"%v\"", encodingType))
 	}
 	return &DataBlob{
@@ -930,6 +930,12 @@ func (d *DataBlob) GetEncoding() common.EncodingType {
 		return common.EncodingTypeJSON
 	case common.EncodingTypeThriftRW:
 		return common.EncodingTypeThriftRW
+	case common.EncodingTypeThriftRWSnappy:
+		return common.EncodingTypeThriftRWSnappy
+	case common.EncodingTypeThriftRWZstd:
+		return common.EncodingTypeThriftRWZstd
+	case common.EncodingTypeEncrypted:
+		return common.EncodingTypeEncrypted
 	case common.EncodingTypeEmpty:
 		return common.EncodingTypeEmpty
 	default:
@@ -0,0 +1,78 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package persistence
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/encryption"
+)
+
+// encryptDataBlob seals a blob with the data key of the domain. The encoding of the blob is sealed
+// along with its data, as a length prefixed string in front of it.
+func encryptDataBlob(
+	ctx context.Context,
+	envelope encryption.Envelope,
+	domainID string,
+	blob *DataBlob,
+) (*DataBlob, error) {
+	if blob == nil {
+		return nil, nil
+	}
+	encoding := blob.GetEncodingString()
+	if len(encoding) > 255 {
+		return nil, NewCadenceSerializationError(fmt.Sprintf("encoding %q is too long to be encrypted", encoding))
+	}
+	plaintext := make([]byte, 0, 1+len(encoding)+len(blob.Data))
+	plaintext = append(plaintext, byte(len(encoding)))
+	plaintext = append(plaintext, encoding...)
+	plaintext = append(plaintext, blob.Data...)
+	ciphertext, err := envelope.Encrypt(ctx, domainID, plaintext)
+	if err != nil {
+		return nil, NewCadenceSerializationError(fmt.Sprintf("failed to encrypt blob: %v", err))
+	}
+	return NewDataBlob(ciphertext, common.EncodingTypeEncrypted), nil
+}
+
+// decryptDataBlob returns the blob an encrypted blob was made of, blobs that are not encrypted are returned as is
+func decryptDataBlob(
+	ctx context.Context,
+	envelope encryption.Envelope,
+	blob *DataBlob,
+) (*DataBlob, error) {
+	if blob == nil || blob.Encoding != common.EncodingTypeEncrypted {
+		return blob, nil
+	}
+	if envelope == nil {
+		return nil, NewCadenceDeserializationError("blob is encrypted but persistence encryption is not configured")
+	}
+	plaintext, err := envelope.Decrypt(ctx, blob.Data)
+	if err != nil {
+		return nil, NewCadenceDeserializationError(fmt.Sprintf("failed to decrypt blob: %v", err))
+	}
+	if len(plaintext) == 0 || len(plaintext) < 1+int(plaintext[0]) {
+		return nil, NewCadenceDeserializationError("decrypted blob is malformed")
+	}
+	encodingLength := int(plaintext[0])
+	return NewDataBlob(plaintext[1+encodingLength:], common.EncodingType(plaintext[1:1+encodingLength])), nil
+}
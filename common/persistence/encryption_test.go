@@ -0,0 +1,81 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package persistence
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/encryption"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestEncryptDataBlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keyring")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	key := make([]byte, 32)
+	_, err = rand.Read(key)
+	require.NoError(t, err)
+	path := filepath.Join(dir, "keyring.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("activeKey: k\nkeys:\n  k: "+base64.StdEncoding.EncodeToString(key)+"\n"), 0600))
+	provider, err := encryption.NewFileKeyProvider(path)
+	require.NoError(t, err)
+	envelope := encryption.NewEnvelope(provider, time.Hour)
+
+	events := []*types.HistoryEvent{{
+		EventID:   1,
+		EventType: types.EventTypeWorkflowExecutionStarted.Ptr(),
+		WorkflowExecutionStartedEventAttributes: &types.WorkflowExecutionStartedEventAttributes{
+			Input: []byte("secret input"),
+		},
+	}}
+	serializer := NewPayloadSerializer()
+	for _, encoding := range []common.EncodingType{common.EncodingTypeThriftRW, common.EncodingTypeThriftRWZstd} {
+		blob, err := serializer.SerializeBatchEvents(events, encoding)
+		require.NoError(t, err)
+
+		encrypted, err := encryptDataBlob(context.Background(), envelope, "domain-id", blob)
+		require.NoError(t, err)
+		require.Equal(t, common.EncodingTypeEncrypted, encrypted.GetEncoding())
+		require.NotContains(t, string(encrypted.Data), "secret input")
+
+		decrypted, err := decryptDataBlob(context.Background(), envelope, encrypted)
+		require.NoError(t, err)
+		require.Equal(t, blob, decrypted)
+
+		_, err = decryptDataBlob(context.Background(), nil, encrypted)
+		require.Error(t, err)
+
+		plain, err := decryptDataBlob(context.Background(), nil, blob)
+		require.NoError(t, err)
+		require.Equal(t, blob, plain)
+	}
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/codec"
 	"github.com/uber/cadence/common/dynamicconfig"
+	"github.com/uber/cadence/common/encryption"
 	"github.com/uber/cadence/common/log"
 	"github.com/uber/cadence/common/log/tag"
 	"github.com/uber/cadence/common/types"
@@ -45,6 +46,7 @@ type (
 		thriftEncoder         codec.BinaryEncoder
 		pagingTokenSerializer *jsonHistoryTokenSerializer
 		transactionSizeLimit  dynamicconfig.IntPropertyFn
+		envelope              encryption.Envelope
 	}
 )
 
@@ -55,11 +57,12 @@ const (
 
 var _ HistoryManager = (*historyV2ManagerImpl)(nil)
 
-// NewHistoryV2ManagerImpl returns new HistoryManager
+// NewHistoryV2ManagerImpl returns new HistoryManager, history events are encrypted at rest when envelope is not nil
 func NewHistoryV2ManagerImpl(
 	persistence HistoryStore,
 	logger log.Logger,
 	transactionSizeLimit dynamicconfig.IntPropertyFn,
+	envelope encryption.Envelope,
 ) HistoryManager {
 
 	return &historyV2ManagerImpl{
@@ -69,6 +72,7 @@ func NewHistoryV2ManagerImpl(
 		thriftEncoder:         codec.NewThriftRWEncoder(),
 		pagingTokenSerializer: newJSONHistoryTokenSerializer(),
 		transactionSizeLimit:  transactionSizeLimit,
+		envelope:              envelope,
 	}
 }
 
@@ -100,10 +104,15 @@ func (m *historyV2ManagerImpl) ForkHistoryBranch(
 		}
 	}
 
+	newBranchID := request.NewBranchID
+	if newBranchID == "" {
+		newBranchID = uuid.New()
+	}
+
 	req := &InternalForkHistoryBranchRequest{
 		ForkBranchInfo: *thrift.ToHistoryBranch(&forkBranch),
 		ForkNodeID:     request.ForkNodeID,
-		NewBranchID:    uuid.New(),
+		NewBranchID:    newBranchID,
 		Info:           request.Info,
 		ShardID:        shardID,
 	}
@@ -225,6 +234,11 @@ func (m *historyV2ManagerImpl) AppendHistoryNodes(
 	if err != nil {
 		return nil, err
 	}
+	if m.envelope != nil && request.DomainID != "" {
+		if blob, err = encryptDataBlob(ctx, m.envelope, request.DomainID, blob); err != nil {
+			return nil, err
+		}
+	}
 	size := len(blob.Data)
 	sizeLimit := m.transactionSizeLimit()
 	if size > sizeLimit {
@@ -300,6 +314,12 @@ func (m *historyV2ManagerImpl) ReadRawHistoryBranch(
 	if err != nil {
 		return nil, err
 	}
+	// raw blobs leave persistence, callers only understand uncompressed encodings
+	for i, blob := range dataBlobs {
+		if dataBlobs[i], err = DecompressDataBlob(blob); err != nil {
+			return nil, err
+		}
+	}
 
 	nextPageToken, err := m.serializeToken(token)
 	if err != nil {
@@ -424,8 +444,11 @@ func (m *historyV2ManagerImpl) readRawHistoryBranch(
 
 	dataBlobs := resp.History
 	dataSize := 0
-	for _, dataBlob := range resp.History {
+	for i, dataBlob := range resp.History {
 		dataSize += len(dataBlob.Data)
+		if dataBlobs[i], err = decryptDataBlob(ctx, m.envelope, dataBlob); err != nil {
+			return nil, nil, 0, nil, err
+		}
 	}
 
 	token.StoreToken = resp.NextPageToken
@@ -27,6 +27,7 @@ import (
 	"github.com/uber/cadence/common/log"
 	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/common/service"
 )
 
 type (
@@ -85,7 +86,8 @@ func (f *Factory) NewExecutionStore(shardID int) (p.ExecutionStore, error) {
 }
 
 // NewVisibilityStore returns a visibility store
-func (f *Factory) NewVisibilityStore(sortByCloseTime bool) (p.VisibilityStore, error) {
+func (f *Factory) NewVisibilityStore(cfg *service.Config) (p.VisibilityStore, error) {
+	sortByCloseTime := cfg.EnableReadDBVisibilityFromClosedExecutionV2 != nil && cfg.EnableReadDBVisibilityFromClosedExecutionV2()
 	return newNoSQLVisibilityStore(sortByCloseTime, f.cfg, f.logger)
 }
 
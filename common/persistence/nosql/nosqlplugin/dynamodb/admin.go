@@ -22,15 +22,98 @@
 package dynamodb
 
 import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
 )
 
 var _ nosqlplugin.AdminDB = (*ddb)(nil)
 
+const (
+	testSchemaDir = "schema/dynamodb/"
+)
+
+// schemaCommand is one entry of the DynamoDB schema file
+type schemaCommand struct {
+	CreateTable *dynamodb.CreateTableInput
+	TimeToLive  *dynamodb.TimeToLiveSpecification
+}
+
 func (db *ddb) SetupTestDatabase(schemaBaseDir string) error {
-	panic("TODO")
+	if schemaBaseDir == "" {
+		var err error
+		schemaBaseDir, err = nosqlplugin.GetDefaultTestSchemaDir(testSchemaDir)
+		if err != nil {
+			return err
+		}
+	}
+
+	schemaFile := schemaBaseDir + "cadence/schema.json"
+	byteValues, err := ioutil.ReadFile(schemaFile)
+	if err != nil {
+		return err
+	}
+	var commands []schemaCommand
+	if err := json.Unmarshal(byteValues, &commands); err != nil {
+		return err
+	}
+
+	// TODO CreateDB/CreateAdminDB don't pass in context.Context so we are using background for now
+	ctx := context.Background()
+	for _, cmd := range commands {
+		if cmd.CreateTable == nil {
+			continue
+		}
+		input := *cmd.CreateTable
+		input.TableName = db.tableName(aws.StringValue(cmd.CreateTable.TableName))
+		if _, err := db.client.CreateTableWithContext(ctx, &input); err != nil {
+			return err
+		}
+		if err := db.client.WaitUntilTableExistsWithContext(ctx, &dynamodb.DescribeTableInput{
+			TableName: input.TableName,
+		}); err != nil {
+			return err
+		}
+		if cmd.TimeToLive != nil {
+			if _, err := db.client.UpdateTimeToLiveWithContext(ctx, &dynamodb.UpdateTimeToLiveInput{
+				TableName:               input.TableName,
+				TimeToLiveSpecification: cmd.TimeToLive,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (db *ddb) TeardownTestDatabase() error {
-	panic("TODO")
+	ctx := context.Background()
+	prefix := aws.StringValue(db.tableName(""))
+	var tables []*string
+	err := db.client.ListTablesPagesWithContext(ctx, &dynamodb.ListTablesInput{}, func(page *dynamodb.ListTablesOutput, lastPage bool) bool {
+		for _, table := range page.TableNames {
+			if strings.HasPrefix(aws.StringValue(table), prefix) {
+				tables = append(tables, table)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		if _, err := db.client.DeleteTableWithContext(ctx, &dynamodb.DeleteTableInput{TableName: table}); err != nil {
+			return err
+		}
+		if err := db.client.WaitUntilTableNotExistsWithContext(ctx, &dynamodb.DescribeTableInput{TableName: table}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -22,15 +22,66 @@ package dynamodb
 
 import (
 	"context"
-	"errors"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
 )
 
+type clusterConfigItem struct {
+	RowType      int    `dynamodbav:"row_type"`
+	Version      int64  `dynamodbav:"version"`
+	Timestamp    int64  `dynamodbav:"timestamp"`
+	Data         []byte `dynamodbav:"data"`
+	DataEncoding string `dynamodbav:"data_encoding"`
+}
+
 func (db *ddb) InsertConfig(ctx context.Context, row *persistence.InternalConfigStoreEntry) error {
-	return errors.New("TODO")
+	item := clusterConfigItem{
+		RowType:      row.RowType,
+		Version:      row.Version,
+		Timestamp:    row.Timestamp.UnixNano(),
+		Data:         row.Values.Data,
+		DataEncoding: row.Values.GetEncodingString(),
+	}
+	err := db.putItem(ctx, cadence.ClusterConfigTableName, item, "attribute_not_exists(row_type)", nil, nil)
+	if db.IsConditionFailedError(err) {
+		return nosqlplugin.NewConditionFailure("InsertConfig operation failed because of version collision")
+	}
+	return err
 }
 
 func (db *ddb) SelectLatestConfig(ctx context.Context, rowType int) (*persistence.InternalConfigStoreEntry, error) {
-	return nil, errors.New("TODO")
+	resp, err := db.client.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.ClusterConfigTableName),
+		KeyConditionExpression: aws.String("row_type = :row_type"),
+		ExpressionAttributeValues: attributeMap{
+			":row_type": numberAttr(int64(rowType)),
+		},
+		ScanIndexForward: aws.Bool(false),
+		Limit:            aws.Int64(1),
+		ConsistentRead:   aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Items) == 0 {
+		return nil, errNotFound
+	}
+	var item clusterConfigItem
+	if err := dynamodbattribute.UnmarshalMap(resp.Items[0], &item); err != nil {
+		return nil, err
+	}
+	return &persistence.InternalConfigStoreEntry{
+		RowType:   rowType,
+		Version:   item.Version,
+		Timestamp: time.Unix(0, item.Timestamp),
+		Values:    persistence.NewDataBlob(item.Data, common.EncodingType(item.DataEncoding)),
+	}, nil
 }
@@ -21,25 +21,29 @@
 package dynamodb
 
 import (
+	"context"
 	"errors"
-	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 
 	"github.com/uber/cadence/common/config"
 	"github.com/uber/cadence/common/log"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
 )
 
-const (
-	// PluginName is the name of the plugin
-	PluginName = "dynamodb"
-)
-
 var (
 	errConditionFailed = errors.New("internal condition fail error")
+	// DynamoDB returns an empty item instead of an error when GetItem doesn't find anything
+	errNotFound = errors.New("item not found")
 )
 
 // ddb represents a logical connection to DynamoDB database
 type ddb struct {
+	client dynamodbiface.DynamoDBAPI
+	cfg    *config.NoSQL
 	logger log.Logger
 }
 
@@ -47,11 +51,11 @@ var _ nosqlplugin.DB = (*ddb)(nil)
 
 // NewDynamoDB return a new DB
 func NewDynamoDB(cfg config.NoSQL, logger log.Logger) (nosqlplugin.DB, error) {
-	return nil, fmt.Errorf("TODO")
+	return (&plugin{}).doCreateDB(&cfg, logger)
 }
 
 func (db *ddb) Close() {
-	panic("TODO")
+	// DynamoDB client is stateless HTTP client, nothing to close
 }
 
 func (db *ddb) PluginName() string {
@@ -59,20 +63,37 @@ func (db *ddb) PluginName() string {
 }
 
 func (db *ddb) IsNotFoundError(err error) bool {
-	panic("TODO")
+	return err == errNotFound
 }
 
 func (db *ddb) IsTimeoutError(err error) bool {
-	panic("TODO")
+	if err == context.DeadlineExceeded {
+		return true
+	}
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == request.CanceledErrorCode || aerr.Code() == request.ErrCodeResponseTimeout
+	}
+	return false
 }
 
 func (db *ddb) IsThrottlingError(err error) bool {
-	panic("TODO")
+	if aerr, ok := err.(awserr.Error); ok {
+		switch aerr.Code() {
+		case dynamodb.ErrCodeProvisionedThroughputExceededException,
+			dynamodb.ErrCodeRequestLimitExceeded,
+			"ThrottlingException":
+			return true
+		}
+	}
+	return false
 }
 
 func (db *ddb) IsConditionFailedError(err error) bool {
 	if err == errConditionFailed {
 		return true
 	}
+	if aerr, ok := err.(awserr.Error); ok {
+		return aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException
+	}
 	return false
 }
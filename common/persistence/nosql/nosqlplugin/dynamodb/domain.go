@@ -22,26 +22,145 @@ package dynamodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log/tag"
+	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
-	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin/cassandra/gocql"
+	"github.com/uber/cadence/common/types"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
+)
+
+// domain table is serving very small volume of traffic, so it's okay to use a constant value for partition key
+const constDomainPartition = 0
+
+type (
+	// domainItem stores the whole DomainRow as a JSON blob, name and domain_id are the only significant columns
+	domainItem struct {
+		DomainPartition int    `dynamodbav:"domain_partition"`
+		Name            string `dynamodbav:"name"`
+		DomainID        string `dynamodbav:"domain_id"`
+		Data            []byte `dynamodbav:"data"`
+	}
+
+	domainMetadataItem struct {
+		DomainPartition     int   `dynamodbav:"domain_partition"`
+		NotificationVersion int64 `dynamodbav:"notification_version"`
+	}
 )
 
-// Insert a new record to domain, return error if failed or already exists
-// Return ConditionFailure if the condition doesn't meet
+func domainMetadataKey() attributeMap {
+	return attributeMap{
+		"domain_partition": numberAttr(constDomainPartition),
+	}
+}
+
+func domainKey(name string) attributeMap {
+	return attributeMap{
+		"domain_partition": numberAttr(constDomainPartition),
+		"name":             stringAttr(name),
+	}
+}
+
+func newDomainItem(row *nosqlplugin.DomainRow) (*domainItem, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	return &domainItem{
+		DomainPartition: constDomainPartition,
+		Name:            row.Info.Name,
+		DomainID:        row.Info.ID,
+		Data:            data,
+	}, nil
+}
+
+func toDomainRow(item attributeMap) (*nosqlplugin.DomainRow, error) {
+	var domain domainItem
+	if err := dynamodbattribute.UnmarshalMap(item, &domain); err != nil {
+		return nil, err
+	}
+	row := &nosqlplugin.DomainRow{}
+	if err := json.Unmarshal(domain.Data, row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// newUpdateMetadataTransactionItem increases the notification version by one,
+// conditioned on the current notification version
+func (db *ddb) newUpdateMetadataTransactionItem(notificationVersion int64) *dynamodb.TransactWriteItem {
+	update := &dynamodb.Update{
+		TableName:        db.tableName(cadence.DomainMetadataTableName),
+		Key:              domainMetadataKey(),
+		UpdateExpression: aws.String("SET notification_version = :next_version"),
+		ExpressionAttributeValues: attributeMap{
+			":next_version": numberAttr(notificationVersion + 1),
+		},
+		ConditionExpression: aws.String("attribute_not_exists(notification_version)"),
+	}
+	if notificationVersion > 0 {
+		update.ConditionExpression = aws.String("notification_version = :current_version")
+		update.ExpressionAttributeValues[":current_version"] = numberAttr(notificationVersion)
+	}
+	return &dynamodb.TransactWriteItem{Update: update}
+}
+
+// Insert a new record to domain
+// return types.DomainAlreadyExistsError error if failed or already exists
+// Must return ConditionFailure error if other condition doesn't match
 func (db *ddb) InsertDomain(
 	ctx context.Context,
 	row *nosqlplugin.DomainRow,
 ) error {
-	panic("TODO")
-}
+	// NOTE: the domain_id index cannot enforce uniqueness, so the ID is checked before the transaction
+	_, err := db.selectDomainNameByID(ctx, row.Info.ID)
+	if err == nil {
+		return fmt.Errorf("CreateDomain operation failed because of uuid collision")
+	}
+	if !db.IsNotFoundError(err) {
+		return err
+	}
 
-func (db *ddb) updateMetadataBatch(
-	ctx context.Context,
-	batch gocql.Batch,
-	notificationVersion int64,
-) {
-	panic("TODO")
+	metadataNotificationVersion, err := db.SelectDomainMetadata(ctx)
+	if err != nil {
+		return err
+	}
+
+	domain := *row
+	domain.NotificationVersion = metadataNotificationVersion
+	domain.FailoverNotificationVersion = p.InitialFailoverNotificationVersion
+	domain.PreviousFailoverVersion = common.InitialPreviousFailoverVersion
+	item, err := newDomainItem(&domain)
+	if err != nil {
+		return err
+	}
+	putItem, err := db.newPutTransactionItem(cadence.DomainTableName, item, "attribute_not_exists(domain_partition)", nil, nil)
+	if err != nil {
+		return err
+	}
+
+	reasons, err := db.executeTransaction(ctx, []*dynamodb.TransactWriteItem{
+		putItem,
+		db.newUpdateMetadataTransactionItem(metadataNotificationVersion),
+	})
+	if db.IsConditionFailedError(err) {
+		if isConditionFailedReason(reasons, 0) {
+			db.logger.Warn("Domain already exists", tag.WorkflowDomainName(row.Info.Name))
+			return &types.DomainAlreadyExistsError{
+				Message: fmt.Sprintf("Domain %v already exists", row.Info.Name),
+			}
+		}
+		db.logger.Warn("Create domain operation failed because of condition update failure on domain metadata record")
+		return nosqlplugin.NewConditionFailure("domain")
+	}
+	return err
 }
 
 // Update domain
@@ -49,7 +168,22 @@ func (db *ddb) UpdateDomain(
 	ctx context.Context,
 	row *nosqlplugin.DomainRow,
 ) error {
-	panic("TODO")
+	item, err := newDomainItem(row)
+	if err != nil {
+		return err
+	}
+	putItem, err := db.newPutTransactionItem(cadence.DomainTableName, item, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	_, err = db.executeTransaction(ctx, []*dynamodb.TransactWriteItem{
+		putItem,
+		db.newUpdateMetadataTransactionItem(row.NotificationVersion),
+	})
+	if db.IsConditionFailedError(err) {
+		return nosqlplugin.NewConditionFailure("domain")
+	}
+	return err
 }
 
 // Get one domain data, either by domainID or domainName
@@ -58,7 +192,61 @@ func (db *ddb) SelectDomain(
 	domainID *string,
 	domainName *string,
 ) (*nosqlplugin.DomainRow, error) {
-	panic("TODO")
+	if domainID != nil && domainName != nil {
+		return nil, fmt.Errorf("GetDomain operation failed.  Both ID and Name specified in request")
+	} else if domainID == nil && domainName == nil {
+		return nil, fmt.Errorf("GetDomain operation failed.  Both ID and Name are empty")
+	}
+
+	if domainID != nil {
+		name, err := db.selectDomainNameByID(ctx, *domainID)
+		if err != nil {
+			return nil, err
+		}
+		domainName = &name
+	}
+
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      db.tableName(cadence.DomainTableName),
+		Key:            domainKey(*domainName),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Item) == 0 {
+		return nil, errNotFound
+	}
+	return toDomainRow(resp.Item)
+}
+
+// selectDomainNameByID looks up the domain name using the local secondary index of domain_id
+func (db *ddb) selectDomainNameByID(ctx context.Context, domainID string) (string, error) {
+	resp, err := db.client.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.DomainTableName),
+		IndexName:              aws.String(cadence.DomainIDIndexName),
+		KeyConditionExpression: aws.String("domain_partition = :domain_partition AND domain_id = :domain_id"),
+		ExpressionAttributeValues: attributeMap{
+			":domain_partition": numberAttr(constDomainPartition),
+			":domain_id":        stringAttr(domainID),
+		},
+		ExpressionAttributeNames: map[string]*string{
+			"#name": aws.String("name"),
+		},
+		ProjectionExpression: aws.String("#name"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(resp.Items) == 0 {
+		return "", errNotFound
+	}
+	var item domainItem
+	if err := dynamodbattribute.UnmarshalMap(resp.Items[0], &item); err != nil {
+		return "", err
+	}
+	return item.Name, nil
 }
 
 // Get all domain data
@@ -67,20 +255,63 @@ func (db *ddb) SelectAllDomains(
 	pageSize int,
 	pageToken []byte,
 ) ([]*nosqlplugin.DomainRow, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.DomainTableName),
+		KeyConditionExpression: aws.String("domain_partition = :domain_partition"),
+		ExpressionAttributeValues: attributeMap{
+			":domain_partition": numberAttr(constDomainPartition),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := make([]*nosqlplugin.DomainRow, 0, len(items))
+	for _, item := range items {
+		row, err := toDomainRow(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nextPageToken, nil
 }
 
-//  Delete a domain, either by domainID or domainName
+// Delete a domain, either by domainID or domainName
 func (db *ddb) DeleteDomain(
 	ctx context.Context,
 	domainID *string,
 	domainName *string,
 ) error {
-	panic("TODO")
+	if domainName == nil && domainID == nil {
+		return fmt.Errorf("must provide either domainID or domainName")
+	}
+
+	if domainName == nil {
+		name, err := db.selectDomainNameByID(ctx, *domainID)
+		if err != nil {
+			if db.IsNotFoundError(err) {
+				return nil
+			}
+			return err
+		}
+		domainName = &name
+	}
+	return db.deleteItem(ctx, cadence.DomainTableName, domainKey(*domainName))
 }
 
 func (db *ddb) SelectDomainMetadata(
 	ctx context.Context,
 ) (int64, error) {
-	panic("TODO")
+	var item domainMetadataItem
+	err := db.getItem(ctx, cadence.DomainMetadataTableName, domainMetadataKey(), &item)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			// this error can be thrown in the very beginning,
+			// i.e. when domain_metadata is initialized
+			return 0, nil
+		}
+		return 0, err
+	}
+	return item.NotificationVersion, nil
 }
@@ -22,31 +22,371 @@ package dynamodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/common/types"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
+)
+
+type (
+	historyTreeItem struct {
+		TreeID   string `dynamodbav:"tree_id"`
+		BranchID string `dynamodbav:"branch_id"`
+		ShardID  int    `dynamodbav:"shard_id"`
+		Data     []byte `dynamodbav:"data"`
+	}
+
+	// historyTreeData is the non-significant columns of history_tree
+	historyTreeData struct {
+		Ancestors       []*types.HistoryBranchRange `json:"ancestors"`
+		CreateTimestamp time.Time                   `json:"create_timestamp"`
+		Info            string                      `json:"info"`
+	}
+
+	// historyNodeItem uses node_key(branchID#nodeID#reversedTxnID) as range key
+	// so that nodes are sorted by branchID, nodeID ASC, txnID DESC
+	// A node whose data doesn't fit into one item is split into ChunkCount items. The first one is the node item
+	// itself, the others use node_key(branchID#nodeID#reversedTxnID#chunkIndex) so they are sorted right after it.
+	historyNodeItem struct {
+		TreeID       string `dynamodbav:"tree_id"`
+		NodeKey      string `dynamodbav:"node_key"`
+		BranchID     string `dynamodbav:"branch_id"`
+		NodeID       int64  `dynamodbav:"node_id"`
+		TxnID        int64  `dynamodbav:"txn_id"`
+		Data         []byte `dynamodbav:"data"`
+		DataEncoding string `dynamodbav:"data_encoding"`
+		// ChunkCount is only set on the first item of a chunked node
+		ChunkCount int `dynamodbav:"chunk_count,omitempty"`
+		// ChunkIndex is zero for the first item of a node
+		ChunkIndex int `dynamodbav:"chunk_index,omitempty"`
+	}
+)
+
+const (
+	// nodeKeyUpperBound is greater than any digit so it can be used to bound all the nodes of a branch
+	nodeKeyUpperBound = "~"
+	// historyNodeChunkSize is the max size of the data in a single history node item,
+	// it leaves enough room under maxItemSize for the other attributes
+	historyNodeChunkSize = 350 * 1024
 )
 
+func historyNodeKey(branchID string, nodeID int64, txnID int64) string {
+	return compositeKey(branchID, paddedInt64(nodeID), paddedInt64(math.MaxInt64-txnID))
+}
+
+func historyNodeChunkKey(nodeKey string, chunkIndex int) string {
+	return compositeKey(nodeKey, paddedInt64(int64(chunkIndex)))
+}
+
+// newHistoryNodeItems splits the node row into items of at most historyNodeChunkSize bytes of data
+func newHistoryNodeItems(row *nosqlplugin.HistoryNodeRow) []*historyNodeItem {
+	txnID := aws.Int64Value(row.TxnID)
+	nodeKey := historyNodeKey(row.BranchID, row.NodeID, txnID)
+	chunkCount := (len(row.Data) + historyNodeChunkSize - 1) / historyNodeChunkSize
+	if chunkCount <= 1 {
+		return []*historyNodeItem{{
+			TreeID:       row.TreeID,
+			NodeKey:      nodeKey,
+			BranchID:     row.BranchID,
+			NodeID:       row.NodeID,
+			TxnID:        txnID,
+			Data:         row.Data,
+			DataEncoding: row.DataEncoding,
+		}}
+	}
+
+	items := make([]*historyNodeItem, 0, chunkCount)
+	for i := 0; i < chunkCount; i++ {
+		end := (i + 1) * historyNodeChunkSize
+		if end > len(row.Data) {
+			end = len(row.Data)
+		}
+		item := &historyNodeItem{
+			TreeID:     row.TreeID,
+			NodeKey:    historyNodeChunkKey(nodeKey, i),
+			BranchID:   row.BranchID,
+			NodeID:     row.NodeID,
+			TxnID:      txnID,
+			Data:       row.Data[i*historyNodeChunkSize : end],
+			ChunkIndex: i,
+		}
+		if i == 0 {
+			item.NodeKey = nodeKey
+			item.DataEncoding = row.DataEncoding
+			item.ChunkCount = chunkCount
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// toHistoryNodeRows merges the chunks of the nodes back into node rows.
+// It also returns the number of chunks that are missing from the last node, e.g. when a page ends in the middle
+// of a chunked node, the caller must read the remaining chunks. Chunks without the first item of the node are dropped.
+func toHistoryNodeRows(items []*historyNodeItem) ([]*nosqlplugin.HistoryNodeRow, int) {
+	rows := make([]*nosqlplugin.HistoryNodeRow, 0, len(items))
+	missing := 0
+	for _, item := range items {
+		if item.ChunkIndex > 0 {
+			if len(rows) == 0 || missing == 0 {
+				continue
+			}
+			last := rows[len(rows)-1]
+			last.Data = append(last.Data, item.Data...)
+			missing--
+			continue
+		}
+		txnID := item.TxnID
+		rows = append(rows, &nosqlplugin.HistoryNodeRow{
+			TreeID:       item.TreeID,
+			BranchID:     item.BranchID,
+			NodeID:       item.NodeID,
+			TxnID:        &txnID,
+			Data:         item.Data,
+			DataEncoding: item.DataEncoding,
+		})
+		missing = 0
+		if item.ChunkCount > 1 {
+			missing = item.ChunkCount - 1
+		}
+	}
+	return rows, missing
+}
+
+// historyNodeKeyPrefix returns the prefix of node_key for a node, which is less than any node_key of the node
+func historyNodeKeyPrefix(branchID string, nodeID int64) string {
+	return compositeKey(branchID, paddedInt64(nodeID))
+}
+
+func (db *ddb) newHistoryTreeItem(row *nosqlplugin.HistoryTreeRow) (*historyTreeItem, error) {
+	data, err := json.Marshal(&historyTreeData{
+		Ancestors:       row.Ancestors,
+		CreateTimestamp: row.CreateTimestamp,
+		Info:            row.Info,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &historyTreeItem{
+		TreeID:   row.TreeID,
+		BranchID: row.BranchID,
+		ShardID:  row.ShardID,
+		Data:     data,
+	}, nil
+}
+
+func toHistoryTreeRow(item attributeMap) (*nosqlplugin.HistoryTreeRow, error) {
+	var tree historyTreeItem
+	if err := dynamodbattribute.UnmarshalMap(item, &tree); err != nil {
+		return nil, err
+	}
+	var data historyTreeData
+	if err := json.Unmarshal(tree.Data, &data); err != nil {
+		return nil, err
+	}
+	ancestors := data.Ancestors
+	if len(ancestors) > 0 {
+		// sort ans based onf EndNodeID so that we can set BeginNodeID
+		sort.Slice(ancestors, func(i, j int) bool { return ancestors[i].EndNodeID < ancestors[j].EndNodeID })
+		ancestors[0].BeginNodeID = int64(1)
+		for i := 1; i < len(ancestors); i++ {
+			ancestors[i].BeginNodeID = ancestors[i-1].EndNodeID
+		}
+	}
+	return &nosqlplugin.HistoryTreeRow{
+		ShardID:         tree.ShardID,
+		TreeID:          tree.TreeID,
+		BranchID:        tree.BranchID,
+		Ancestors:       ancestors,
+		CreateTimestamp: data.CreateTimestamp,
+		Info:            data.Info,
+	}, nil
+}
+
 // InsertIntoHistoryTreeAndNode inserts one or two rows: tree row and node row(at least one of them)
 func (db *ddb) InsertIntoHistoryTreeAndNode(ctx context.Context, treeRow *nosqlplugin.HistoryTreeRow, nodeRow *nosqlplugin.HistoryNodeRow) error {
-	panic("TODO")
+	if treeRow == nil && nodeRow == nil {
+		return fmt.Errorf("require at least a tree row or a node row to insert")
+	}
+
+	var items []*dynamodb.TransactWriteItem
+	if treeRow != nil {
+		treeItem, err := db.newHistoryTreeItem(treeRow)
+		if err != nil {
+			return err
+		}
+		item, err := db.newPutTransactionItem(cadence.HistoryTreeTableName, treeItem, "", nil, nil)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+	if nodeRow != nil {
+		for _, nodeItem := range newHistoryNodeItems(nodeRow) {
+			item, err := db.newPutTransactionItem(cadence.HistoryNodeTableName, nodeItem, "", nil, nil)
+			if err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+	}
+
+	if len(items) == 1 {
+		// single item write doesn't need a transaction
+		put := items[0].Put
+		_, err := db.client.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+			TableName: put.TableName,
+			Item:      put.Item,
+		})
+		return err
+	}
+	if err := checkTransactionSize(items); err != nil {
+		return err
+	}
+	_, err := db.executeTransaction(ctx, items)
+	return err
 }
 
 // SelectFromHistoryNode read nodes based on a filter
 func (db *ddb) SelectFromHistoryNode(ctx context.Context, filter *nosqlplugin.HistoryNodeFilter) ([]*nosqlplugin.HistoryNodeRow, []byte, error) {
-	panic("TODO")
+	// BETWEEN is inclusive, but the upper bound is a prefix so that it is less than any node_key of MaxNodeID
+	items, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.HistoryNodeTableName),
+		KeyConditionExpression: aws.String("tree_id = :tree_id AND node_key BETWEEN :min_key AND :max_key"),
+		ExpressionAttributeValues: attributeMap{
+			":tree_id": stringAttr(filter.TreeID),
+			":min_key": stringAttr(historyNodeKeyPrefix(filter.BranchID, filter.MinNodeID)),
+			":max_key": stringAttr(historyNodeKeyPrefix(filter.BranchID, filter.MaxNodeID)),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, filter.PageSize, filter.NextPageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes := make([]*historyNodeItem, 0, len(items))
+	for _, item := range items {
+		node := &historyNodeItem{}
+		if err := dynamodbattribute.UnmarshalMap(item, node); err != nil {
+			return nil, nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	rows, missing := toHistoryNodeRows(nodes)
+	if missing == 0 {
+		return rows, nextPageToken, nil
+	}
+
+	// the page ends in the middle of a chunked node, read the rest of it and continue the next page after it
+	last := nodes[len(nodes)-1]
+	nodeKey := historyNodeKey(last.BranchID, last.NodeID, last.TxnID)
+	lastChunkIndex := last.ChunkIndex + missing
+	chunks, err := db.queryAll(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.HistoryNodeTableName),
+		KeyConditionExpression: aws.String("tree_id = :tree_id AND node_key BETWEEN :min_key AND :max_key"),
+		ExpressionAttributeValues: attributeMap{
+			":tree_id": stringAttr(filter.TreeID),
+			":min_key": stringAttr(historyNodeChunkKey(nodeKey, last.ChunkIndex+1)),
+			":max_key": stringAttr(historyNodeChunkKey(nodeKey, lastChunkIndex)),
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(chunks) != missing {
+		return nil, nil, fmt.Errorf("history node %v has %v chunks, expected %v", nodeKey, len(chunks), missing)
+	}
+	for _, item := range chunks {
+		var chunk historyNodeItem
+		if err := dynamodbattribute.UnmarshalMap(item, &chunk); err != nil {
+			return nil, nil, err
+		}
+		rows[len(rows)-1].Data = append(rows[len(rows)-1].Data, chunk.Data...)
+	}
+	if len(nextPageToken) > 0 {
+		nextPageToken, err = serializePageToken(attributeMap{
+			"tree_id":  stringAttr(filter.TreeID),
+			"node_key": stringAttr(historyNodeChunkKey(nodeKey, lastChunkIndex)),
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return rows, nextPageToken, nil
 }
 
 // DeleteFromHistoryTreeAndNode delete a branch record, and a list of ranges of nodes.
+// NOTE: unlike Cassandra's batch, this is not atomic. The branch record is deleted last so that
+// a failed deletion can be retried from the branch record.
 func (db *ddb) DeleteFromHistoryTreeAndNode(ctx context.Context, treeFilter *nosqlplugin.HistoryTreeFilter, nodeFilters []*nosqlplugin.HistoryNodeFilter) error {
-	panic("TODO")
+	for _, nodeFilter := range nodeFilters {
+		_, err := db.deleteByQuery(ctx, cadence.HistoryNodeTableName, &dynamodb.QueryInput{
+			KeyConditionExpression: aws.String("tree_id = :tree_id AND node_key BETWEEN :min_key AND :max_key"),
+			ExpressionAttributeValues: attributeMap{
+				":tree_id": stringAttr(nodeFilter.TreeID),
+				":min_key": stringAttr(historyNodeKeyPrefix(nodeFilter.BranchID, nodeFilter.MinNodeID)),
+				":max_key": stringAttr(compositeKey(nodeFilter.BranchID, nodeKeyUpperBound)),
+			},
+		}, "tree_id", "node_key")
+		if err != nil {
+			return err
+		}
+	}
+	return db.deleteItem(ctx, cadence.HistoryTreeTableName, attributeMap{
+		"tree_id":   stringAttr(treeFilter.TreeID),
+		"branch_id": stringAttr(aws.StringValue(treeFilter.BranchID)),
+	})
 }
 
 // SelectAllHistoryTrees will return all tree branches with pagination
 func (db *ddb) SelectAllHistoryTrees(ctx context.Context, nextPageToken []byte, pageSize int) ([]*nosqlplugin.HistoryTreeRow, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.scanPage(ctx, &dynamodb.ScanInput{
+		TableName: db.tableName(cadence.HistoryTreeTableName),
+	}, pageSize, nextPageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := make([]*nosqlplugin.HistoryTreeRow, 0, len(items))
+	for _, item := range items {
+		row, err := toHistoryTreeRow(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nextPageToken, nil
 }
 
 // SelectFromHistoryTree read branch records for a tree
 func (db *ddb) SelectFromHistoryTree(ctx context.Context, filter *nosqlplugin.HistoryTreeFilter) ([]*nosqlplugin.HistoryTreeRow, error) {
-	panic("TODO")
+	items, err := db.queryAll(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.HistoryTreeTableName),
+		KeyConditionExpression: aws.String("tree_id = :tree_id"),
+		ExpressionAttributeValues: attributeMap{
+			":tree_id": stringAttr(filter.TreeID),
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*nosqlplugin.HistoryTreeRow, 0, len(items))
+	for _, item := range items {
+		row, err := toHistoryTreeRow(item)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
 }
@@ -23,17 +23,72 @@ package dynamodb
 import (
 	"context"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
 	"github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
 )
 
-//Insert message into queue, return error if failed or already exists
+type queueMessageItem struct {
+	QueueType persistence.QueueType `dynamodbav:"queue_type"`
+	MessageID int64                 `dynamodbav:"message_id"`
+	Payload   []byte                `dynamodbav:"payload"`
+}
+
+type queueMetadataItem struct {
+	QueueType        persistence.QueueType `dynamodbav:"queue_type"`
+	Version          int64                 `dynamodbav:"version"`
+	ClusterAckLevels map[string]int64      `dynamodbav:"cluster_ack_levels"`
+}
+
+func queueMessageKey(queueType persistence.QueueType, messageID int64) attributeMap {
+	return attributeMap{
+		"queue_type": numberAttr(int64(queueType)),
+		"message_id": numberAttr(messageID),
+	}
+}
+
+func queueMetadataKey(queueType persistence.QueueType) attributeMap {
+	return attributeMap{
+		"queue_type": numberAttr(int64(queueType)),
+	}
+}
+
+func toQueueMessageRows(items []attributeMap) ([]nosqlplugin.QueueMessageRow, error) {
+	rows := make([]nosqlplugin.QueueMessageRow, 0, len(items))
+	for _, item := range items {
+		var message queueMessageItem
+		if err := dynamodbattribute.UnmarshalMap(item, &message); err != nil {
+			return nil, err
+		}
+		rows = append(rows, nosqlplugin.QueueMessageRow{
+			QueueType: message.QueueType,
+			ID:        message.MessageID,
+			Payload:   message.Payload,
+		})
+	}
+	return rows, nil
+}
+
+// Insert message into queue, return error if failed or already exists
 // Return ConditionFailure if the condition doesn't meet
 func (db *ddb) InsertIntoQueue(
 	ctx context.Context,
 	row *nosqlplugin.QueueMessageRow,
 ) error {
-	panic("TODO")
+	item := queueMessageItem{
+		QueueType: row.QueueType,
+		MessageID: row.ID,
+		Payload:   row.Payload,
+	}
+	err := db.putItem(ctx, cadence.QueueMessageTableName, item, "attribute_not_exists(message_id)", nil, nil)
+	if db.IsConditionFailedError(err) {
+		return nosqlplugin.NewConditionFailure("queue")
+	}
+	return err
 }
 
 // Get the ID of last message inserted into the queue
@@ -41,7 +96,28 @@ func (db *ddb) SelectLastEnqueuedMessageID(
 	ctx context.Context,
 	queueType persistence.QueueType,
 ) (int64, error) {
-	panic("TODO")
+	resp, err := db.client.QueryWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.QueueMessageTableName),
+		KeyConditionExpression: aws.String("queue_type = :queue_type"),
+		ExpressionAttributeValues: attributeMap{
+			":queue_type": numberAttr(int64(queueType)),
+		},
+		ProjectionExpression: aws.String("message_id"),
+		ScanIndexForward:     aws.Bool(false),
+		Limit:                aws.Int64(1),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(resp.Items) == 0 {
+		return 0, errNotFound
+	}
+	var item queueMessageItem
+	if err := dynamodbattribute.UnmarshalMap(resp.Items[0], &item); err != nil {
+		return 0, err
+	}
+	return item.MessageID, nil
 }
 
 // Read queue messages starting from the exclusiveBeginMessageID
@@ -51,7 +127,27 @@ func (db *ddb) SelectMessagesFrom(
 	exclusiveBeginMessageID int64,
 	maxRows int,
 ) ([]*nosqlplugin.QueueMessageRow, error) {
-	panic("TODO")
+	items, _, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.QueueMessageTableName),
+		KeyConditionExpression: aws.String("queue_type = :queue_type AND message_id > :begin"),
+		ExpressionAttributeValues: attributeMap{
+			":queue_type": numberAttr(int64(queueType)),
+			":begin":      numberAttr(exclusiveBeginMessageID),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, maxRows, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := toQueueMessageRows(items)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*nosqlplugin.QueueMessageRow, 0, len(rows))
+	for i := range rows {
+		result = append(result, &rows[i])
+	}
+	return result, nil
 }
 
 // Read queue message starting from exclusiveBeginMessageID int64, inclusiveEndMessageID int64
@@ -59,7 +155,27 @@ func (db *ddb) SelectMessagesBetween(
 	ctx context.Context,
 	request nosqlplugin.SelectMessagesBetweenRequest,
 ) (*nosqlplugin.SelectMessagesBetweenResponse, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.QueueMessageTableName),
+		KeyConditionExpression: aws.String("queue_type = :queue_type AND message_id BETWEEN :begin AND :end"),
+		ExpressionAttributeValues: attributeMap{
+			":queue_type": numberAttr(int64(request.QueueType)),
+			":begin":      numberAttr(request.ExclusiveBeginMessageID + 1),
+			":end":        numberAttr(request.InclusiveEndMessageID),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, request.PageSize, request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := toQueueMessageRows(items)
+	if err != nil {
+		return nil, err
+	}
+	return &nosqlplugin.SelectMessagesBetweenResponse{
+		Rows:          rows,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
 // Delete all messages before exclusiveBeginMessageID
@@ -68,7 +184,14 @@ func (db *ddb) DeleteMessagesBefore(
 	queueType persistence.QueueType,
 	exclusiveBeginMessageID int64,
 ) error {
-	panic("TODO")
+	_, err := db.deleteByQuery(ctx, cadence.QueueMessageTableName, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("queue_type = :queue_type AND message_id < :begin"),
+		ExpressionAttributeValues: attributeMap{
+			":queue_type": numberAttr(int64(queueType)),
+			":begin":      numberAttr(exclusiveBeginMessageID),
+		},
+	}, "queue_type", "message_id")
+	return err
 }
 
 // Delete all messages in a range between exclusiveBeginMessageID and inclusiveEndMessageID
@@ -78,7 +201,15 @@ func (db *ddb) DeleteMessagesInRange(
 	exclusiveBeginMessageID int64,
 	inclusiveEndMessageID int64,
 ) error {
-	panic("TODO")
+	_, err := db.deleteByQuery(ctx, cadence.QueueMessageTableName, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("queue_type = :queue_type AND message_id BETWEEN :begin AND :end"),
+		ExpressionAttributeValues: attributeMap{
+			":queue_type": numberAttr(int64(queueType)),
+			":begin":      numberAttr(exclusiveBeginMessageID + 1),
+			":end":        numberAttr(inclusiveEndMessageID),
+		},
+	}, "queue_type", "message_id")
+	return err
 }
 
 // Delete one message
@@ -87,7 +218,7 @@ func (db *ddb) DeleteMessage(
 	queueType persistence.QueueType,
 	messageID int64,
 ) error {
-	panic("TODO")
+	return db.deleteItem(ctx, cadence.QueueMessageTableName, queueMessageKey(queueType, messageID))
 }
 
 // Insert an empty metadata row, starting from a version
@@ -96,7 +227,17 @@ func (db *ddb) InsertQueueMetadata(
 	queueType persistence.QueueType,
 	version int64,
 ) error {
-	panic("TODO")
+	item := queueMetadataItem{
+		QueueType:        queueType,
+		Version:          version,
+		ClusterAckLevels: map[string]int64{},
+	}
+	err := db.putItem(ctx, cadence.QueueMetadataTableName, item, "attribute_not_exists(queue_type)", nil, nil)
+	if db.IsConditionFailedError(err) {
+		// it's ok if the item exists already.
+		return nil
+	}
+	return err
 }
 
 // **Conditionally** update a queue metadata row, if current version is matched(meaning current == row.Version - 1),
@@ -106,7 +247,18 @@ func (db *ddb) UpdateQueueMetadataCas(
 	ctx context.Context,
 	row nosqlplugin.QueueMetadataRow,
 ) error {
-	panic("TODO")
+	item := queueMetadataItem{
+		QueueType:        row.QueueType,
+		Version:          row.Version,
+		ClusterAckLevels: row.ClusterAckLevels,
+	}
+	err := db.putItem(ctx, cadence.QueueMetadataTableName, item, "version = :previous_version", nil, attributeMap{
+		":previous_version": numberAttr(row.Version - 1),
+	})
+	if db.IsConditionFailedError(err) {
+		return nosqlplugin.NewConditionFailure("queue")
+	}
+	return err
 }
 
 // Read a QueueMetadata
@@ -114,12 +266,37 @@ func (db *ddb) SelectQueueMetadata(
 	ctx context.Context,
 	queueType persistence.QueueType,
 ) (*nosqlplugin.QueueMetadataRow, error) {
-	panic("TODO")
+	var item queueMetadataItem
+	if err := db.getItem(ctx, cadence.QueueMetadataTableName, queueMetadataKey(queueType), &item); err != nil {
+		return nil, err
+	}
+	// if record exist but ackLevels is empty, we initialize the map
+	if item.ClusterAckLevels == nil {
+		item.ClusterAckLevels = make(map[string]int64)
+	}
+	return &nosqlplugin.QueueMetadataRow{
+		QueueType:        queueType,
+		ClusterAckLevels: item.ClusterAckLevels,
+		Version:          item.Version,
+	}, nil
 }
 
 func (db *ddb) GetQueueSize(
 	ctx context.Context,
 	queueType persistence.QueueType,
 ) (int64, error) {
-	panic("TODO")
+	var count int64
+	err := db.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.QueueMessageTableName),
+		KeyConditionExpression: aws.String("queue_type = :queue_type"),
+		ExpressionAttributeValues: attributeMap{
+			":queue_type": numberAttr(int64(queueType)),
+		},
+		Select:         aws.String(dynamodb.SelectCount),
+		ConsistentRead: aws.Bool(true),
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		count += aws.Int64Value(page.Count)
+		return true
+	})
+	return count, err
 }
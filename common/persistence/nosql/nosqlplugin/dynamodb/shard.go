@@ -22,29 +22,133 @@ package dynamodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
 
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
 )
 
+// shardItem is the item of shard table. range_id is the only significant column, the rest of the shard
+// is stored as a JSON blob.
+type shardItem struct {
+	ShardID int    `dynamodbav:"shard_id"`
+	RangeID int64  `dynamodbav:"range_id"`
+	Data    []byte `dynamodbav:"data"`
+}
+
+func shardKey(shardID int) attributeMap {
+	return attributeMap{
+		"shard_id": numberAttr(int64(shardID)),
+	}
+}
+
+func newShardItem(row *nosqlplugin.ShardRow) (*shardItem, error) {
+	shard := *row
+	shard.UpdatedAt = time.Now()
+	data, err := json.Marshal(&shard)
+	if err != nil {
+		return nil, err
+	}
+	return &shardItem{
+		ShardID: row.ShardID,
+		RangeID: row.RangeID,
+		Data:    data,
+	}, nil
+}
+
 // InsertShard creates a new shard, return error is there is any.
 // Return ShardOperationConditionFailure if the condition doesn't meet
 func (db *ddb) InsertShard(ctx context.Context, row *nosqlplugin.ShardRow) error {
-	panic("TODO")
+	item, err := newShardItem(row)
+	if err != nil {
+		return err
+	}
+	err = db.putItem(ctx, cadence.ShardTableName, item, "attribute_not_exists(shard_id)", nil, nil)
+	if db.IsConditionFailedError(err) {
+		return db.getConflictedShard(ctx, row.ShardID)
+	}
+	return err
 }
 
 // SelectShard gets a shard
 func (db *ddb) SelectShard(ctx context.Context, shardID int, currentClusterName string) (int64, *nosqlplugin.ShardRow, error) {
-	panic("TODO")
+	var item shardItem
+	if err := db.getItem(ctx, cadence.ShardTableName, shardKey(shardID), &item); err != nil {
+		return 0, nil, err
+	}
+	shard := &nosqlplugin.ShardRow{}
+	if err := json.Unmarshal(item.Data, shard); err != nil {
+		return 0, nil, err
+	}
+	if shard.ClusterTransferAckLevel == nil {
+		shard.ClusterTransferAckLevel = map[string]int64{
+			currentClusterName: shard.TransferAckLevel,
+		}
+	}
+	if shard.ClusterTimerAckLevel == nil {
+		shard.ClusterTimerAckLevel = map[string]time.Time{
+			currentClusterName: shard.TimerAckLevel,
+		}
+	}
+	if shard.ClusterReplicationLevel == nil {
+		shard.ClusterReplicationLevel = make(map[string]int64)
+	}
+	if shard.ReplicationDLQAckLevel == nil {
+		shard.ReplicationDLQAckLevel = make(map[string]int64)
+	}
+	return item.RangeID, shard, nil
 }
 
 // UpdateRangeID updates the rangeID, return error is there is any
 // Return ShardOperationConditionFailure if the condition doesn't meet
 func (db *ddb) UpdateRangeID(ctx context.Context, shardID int, rangeID int64, previousRangeID int64) error {
-	panic("TODO")
+	_, err := db.client.UpdateItemWithContext(ctx, &dynamodb.UpdateItemInput{
+		TableName:           db.tableName(cadence.ShardTableName),
+		Key:                 shardKey(shardID),
+		UpdateExpression:    aws.String("SET range_id = :range_id"),
+		ConditionExpression: aws.String("range_id = :previous_range_id"),
+		ExpressionAttributeValues: attributeMap{
+			":range_id":          numberAttr(rangeID),
+			":previous_range_id": numberAttr(previousRangeID),
+		},
+	})
+	if db.IsConditionFailedError(err) {
+		return db.getConflictedShard(ctx, shardID)
+	}
+	return err
 }
 
 // UpdateShard updates a shard, return error is there is any.
 // Return ShardOperationConditionFailure if the condition doesn't meet
 func (db *ddb) UpdateShard(ctx context.Context, row *nosqlplugin.ShardRow, previousRangeID int64) error {
-	panic("TODO")
+	item, err := newShardItem(row)
+	if err != nil {
+		return err
+	}
+	err = db.putItem(ctx, cadence.ShardTableName, item, "range_id = :previous_range_id", nil, attributeMap{
+		":previous_range_id": numberAttr(previousRangeID),
+	})
+	if db.IsConditionFailedError(err) {
+		return db.getConflictedShard(ctx, row.ShardID)
+	}
+	return err
+}
+
+// getConflictedShard reads the current shard after a conditional write fails,
+// because DynamoDB doesn't return the previous item on a failed conditional write
+func (db *ddb) getConflictedShard(ctx context.Context, shardID int) error {
+	var item shardItem
+	err := db.getItem(ctx, cadence.ShardTableName, shardKey(shardID), &item)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	return &nosqlplugin.ShardOperationConditionFailure{
+		RangeID: item.RangeID,
+		Details: fmt.Sprintf("shard_id=%v, range_id=%v", shardID, item.RangeID),
+	}
 }
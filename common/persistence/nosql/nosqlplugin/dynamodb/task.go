@@ -22,20 +22,123 @@ package dynamodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
+)
+
+const (
+	initialRangeID  = 1 // Id of the first range of a new task list
+	initialAckLevel = 0
+)
+
+type (
+	// taskListItem stores the TaskListRow as a JSON blob, range_id is the only significant column
+	taskListItem struct {
+		TaskListKey string `dynamodbav:"task_list_key"`
+		RangeID     int64  `dynamodbav:"range_id"`
+		Data        []byte `dynamodbav:"data"`
+		// Expiry is the TTL in unix seconds, zero means never expire
+		Expiry int64 `dynamodbav:"expiry,omitempty"`
+	}
+
+	// taskItem stores the TaskRow as a JSON blob
+	taskItem struct {
+		TaskListKey string `dynamodbav:"task_list_key"`
+		TaskID      int64  `dynamodbav:"task_id"`
+		Data        []byte `dynamodbav:"data"`
+		// Expiry is the TTL in unix seconds, zero means never expire
+		Expiry int64 `dynamodbav:"expiry,omitempty"`
+	}
 )
 
+func taskListKey(filter *nosqlplugin.TaskListFilter) string {
+	return compositeKey(filter.DomainID, filter.TaskListName, strconv.Itoa(filter.TaskListType))
+}
+
+func taskListItemKey(filter *nosqlplugin.TaskListFilter) attributeMap {
+	return attributeMap{
+		"task_list_key": stringAttr(taskListKey(filter)),
+	}
+}
+
+func toTaskListFilter(row *nosqlplugin.TaskListRow) *nosqlplugin.TaskListFilter {
+	return &nosqlplugin.TaskListFilter{
+		DomainID:     row.DomainID,
+		TaskListName: row.TaskListName,
+		TaskListType: row.TaskListType,
+	}
+}
+
+func newTaskListItem(row *nosqlplugin.TaskListRow, ttlSeconds int64) (*taskListItem, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	item := &taskListItem{
+		TaskListKey: taskListKey(toTaskListFilter(row)),
+		RangeID:     row.RangeID,
+		Data:        data,
+	}
+	if ttlSeconds > 0 {
+		item.Expiry = time.Now().Unix() + ttlSeconds
+	}
+	return item, nil
+}
+
+// notExpiredCondition is for filtering out the items that are expired but not yet deleted by DynamoDB,
+// because DynamoDB deletes expired items in the background, typically within 48 hours.
+const notExpiredCondition = "(attribute_not_exists(expiry) OR expiry > :now)"
+
+func nowAttr() *dynamodb.AttributeValue {
+	return numberAttr(time.Now().Unix())
+}
+
 // SelectTaskList returns a single tasklist row.
 // Return IsNotFoundError if the row doesn't exist
 func (db *ddb) SelectTaskList(ctx context.Context, filter *nosqlplugin.TaskListFilter) (*nosqlplugin.TaskListRow, error) {
-	panic("TODO")
+	var item taskListItem
+	if err := db.getItem(ctx, cadence.TaskListTableName, taskListItemKey(filter), &item); err != nil {
+		return nil, err
+	}
+	if item.Expiry > 0 && item.Expiry <= time.Now().Unix() {
+		return nil, errNotFound
+	}
+	row := &nosqlplugin.TaskListRow{}
+	if err := json.Unmarshal(item.Data, row); err != nil {
+		return nil, err
+	}
+	row.RangeID = item.RangeID
+	return row, nil
 }
 
 // InsertTaskList insert a single tasklist row
 // Return IsConditionFailedError if the row already exists, and also the existing row
 func (db *ddb) InsertTaskList(ctx context.Context, row *nosqlplugin.TaskListRow) error {
-	panic("TODO")
+	taskList := *row
+	taskList.RangeID = initialRangeID
+	taskList.AckLevel = initialAckLevel
+	item, err := newTaskListItem(&taskList, 0)
+	if err != nil {
+		return err
+	}
+	err = db.putItem(ctx, cadence.TaskListTableName, item,
+		"attribute_not_exists(task_list_key) OR expiry <= :now", nil, attributeMap{
+			":now": nowAttr(),
+		})
+	if db.IsConditionFailedError(err) {
+		return db.getTaskListConditionFailure(ctx, toTaskListFilter(row))
+	}
+	return err
 }
 
 // UpdateTaskList updates a single tasklist row
@@ -45,7 +148,7 @@ func (db *ddb) UpdateTaskList(
 	row *nosqlplugin.TaskListRow,
 	previousRangeID int64,
 ) error {
-	panic("TODO")
+	return db.updateTaskList(ctx, 0, row, previousRangeID)
 }
 
 // UpdateTaskList updates a single tasklist row, and set an TTL on the record
@@ -58,40 +161,195 @@ func (db *ddb) UpdateTaskListWithTTL(
 	row *nosqlplugin.TaskListRow,
 	previousRangeID int64,
 ) error {
-	panic("TODO")
+	taskList := *row
+	taskList.LastUpdatedTime = time.Now()
+	return db.updateTaskList(ctx, ttlSeconds, &taskList, previousRangeID)
+}
+
+func (db *ddb) updateTaskList(
+	ctx context.Context,
+	ttlSeconds int64,
+	row *nosqlplugin.TaskListRow,
+	previousRangeID int64,
+) error {
+	item, err := newTaskListItem(row, ttlSeconds)
+	if err != nil {
+		return err
+	}
+	err = db.putItem(ctx, cadence.TaskListTableName, item, "range_id = :previous_range_id", nil, attributeMap{
+		":previous_range_id": numberAttr(previousRangeID),
+	})
+	if db.IsConditionFailedError(err) {
+		return db.getTaskListConditionFailure(ctx, toTaskListFilter(row))
+	}
+	return err
 }
 
 // ListTaskList returns all tasklists.
 // Noop if TTL is already implemented in other methods
+// NOTE: it's a paginated full table scan, the page token is the LastEvaluatedKey of the scan
 func (db *ddb) ListTaskList(ctx context.Context, pageSize int, nextPageToken []byte) (*nosqlplugin.ListTaskListResult, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.scanPage(ctx, &dynamodb.ScanInput{
+		TableName:        db.tableName(cadence.TaskListTableName),
+		FilterExpression: aws.String(notExpiredCondition),
+		ExpressionAttributeValues: attributeMap{
+			":now": nowAttr(),
+		},
+	}, pageSize, nextPageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &nosqlplugin.ListTaskListResult{
+		TaskLists:     make([]*nosqlplugin.TaskListRow, 0, len(items)),
+		NextPageToken: nextPageToken,
+	}
+	for _, item := range items {
+		var taskList taskListItem
+		if err := dynamodbattribute.UnmarshalMap(item, &taskList); err != nil {
+			return nil, err
+		}
+		row := &nosqlplugin.TaskListRow{}
+		if err := json.Unmarshal(taskList.Data, row); err != nil {
+			return nil, err
+		}
+		row.RangeID = taskList.RangeID
+		result.TaskLists = append(result.TaskLists, row)
+	}
+	return result, nil
 }
 
 // DeleteTaskList deletes a single tasklist row
 // Return TaskOperationConditionFailure if the condition doesn't meet
 func (db *ddb) DeleteTaskList(ctx context.Context, filter *nosqlplugin.TaskListFilter, previousRangeID int64) error {
-	panic("TODO")
+	_, err := db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:           db.tableName(cadence.TaskListTableName),
+		Key:                 taskListItemKey(filter),
+		ConditionExpression: aws.String("range_id = :previous_range_id"),
+		ExpressionAttributeValues: attributeMap{
+			":previous_range_id": numberAttr(previousRangeID),
+		},
+	})
+	if db.IsConditionFailedError(err) {
+		return db.getTaskListConditionFailure(ctx, filter)
+	}
+	return err
 }
 
 // InsertTasks inserts a batch of tasks
 // Return TaskOperationConditionFailure if the condition doesn't meet
+// NOTE: the batch is written in a single transaction together with the rangeID check of the tasklist, so a batch
+// can contain at most maxTransactionItems-1 tasks(i.e. matching.maxTaskBatchSize must be less than 100).
+// A larger batch fails with TransactionSizeLimitError and nothing is written.
 func (db *ddb) InsertTasks(
 	ctx context.Context,
 	tasksToInsert []*nosqlplugin.TaskRowForInsert,
 	tasklistCondition *nosqlplugin.TaskListRow,
 ) error {
-	panic("TODO")
+	filter := toTaskListFilter(tasklistCondition)
+	key := taskListKey(filter)
+
+	items := make([]*dynamodb.TransactWriteItem, 0, len(tasksToInsert)+1)
+	for _, task := range tasksToInsert {
+		data, err := json.Marshal(&task.TaskRow)
+		if err != nil {
+			return err
+		}
+		row := &taskItem{
+			TaskListKey: key,
+			TaskID:      task.TaskID,
+			Data:        data,
+		}
+		if task.TTLSeconds > 0 {
+			row.Expiry = time.Now().Unix() + int64(task.TTLSeconds)
+		}
+		item, err := db.newPutTransactionItem(cadence.TaskTableName, row, "", nil, nil)
+		if err != nil {
+			return err
+		}
+		items = append(items, item)
+	}
+	items = append(items, &dynamodb.TransactWriteItem{
+		ConditionCheck: &dynamodb.ConditionCheck{
+			TableName:           db.tableName(cadence.TaskListTableName),
+			Key:                 taskListItemKey(filter),
+			ConditionExpression: aws.String("range_id = :range_id"),
+			ExpressionAttributeValues: attributeMap{
+				":range_id": numberAttr(tasklistCondition.RangeID),
+			},
+		},
+	})
+	if err := checkTransactionSize(items); err != nil {
+		return err
+	}
+
+	_, err := db.executeTransaction(ctx, items)
+	if db.IsConditionFailedError(err) {
+		return db.getTaskListConditionFailure(ctx, filter)
+	}
+	return err
 }
 
 // SelectTasks return tasks that associated to a tasklist
 func (db *ddb) SelectTasks(ctx context.Context, filter *nosqlplugin.TasksFilter) ([]*nosqlplugin.TaskRow, error) {
-	panic("TODO")
+	items, _, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.TaskTableName),
+		KeyConditionExpression: aws.String("task_list_key = :task_list_key AND task_id BETWEEN :min_task_id AND :max_task_id"),
+		FilterExpression:       aws.String(notExpiredCondition),
+		ExpressionAttributeValues: attributeMap{
+			":task_list_key": stringAttr(taskListKey(&filter.TaskListFilter)),
+			":min_task_id":   numberAttr(filter.MinTaskID + 1),
+			":max_task_id":   numberAttr(filter.MaxTaskID),
+			":now":           nowAttr(),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, filter.BatchSize, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*nosqlplugin.TaskRow, 0, len(items))
+	for _, item := range items {
+		var task taskItem
+		if err := dynamodbattribute.UnmarshalMap(item, &task); err != nil {
+			return nil, err
+		}
+		row := &nosqlplugin.TaskRow{}
+		if err := json.Unmarshal(task.Data, row); err != nil {
+			return nil, err
+		}
+		row.TaskID = task.TaskID
+		response = append(response, row)
+	}
+	return response, nil
 }
 
 // DeleteTask delete a batch tasks that taskIDs less than the row
 // If TTL is not implemented, then should also return the number of rows deleted, otherwise persistence.UnknownNumRowsAffected
 // NOTE: This API ignores the `BatchSize` request parameter i.e. either all tasks leq the task_id will be deleted or an error will
-// be returned to the caller, because rowsDeleted is not supported by Cassandra
+// be returned to the caller, because rowsDeleted is not supported with TTL
 func (db *ddb) RangeDeleteTasks(ctx context.Context, filter *nosqlplugin.TasksFilter) (rowsDeleted int, err error) {
-	panic("TODO")
+	_, err = db.deleteByQuery(ctx, cadence.TaskTableName, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("task_list_key = :task_list_key AND task_id BETWEEN :min_task_id AND :max_task_id"),
+		ExpressionAttributeValues: attributeMap{
+			":task_list_key": stringAttr(taskListKey(&filter.TaskListFilter)),
+			":min_task_id":   numberAttr(filter.MinTaskID + 1),
+			":max_task_id":   numberAttr(filter.MaxTaskID),
+		},
+	}, "task_list_key", "task_id")
+	return p.UnknownNumRowsAffected, err
+}
+
+// getTaskListConditionFailure reads the current tasklist after a conditional write fails,
+// because DynamoDB doesn't return the previous item on a failed conditional write
+func (db *ddb) getTaskListConditionFailure(ctx context.Context, filter *nosqlplugin.TaskListFilter) error {
+	var item taskListItem
+	err := db.getItem(ctx, cadence.TaskListTableName, taskListItemKey(filter), &item)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	return &nosqlplugin.TaskOperationConditionFailure{
+		RangeID: item.RangeID,
+		Details: fmt.Sprintf("task_list_key=%v, range_id=%v", taskListKey(filter), item.RangeID),
+	}
 }
@@ -0,0 +1,115 @@
+// Copyright (c) 2017 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	p "github.com/uber/cadence/common/persistence"
+	persistencetests "github.com/uber/cadence/common/persistence/persistence-tests"
+	"github.com/uber/cadence/common/types"
+)
+
+// maxTasksPerTransaction is the max number of tasks in one InsertTasks transaction,
+// which also contains the rangeID check of the tasklist
+const maxTasksPerTransaction = 99
+
+type transactionLimitSuite struct {
+	persistencetests.TestBase
+	*require.Assertions
+}
+
+func TestDynamoDBTransactionLimit(t *testing.T) {
+	s := new(transactionLimitSuite)
+	s.TestBase = NewTestBaseWithDynamoDB()
+	s.TestBase.Setup()
+	suite.Run(t, s)
+}
+
+func (s *transactionLimitSuite) SetupTest() {
+	s.Assertions = require.New(s.T())
+}
+
+func (s *transactionLimitSuite) TearDownSuite() {
+	s.TearDownWorkflowStore()
+}
+
+func (s *transactionLimitSuite) TestCreateTasksOverLimit() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	domainID := uuid.New()
+	taskList := "transaction-limit-tasklist"
+	leaseResp, err := s.TaskMgr.LeaseTaskList(ctx, &p.LeaseTaskListRequest{
+		DomainID: domainID,
+		TaskList: taskList,
+		TaskType: p.TaskListTypeActivity,
+	})
+	s.NoError(err)
+
+	_, err = s.TaskMgr.CreateTasks(ctx, &p.CreateTasksRequest{
+		TaskListInfo: leaseResp.TaskListInfo,
+		Tasks:        s.newTasks(domainID, maxTasksPerTransaction+1),
+	})
+	s.IsType(&p.TransactionSizeLimitError{}, err)
+	resp, err := s.GetTasks(ctx, domainID, taskList, p.TaskListTypeActivity, 2*maxTasksPerTransaction)
+	s.NoError(err)
+	s.Empty(resp.Tasks, "no task should be written when the batch is rejected")
+
+	// the largest batch takes exactly 100 transaction items, which requires a DynamoDB(Local) that supports 100 items
+	_, err = s.TaskMgr.CreateTasks(ctx, &p.CreateTasksRequest{
+		TaskListInfo: leaseResp.TaskListInfo,
+		Tasks:        s.newTasks(domainID, maxTasksPerTransaction),
+	})
+	s.NoError(err)
+	resp, err = s.GetTasks(ctx, domainID, taskList, p.TaskListTypeActivity, 2*maxTasksPerTransaction)
+	s.NoError(err)
+	s.Len(resp.Tasks, maxTasksPerTransaction)
+}
+
+func (s *transactionLimitSuite) newTasks(domainID string, count int) []*p.CreateTaskInfo {
+	tasks := make([]*p.CreateTaskInfo, 0, count)
+	for i := 0; i < count; i++ {
+		execution := types.WorkflowExecution{
+			WorkflowID: "transaction-limit-workflow",
+			RunID:      uuid.New(),
+		}
+		taskID := s.GetNextSequenceNumber()
+		tasks = append(tasks, &p.CreateTaskInfo{
+			TaskID:    taskID,
+			Execution: execution,
+			Data: &p.TaskInfo{
+				DomainID:   domainID,
+				WorkflowID: execution.WorkflowID,
+				RunID:      execution.RunID,
+				TaskID:     taskID,
+				ScheduleID: int64(i),
+			},
+		})
+	}
+	return tasks
+}
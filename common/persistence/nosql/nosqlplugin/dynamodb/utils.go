@@ -0,0 +1,416 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/uber/cadence/common/backoff"
+)
+
+const (
+	// maxTransactionItems is the max number of unique items in a single TransactWriteItems request.
+	// NOTE: the limit was 25 before AWS raised it to 100, and older DynamoDB Local versions still enforce 25.
+	// The DynamoDB Local image used by the tests is pinned(see docker/buildkite) to a version that allows 100.
+	maxTransactionItems = 100
+	// maxBatchWriteItems is the max number of items in a single BatchWriteItem request
+	maxBatchWriteItems = 25
+
+	keySeparator = "#"
+
+	conditionalCheckFailedReason = "ConditionalCheckFailed"
+)
+
+type attributeMap = map[string]*dynamodb.AttributeValue
+
+var unprocessedItemsRetryPolicy = newUnprocessedItemsRetryPolicy()
+
+func newUnprocessedItemsRetryPolicy() backoff.RetryPolicy {
+	policy := backoff.NewExponentialRetryPolicy(50 * time.Millisecond)
+	policy.SetMaximumInterval(2 * time.Second)
+	policy.SetExpirationInterval(30 * time.Second)
+	return policy
+}
+
+func (db *ddb) tableName(name string) *string {
+	return aws.String(db.cfg.Keyspace + "_" + name)
+}
+
+// compositeKey joins multiple columns into a single string key.
+// DynamoDB only supports one partition key attribute and one range key attribute.
+func compositeKey(parts ...string) string {
+	return strings.Join(parts, keySeparator)
+}
+
+// paddedInt64 formats a number so that the lexical order is the same as the numeric order.
+// The sign bit is flipped to shift the value into unsigned space, so negative numbers(e.g. pre-1970 timestamps)
+// are still ordered before the positive ones.
+func paddedInt64(v int64) string {
+	return fmt.Sprintf("%020d", uint64(v)^(1<<63))
+}
+
+var (
+	minUnixNanoTime = time.Unix(0, math.MinInt64)
+	maxUnixNanoTime = time.Unix(0, math.MaxInt64)
+)
+
+// unixNano returns the UnixNano of the time.
+// UnixNano is undefined when the time is out of the int64 range(e.g. a zero time.Time), so it's clamped.
+func unixNano(t time.Time) int64 {
+	switch {
+	case t.Before(minUnixNanoTime):
+		return math.MinInt64
+	case t.After(maxUnixNanoTime):
+		return math.MaxInt64
+	}
+	return t.UnixNano()
+}
+
+// paddedTimestamp formats a timestamp so that the lexical order is the same as the chronological order
+func paddedTimestamp(t time.Time) string {
+	return paddedInt64(unixNano(t))
+}
+
+func numberAttr(v int64) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(v, 10))}
+}
+
+func stringAttr(v string) *dynamodb.AttributeValue {
+	return &dynamodb.AttributeValue{S: aws.String(v)}
+}
+
+// attributeMapSize approximates the size of an item the way DynamoDB calculates it,
+// i.e. the sum of the lengths of the attribute names and values
+func attributeMapSize(item attributeMap) int {
+	size := 0
+	for name, value := range item {
+		size += len(name) + attributeValueSize(value)
+	}
+	return size
+}
+
+func attributeValueSize(value *dynamodb.AttributeValue) int {
+	if value == nil {
+		return 0
+	}
+	switch {
+	case value.S != nil:
+		return len(*value.S)
+	case value.N != nil:
+		return len(*value.N)
+	case value.B != nil:
+		return len(value.B)
+	case value.M != nil:
+		return 3 + attributeMapSize(value.M)
+	case value.L != nil:
+		size := 3
+		for _, v := range value.L {
+			size += 1 + attributeValueSize(v)
+		}
+		return size
+	}
+	// BOOL and NULL
+	return 1
+}
+
+// serializePageToken encodes the LastEvaluatedKey of a Query/Scan as an opaque page token
+func serializePageToken(lastEvaluatedKey attributeMap) ([]byte, error) {
+	if len(lastEvaluatedKey) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(lastEvaluatedKey)
+}
+
+// deserializePageToken decodes the page token into ExclusiveStartKey of a Query/Scan
+func deserializePageToken(pageToken []byte) (attributeMap, error) {
+	if len(pageToken) == 0 {
+		return nil, nil
+	}
+	var key attributeMap
+	if err := json.Unmarshal(pageToken, &key); err != nil {
+		return nil, fmt.Errorf("invalid page token: %v", err)
+	}
+	return key, nil
+}
+
+// getItem reads a single item with strong consistency, return errNotFound if the item doesn't exist
+func (db *ddb) getItem(ctx context.Context, table string, key attributeMap, out interface{}) error {
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      db.tableName(table),
+		Key:            key,
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Item) == 0 {
+		return errNotFound
+	}
+	return dynamodbattribute.UnmarshalMap(resp.Item, out)
+}
+
+// putItem writes a single item, with an optional condition expression
+func (db *ddb) putItem(
+	ctx context.Context,
+	table string,
+	item interface{},
+	condition string,
+	names map[string]*string,
+	values attributeMap,
+) error {
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return err
+	}
+	input := &dynamodb.PutItemInput{
+		TableName: db.tableName(table),
+		Item:      av,
+	}
+	if condition != "" {
+		input.ConditionExpression = aws.String(condition)
+		input.ExpressionAttributeNames = names
+		input.ExpressionAttributeValues = values
+	}
+	_, err = db.client.PutItemWithContext(ctx, input)
+	return err
+}
+
+// deleteItem deletes a single item. Deleting a non-existing item is not an error in DynamoDB.
+func (db *ddb) deleteItem(ctx context.Context, table string, key attributeMap) error {
+	_, err := db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: db.tableName(table),
+		Key:       key,
+	})
+	return err
+}
+
+// queryAll runs the query through all the pages
+func (db *ddb) queryAll(ctx context.Context, input *dynamodb.QueryInput) ([]attributeMap, error) {
+	var items []attributeMap
+	err := db.client.QueryPagesWithContext(ctx, input, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		items = append(items, page.Items...)
+		return true
+	})
+	return items, err
+}
+
+// queryPage runs the query until pageSize items are collected or there is no more items.
+// It's needed because DynamoDB applies the Limit before the filter expression.
+// A non-positive pageSize means no limit.
+func (db *ddb) queryPage(ctx context.Context, input *dynamodb.QueryInput, pageSize int, pageToken []byte) ([]attributeMap, []byte, error) {
+	startKey, err := deserializePageToken(pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []attributeMap
+	for {
+		input.ExclusiveStartKey = startKey
+		if pageSize > 0 {
+			input.Limit = aws.Int64(int64(pageSize - len(items)))
+		}
+		resp, err := db.client.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, resp.Items...)
+		startKey = resp.LastEvaluatedKey
+		if len(startKey) == 0 || (pageSize > 0 && len(items) >= pageSize) {
+			break
+		}
+	}
+	nextPageToken, err := serializePageToken(startKey)
+	return items, nextPageToken, err
+}
+
+// scanPage is the same as queryPage, but for a full table scan
+func (db *ddb) scanPage(ctx context.Context, input *dynamodb.ScanInput, pageSize int, pageToken []byte) ([]attributeMap, []byte, error) {
+	startKey, err := deserializePageToken(pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []attributeMap
+	for {
+		input.ExclusiveStartKey = startKey
+		if pageSize > 0 {
+			input.Limit = aws.Int64(int64(pageSize - len(items)))
+		}
+		input.ConsistentRead = aws.Bool(true)
+		resp, err := db.client.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, nil, err
+		}
+		items = append(items, resp.Items...)
+		startKey = resp.LastEvaluatedKey
+		if len(startKey) == 0 || (pageSize > 0 && len(items) >= pageSize) {
+			break
+		}
+	}
+	nextPageToken, err := serializePageToken(startKey)
+	return items, nextPageToken, err
+}
+
+// deleteByQuery deletes all the items returned by the query.
+// keyAttributes must be the primary key attributes of the table.
+// NOTE: this is not atomic. DynamoDB doesn't support range deletion, so it's implemented by query + batch delete.
+func (db *ddb) deleteByQuery(ctx context.Context, table string, input *dynamodb.QueryInput, keyAttributes ...string) (int, error) {
+	input.TableName = db.tableName(table)
+	input.ConsistentRead = aws.Bool(true)
+	input.ProjectionExpression = aws.String(strings.Join(keyAttributes, ", "))
+	items, err := db.queryAll(ctx, input)
+	if err != nil {
+		return 0, err
+	}
+	return len(items), db.batchDelete(ctx, table, items)
+}
+
+func (db *ddb) batchDelete(ctx context.Context, table string, keys []attributeMap) error {
+	for start := 0; start < len(keys); start += maxBatchWriteItems {
+		end := start + maxBatchWriteItems
+		if end > len(keys) {
+			end = len(keys)
+		}
+		requests := make([]*dynamodb.WriteRequest, 0, end-start)
+		for _, key := range keys[start:end] {
+			requests = append(requests, &dynamodb.WriteRequest{
+				DeleteRequest: &dynamodb.DeleteRequest{Key: key},
+			})
+		}
+		pending := map[string][]*dynamodb.WriteRequest{
+			*db.tableName(table): requests,
+		}
+		retrier := backoff.NewRetrier(unprocessedItemsRetryPolicy, backoff.SystemClock)
+		for {
+			resp, err := db.client.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+				RequestItems: pending,
+			})
+			if err != nil {
+				return err
+			}
+			pending = resp.UnprocessedItems
+			if len(pending) == 0 {
+				break
+			}
+
+			// UnprocessedItems are returned when the table is throttled, retry them with backoff
+			next := retrier.NextBackOff()
+			if next < 0 {
+				return fmt.Errorf("failed to delete %v items from %v after retries", len(pending[*db.tableName(table)]), table)
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(next):
+			}
+		}
+	}
+	return nil
+}
+
+// executeTransaction runs TransactWriteItems.
+// When the transaction is canceled because of condition checks, it returns errConditionFailed with the cancellation
+// reasons, one per transaction item in the same order as the items.
+func (db *ddb) executeTransaction(ctx context.Context, items []*dynamodb.TransactWriteItem) ([]*dynamodb.CancellationReason, error) {
+	_, err := db.client.TransactWriteItemsWithContext(ctx, &dynamodb.TransactWriteItemsInput{
+		TransactItems: items,
+	})
+	if err == nil {
+		return nil, nil
+	}
+	reasons, ok := getCancellationReasons(err)
+	if !ok {
+		return nil, err
+	}
+	for _, reason := range reasons {
+		if reason != nil && aws.StringValue(reason.Code) == conditionalCheckFailedReason {
+			return reasons, errConditionFailed
+		}
+	}
+	return nil, err
+}
+
+func getCancellationReasons(err error) ([]*dynamodb.CancellationReason, bool) {
+	if canceledErr, ok := err.(*dynamodb.TransactionCanceledException); ok && len(canceledErr.CancellationReasons) > 0 {
+		return canceledErr.CancellationReasons, true
+	}
+	aerr, ok := err.(awserr.Error)
+	if !ok || aerr.Code() != dynamodb.ErrCodeTransactionCanceledException {
+		return nil, false
+	}
+	// some endpoints(e.g. older DynamoDB Local) only return the reasons in the message:
+	// "Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed]"
+	msg := aerr.Message()
+	begin := strings.LastIndex(msg, "[")
+	end := strings.LastIndex(msg, "]")
+	if begin < 0 || end < begin {
+		return nil, false
+	}
+	var reasons []*dynamodb.CancellationReason
+	for _, code := range strings.Split(msg[begin+1:end], ",") {
+		reasons = append(reasons, &dynamodb.CancellationReason{
+			Code: aws.String(strings.TrimSpace(code)),
+		})
+	}
+	return reasons, true
+}
+
+// isConditionFailedReason returns true if the transaction item at the index failed because of condition check
+func isConditionFailedReason(reasons []*dynamodb.CancellationReason, index int) bool {
+	if index < 0 || index >= len(reasons) || reasons[index] == nil {
+		return false
+	}
+	return aws.StringValue(reasons[index].Code) == conditionalCheckFailedReason
+}
+
+// newPutTransactionItem creates a transaction item to put an item, with an optional condition expression
+func (db *ddb) newPutTransactionItem(
+	table string,
+	item interface{},
+	condition string,
+	names map[string]*string,
+	values attributeMap,
+) (*dynamodb.TransactWriteItem, error) {
+	av, err := dynamodbattribute.MarshalMap(item)
+	if err != nil {
+		return nil, err
+	}
+	put := &dynamodb.Put{
+		TableName: db.tableName(table),
+		Item:      av,
+	}
+	if condition != "" {
+		put.ConditionExpression = aws.String(condition)
+		put.ExpressionAttributeNames = names
+		put.ExpressionAttributeValues = values
+		put.ReturnValuesOnConditionCheckFailure = aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld)
+	}
+	return &dynamodb.TransactWriteItem{Put: put}, nil
+}
@@ -0,0 +1,246 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+// Portions of the Software are attributed to Copyright (c) 2020 Temporal Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynamodb
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common"
+	p "github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+)
+
+type utilsSuite struct {
+	suite.Suite
+}
+
+func TestUtilsSuite(t *testing.T) {
+	suite.Run(t, new(utilsSuite))
+}
+
+func (s *utilsSuite) TestPaddedInt64_Order() {
+	values := []int64{math.MinInt64, -1000, -1, 0, 1, 999, 1000, math.MaxInt64}
+	keys := make([]string, 0, len(values))
+	for _, v := range values {
+		keys = append(keys, paddedInt64(v))
+	}
+	s.True(sort.StringsAreSorted(keys))
+	for _, key := range keys {
+		s.Len(key, 20)
+	}
+}
+
+func (s *utilsSuite) TestTimerKey_Order() {
+	epoch := time.Unix(0, 0)
+	keys := []string{
+		timerKey(time.Time{}, 10),
+		timerKey(epoch.Add(-time.Hour), 10),
+		timerKey(epoch.Add(-time.Nanosecond), 10),
+		timerKey(epoch, 1),
+		timerKey(epoch, 2),
+		timerKey(epoch, 10),
+		timerKey(epoch.Add(time.Nanosecond), 0),
+		timerKey(maxUnixNanoTime.Add(time.Hour), 10),
+	}
+	s.True(sort.StringsAreSorted(keys))
+	s.Equal(paddedTimestamp(time.Time{}), paddedInt64(math.MinInt64))
+}
+
+func (s *utilsSuite) TestHistoryNodeKey_Order() {
+	keys := []string{
+		historyNodeKeyPrefix("branch", 1),
+		// the node with a higher transaction ID comes first
+		historyNodeKey("branch", 1, 20),
+		historyNodeKey("branch", 1, 10),
+		historyNodeKeyPrefix("branch", 2),
+		historyNodeKey("branch", 2, math.MaxInt64),
+		historyNodeKey("branch", 2, 1),
+		historyNodeKey("branch", 10, 1),
+	}
+	s.True(sort.StringsAreSorted(keys))
+}
+
+func (s *utilsSuite) TestHistoryNodeChunkKey_Order() {
+	keys := []string{
+		historyNodeKey("branch", 1, 20),
+		historyNodeChunkKey(historyNodeKey("branch", 1, 20), 1),
+		historyNodeChunkKey(historyNodeKey("branch", 1, 20), 2),
+		historyNodeChunkKey(historyNodeKey("branch", 1, 20), 10),
+		historyNodeKey("branch", 1, 10),
+		historyNodeKeyPrefix("branch", 2),
+	}
+	s.True(sort.StringsAreSorted(keys))
+}
+
+func (s *utilsSuite) TestHistoryNodeItems_RoundTrip() {
+	data := make([]byte, 2*historyNodeChunkSize+10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	row := &nosqlplugin.HistoryNodeRow{
+		TreeID:       "tree",
+		BranchID:     "branch",
+		NodeID:       5,
+		TxnID:        common.Int64Ptr(7),
+		Data:         data,
+		DataEncoding: "thriftrw",
+	}
+	small := &nosqlplugin.HistoryNodeRow{
+		TreeID:       "tree",
+		BranchID:     "branch",
+		NodeID:       6,
+		TxnID:        common.Int64Ptr(8),
+		Data:         []byte("small"),
+		DataEncoding: "thriftrw",
+	}
+
+	items := newHistoryNodeItems(row)
+	s.Len(items, 3)
+	s.Equal(3, items[0].ChunkCount)
+	for _, item := range items {
+		s.True(len(item.Data) <= historyNodeChunkSize)
+	}
+	smallItems := newHistoryNodeItems(small)
+	s.Len(smallItems, 1)
+	s.Zero(smallItems[0].ChunkCount)
+
+	rows, missing := toHistoryNodeRows(append(items, smallItems...))
+	s.Zero(missing)
+	s.Equal([]*nosqlplugin.HistoryNodeRow{row, small}, rows)
+
+	// the page ends in the middle of the chunked node
+	rows, missing = toHistoryNodeRows(items[:2])
+	s.Equal(1, missing)
+	s.Len(rows, 1)
+	s.Equal(data[:2*historyNodeChunkSize], rows[0].Data)
+
+	// the page starts with the remaining chunks of a node from the previous page
+	rows, missing = toHistoryNodeRows(append(items[1:], smallItems...))
+	s.Zero(missing)
+	s.Equal([]*nosqlplugin.HistoryNodeRow{small}, rows)
+}
+
+func (s *utilsSuite) TestGetCancellationReasons() {
+	testCases := []struct {
+		name    string
+		err     error
+		ok      bool
+		reasons []string
+	}{
+		{
+			name: "typed error",
+			err: &dynamodb.TransactionCanceledException{
+				CancellationReasons: []*dynamodb.CancellationReason{
+					{Code: aws.String("None")},
+					{Code: aws.String(conditionalCheckFailedReason)},
+				},
+			},
+			ok:      true,
+			reasons: []string{"None", conditionalCheckFailedReason},
+		},
+		{
+			name: "reasons in message",
+			err: awserr.New(dynamodb.ErrCodeTransactionCanceledException,
+				"Transaction cancelled, please refer cancellation reasons for specific reasons [None, ConditionalCheckFailed, None]", nil),
+			ok:      true,
+			reasons: []string{"None", conditionalCheckFailedReason, "None"},
+		},
+		{
+			name: "no reasons in message",
+			err:  awserr.New(dynamodb.ErrCodeTransactionCanceledException, "Transaction cancelled", nil),
+		},
+		{
+			name: "other error",
+			err:  awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "[ConditionalCheckFailed]", nil),
+		},
+	}
+
+	for _, tc := range testCases {
+		reasons, ok := getCancellationReasons(tc.err)
+		s.Equal(tc.ok, ok, tc.name)
+		var codes []string
+		for _, reason := range reasons {
+			codes = append(codes, aws.StringValue(reason.Code))
+		}
+		s.Equal(tc.reasons, codes, tc.name)
+	}
+}
+
+func (s *utilsSuite) TestPageToken_RoundTrip() {
+	key := attributeMap{
+		"shard_id":  numberAttr(10),
+		"timer_key": stringAttr(timerKey(time.Unix(0, 100), 5)),
+	}
+	token, err := serializePageToken(key)
+	s.NoError(err)
+	s.NotEmpty(token)
+
+	decoded, err := deserializePageToken(token)
+	s.NoError(err)
+	s.Equal(key, decoded)
+
+	token, err = serializePageToken(nil)
+	s.NoError(err)
+	s.Nil(token)
+	decoded, err = deserializePageToken(nil)
+	s.NoError(err)
+	s.Nil(decoded)
+
+	_, err = deserializePageToken([]byte("invalid"))
+	s.Error(err)
+}
+
+func (s *utilsSuite) TestCheckTransactionSize() {
+	newPut := func(size int) *dynamodb.TransactWriteItem {
+		return &dynamodb.TransactWriteItem{
+			Put: &dynamodb.Put{
+				Item: attributeMap{"data": {B: make([]byte, size)}},
+			},
+		}
+	}
+
+	items := []*dynamodb.TransactWriteItem{newPut(100), newPut(100)}
+	s.NoError(checkTransactionSize(items))
+
+	items = make([]*dynamodb.TransactWriteItem, 0, maxTransactionItems+1)
+	for i := 0; i <= maxTransactionItems; i++ {
+		items = append(items, newPut(1))
+	}
+	s.IsType(&p.TransactionSizeLimitError{}, checkTransactionSize(items))
+
+	items = []*dynamodb.TransactWriteItem{newPut(maxItemSize)}
+	s.IsType(&p.TransactionSizeLimitError{}, checkTransactionSize(items))
+
+	items = nil
+	for i := 0; i < 2*maxTransactionSize/maxItemSize+1; i++ {
+		items = append(items, newPut(maxItemSize/2))
+	}
+	s.IsType(&p.TransactionSizeLimitError{}, checkTransactionSize(items))
+}
@@ -22,43 +22,202 @@ package dynamodb
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
 )
 
+// visibilityItem is the item of visibility table. The significant columns are used by the
+// global secondary indexes or filter expressions, the rest is stored as a JSON blob.
+// Global secondary indexes are used instead of local ones, because a table with local secondary indexes
+// is limited to 10GB per partition key, which is too small for a busy domain.
+// close_time and close_status are absent for open workflows, which makes the close_time_index sparse.
+type visibilityItem struct {
+	DomainID         string `dynamodbav:"domain_id"`
+	RunKey           string `dynamodbav:"run_key"`
+	WorkflowID       string `dynamodbav:"workflow_id"`
+	WorkflowTypeName string `dynamodbav:"workflow_type_name"`
+	StartTime        int64  `dynamodbav:"start_time"`
+	CloseTime        *int64 `dynamodbav:"close_time,omitempty"`
+	CloseStatus      *int32 `dynamodbav:"close_status,omitempty"`
+	Data             []byte `dynamodbav:"data"`
+	// Expiry is the TTL in unix seconds, zero means never expire
+	Expiry int64 `dynamodbav:"expiry,omitempty"`
+}
+
+func visibilityKey(domainID, workflowID, runID string) attributeMap {
+	return attributeMap{
+		"domain_id": stringAttr(domainID),
+		"run_key":   stringAttr(compositeKey(workflowID, runID)),
+	}
+}
+
+func newVisibilityItem(domainID string, row *nosqlplugin.VisibilityRow, closed bool, ttlSeconds int64) (*visibilityItem, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	item := &visibilityItem{
+		DomainID:         domainID,
+		RunKey:           compositeKey(row.WorkflowID, row.RunID),
+		WorkflowID:       row.WorkflowID,
+		WorkflowTypeName: row.TypeName,
+		StartTime:        unixNano(row.StartTime),
+		Data:             data,
+	}
+	if closed {
+		closeTime := unixNano(row.CloseTime)
+		item.CloseTime = &closeTime
+		if row.Status != nil {
+			closeStatus := int32(*row.Status)
+			item.CloseStatus = &closeStatus
+		}
+	}
+	if ttlSeconds > 0 {
+		item.Expiry = time.Now().Unix() + ttlSeconds
+	}
+	return item, nil
+}
+
+func toVisibilityRow(item attributeMap) (*nosqlplugin.VisibilityRow, error) {
+	var visibility visibilityItem
+	if err := dynamodbattribute.UnmarshalMap(item, &visibility); err != nil {
+		return nil, err
+	}
+	row := &nosqlplugin.VisibilityRow{}
+	if err := json.Unmarshal(visibility.Data, row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
 func (db *ddb) InsertVisibility(
 	ctx context.Context,
 	ttlSeconds int64,
 	row *nosqlplugin.VisibilityRowForInsert,
 ) error {
-	panic("TODO")
+	item, err := newVisibilityItem(row.DomainID, &row.VisibilityRow, false, ttlSeconds)
+	if err != nil {
+		return err
+	}
+	return db.putItem(ctx, cadence.VisibilityTableName, item, "", nil, nil)
 }
 
+// UpdateVisibility overrides the visibility record.
+// Unlike Cassandra, open and closed records are in the same table, so UpdateOpenToClose/UpdateCloseToOpen can be ignored.
 func (db *ddb) UpdateVisibility(
 	ctx context.Context,
 	ttlSeconds int64,
 	row *nosqlplugin.VisibilityRowForUpdate,
 ) error {
-	panic("TODO")
+	item, err := newVisibilityItem(row.DomainID, &row.VisibilityRow, !row.UpdateCloseToOpen, ttlSeconds)
+	if err != nil {
+		return err
+	}
+	return db.putItem(ctx, cadence.VisibilityTableName, item, "", nil, nil)
 }
 
 func (db *ddb) SelectVisibility(
 	ctx context.Context,
 	filter *nosqlplugin.VisibilityFilter,
 ) (*nosqlplugin.SelectVisibilityResponse, error) {
-	panic("TODO")
+	request := &filter.ListRequest
+	values := attributeMap{
+		":domain_id": stringAttr(request.DomainUUID),
+		":earliest":  numberAttr(unixNano(request.EarliestTime)),
+		":latest":    numberAttr(unixNano(request.LatestTime)),
+		":now":       nowAttr(),
+	}
+	conditions := []string{notExpiredCondition}
+
+	isOpen := filter.FilterType == nosqlplugin.AllOpen ||
+		filter.FilterType == nosqlplugin.OpenByWorkflowType ||
+		filter.FilterType == nosqlplugin.OpenByWorkflowID
+	// close_time_index only contains closed workflows, so the filter is only needed for start_time_index
+	sortByClosedTime := !isOpen && filter.SortType == nosqlplugin.SortByClosedTime
+	if isOpen {
+		conditions = append(conditions, "attribute_not_exists(close_time)")
+	} else if !sortByClosedTime {
+		conditions = append(conditions, "attribute_exists(close_time)")
+	}
+
+	switch filter.FilterType {
+	case nosqlplugin.OpenByWorkflowType, nosqlplugin.ClosedByWorkflowType:
+		conditions = append(conditions, "workflow_type_name = :workflow_type_name")
+		values[":workflow_type_name"] = stringAttr(filter.WorkflowType)
+	case nosqlplugin.OpenByWorkflowID, nosqlplugin.ClosedByWorkflowID:
+		conditions = append(conditions, "workflow_id = :workflow_id")
+		values[":workflow_id"] = stringAttr(filter.WorkflowID)
+	case nosqlplugin.ClosedByClosedStatus:
+		conditions = append(conditions, "close_status = :close_status")
+		values[":close_status"] = numberAttr(int64(filter.CloseStatus))
+	}
+
+	// open workflows are always sorted by start time
+	indexName := cadence.VisibilityStartTimeIndexName
+	keyCondition := "domain_id = :domain_id AND start_time BETWEEN :earliest AND :latest"
+	if sortByClosedTime {
+		indexName = cadence.VisibilityCloseTimeIndexName
+		keyCondition = "domain_id = :domain_id AND close_time BETWEEN :earliest AND :latest"
+	}
+
+	items, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:                 db.tableName(cadence.VisibilityTableName),
+		IndexName:                 aws.String(indexName),
+		KeyConditionExpression:    aws.String(keyCondition),
+		FilterExpression:          aws.String(strings.Join(conditions, " AND ")),
+		ExpressionAttributeValues: values,
+		// newest first
+		ScanIndexForward: aws.Bool(false),
+		// global secondary indexes only support eventually consistent reads, which is fine for visibility
+	}, request.PageSize, request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	executions := make([]*nosqlplugin.VisibilityRow, 0, len(items))
+	for _, item := range items {
+		row, err := toVisibilityRow(item)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, row)
+	}
+	return &nosqlplugin.SelectVisibilityResponse{
+		Executions:    executions,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
 func (db *ddb) DeleteVisibility(
 	ctx context.Context,
 	domainID, workflowID, runID string,
 ) error {
-	panic("TODO")
+	return db.deleteItem(ctx, cadence.VisibilityTableName, visibilityKey(domainID, workflowID, runID))
 }
 
 func (db *ddb) SelectOneClosedWorkflow(
 	ctx context.Context,
 	domainID, workflowID, runID string,
 ) (*nosqlplugin.VisibilityRow, error) {
-	panic("TODO")
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:      db.tableName(cadence.VisibilityTableName),
+		Key:            visibilityKey(domainID, workflowID, runID),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, closed := resp.Item["close_time"]; !closed {
+		// Special case: return nil,nil if not found(since we will deprecate it, it's not worth refactor to be consistent)
+		return nil, nil
+	}
+	return toVisibilityRow(resp.Item)
 }
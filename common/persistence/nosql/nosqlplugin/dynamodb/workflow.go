@@ -23,10 +23,18 @@ package dynamodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
 )
 
 var _ nosqlplugin.WorkflowCRUD = (*ddb)(nil)
@@ -41,7 +49,25 @@ func (db *ddb) InsertWorkflowExecutionWithTasks(
 	timerTasks []*nosqlplugin.TimerTask,
 	shardCondition *nosqlplugin.ShardCondition,
 ) error {
-	panic("TODO")
+	shardID := shardCondition.ShardID
+	txn := newWorkflowTransaction()
+
+	err := db.createOrUpdateCurrentWorkflow(txn, shardID, execution.DomainID, execution.WorkflowID, currentWorkflowRequest)
+	if err != nil {
+		return err
+	}
+
+	err = db.createWorkflowExecutionWithMergeMaps(txn, shardID, execution)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := db.createTasks(shardID, transferTasks, crossClusterTasks, replicationTasks, timerTasks)
+	if err != nil {
+		return err
+	}
+
+	return db.executeWorkflowTransaction(ctx, txn, tasks, currentWorkflowRequest, shardCondition)
 }
 
 func (db *ddb) UpdateWorkflowExecutionWithTasks(
@@ -56,105 +82,492 @@ func (db *ddb) UpdateWorkflowExecutionWithTasks(
 	timerTasks []*nosqlplugin.TimerTask,
 	shardCondition *nosqlplugin.ShardCondition,
 ) error {
-	panic("TODO")
+	shardID := shardCondition.ShardID
+	var domainID, workflowID string
+	if mutatedExecution != nil {
+		domainID = mutatedExecution.DomainID
+		workflowID = mutatedExecution.WorkflowID
+	} else if resetExecution != nil {
+		domainID = resetExecution.DomainID
+		workflowID = resetExecution.WorkflowID
+	} else {
+		return fmt.Errorf("at least one of mutatedExecution and resetExecution should be provided")
+	}
+
+	txn := newWorkflowTransaction()
+
+	err := db.createOrUpdateCurrentWorkflow(txn, shardID, domainID, workflowID, currentWorkflowRequest)
+	if err != nil {
+		return err
+	}
+
+	if mutatedExecution != nil {
+		err = db.updateWorkflowExecutionAndEventBufferWithMergeAndDeleteMaps(ctx, txn, shardID, mutatedExecution)
+		if err != nil {
+			return err
+		}
+	}
+
+	if insertedExecution != nil {
+		err = db.createWorkflowExecutionWithMergeMaps(txn, shardID, insertedExecution)
+		if err != nil {
+			return err
+		}
+	}
+
+	if resetExecution != nil {
+		err = db.resetWorkflowExecutionAndMapsAndEventBuffer(ctx, txn, shardID, resetExecution)
+		if err != nil {
+			return err
+		}
+	}
+
+	tasks, err := db.createTasks(shardID, transferTasks, crossClusterTasks, replicationTasks, timerTasks)
+	if err != nil {
+		return err
+	}
+
+	return db.executeWorkflowTransaction(ctx, txn, tasks, currentWorkflowRequest, shardCondition)
 }
 
 func (db *ddb) SelectCurrentWorkflow(ctx context.Context, shardID int, domainID, workflowID string) (*nosqlplugin.CurrentWorkflowRow, error) {
-	panic("TODO")
+	var item currentWorkflowItem
+	if err := db.getItem(ctx, cadence.CurrentWorkflowTableName, currentWorkflowKey(shardID, domainID, workflowID), &item); err != nil {
+		return nil, err
+	}
+	row := &nosqlplugin.CurrentWorkflowRow{
+		LastWriteVersion: common.EmptyVersion,
+	}
+	if err := json.Unmarshal(item.Data, row); err != nil {
+		return nil, err
+	}
+	row.ShardID = shardID
+	row.DomainID = domainID
+	row.WorkflowID = workflowID
+	row.RunID = item.CurrentRunID
+	return row, nil
 }
 
 func (db *ddb) SelectWorkflowExecution(ctx context.Context, shardID int, domainID, workflowID, runID string) (*nosqlplugin.WorkflowExecution, error) {
-	panic("TODO")
+	return db.selectExecution(ctx, shardID, domainID, workflowID, runID)
 }
 
 func (db *ddb) DeleteCurrentWorkflow(ctx context.Context, shardID int, domainID, workflowID, currentRunIDCondition string) error {
-	panic("TODO")
+	_, err := db.client.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName:           db.tableName(cadence.CurrentWorkflowTableName),
+		Key:                 currentWorkflowKey(shardID, domainID, workflowID),
+		ConditionExpression: aws.String("current_run_id = :current_run_id"),
+		ExpressionAttributeValues: attributeMap{
+			":current_run_id": stringAttr(currentRunIDCondition),
+		},
+	})
+	if db.IsConditionFailedError(err) {
+		// the current workflow has moved to another run, which shouldn't be deleted
+		return nil
+	}
+	return err
 }
 
 func (db *ddb) DeleteWorkflowExecution(ctx context.Context, shardID int, domainID, workflowID, runID string) error {
-	panic("TODO")
+	// entries are deleted first, so that a failed deletion can be retried
+	if _, err := db.deleteExecutionEntries(ctx, shardID, domainID, workflowID, runID, nil); err != nil {
+		return err
+	}
+	return db.deleteItem(ctx, cadence.WorkflowExecutionTableName, executionKey(shardID, domainID, workflowID, runID))
 }
 
 func (db *ddb) SelectAllCurrentWorkflows(ctx context.Context, shardID int, pageToken []byte, pageSize int) ([]*persistence.CurrentWorkflowExecution, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.CurrentWorkflowTableName),
+		KeyConditionExpression: aws.String("shard_id = :shard_id"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id": numberAttr(int64(shardID)),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	executions := make([]*persistence.CurrentWorkflowExecution, 0, len(items))
+	for _, av := range items {
+		var item currentWorkflowItem
+		if err := dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+			return nil, nil, err
+		}
+		row := &nosqlplugin.CurrentWorkflowRow{}
+		if err := json.Unmarshal(item.Data, row); err != nil {
+			return nil, nil, err
+		}
+		executions = append(executions, &persistence.CurrentWorkflowExecution{
+			DomainID:     row.DomainID,
+			WorkflowID:   row.WorkflowID,
+			RunID:        item.CurrentRunID,
+			State:        item.State,
+			CurrentRunID: item.CurrentRunID,
+		})
+	}
+	return executions, nextPageToken, nil
 }
 
 func (db *ddb) SelectAllWorkflowExecutions(ctx context.Context, shardID int, pageToken []byte, pageSize int) ([]*persistence.InternalListConcreteExecutionsEntity, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.WorkflowExecutionTableName),
+		KeyConditionExpression: aws.String("shard_id = :shard_id"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id": numberAttr(int64(shardID)),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	executions := make([]*persistence.InternalListConcreteExecutionsEntity, 0, len(items))
+	for _, av := range items {
+		var item executionItem
+		if err := dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+			return nil, nil, err
+		}
+		data := &executionData{}
+		if err := json.Unmarshal(item.Data, data); err != nil {
+			return nil, nil, err
+		}
+		executions = append(executions, &persistence.InternalListConcreteExecutionsEntity{
+			ExecutionInfo:    data.ExecutionInfo,
+			VersionHistories: data.VersionHistories,
+		})
+	}
+	return executions, nextPageToken, nil
 }
 
 func (db *ddb) IsWorkflowExecutionExists(ctx context.Context, shardID int, domainID, workflowID, runID string) (bool, error) {
-	panic("TODO")
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            db.tableName(cadence.WorkflowExecutionTableName),
+		Key:                  executionKey(shardID, domainID, workflowID, runID),
+		ProjectionExpression: aws.String("shard_id"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(resp.Item) > 0, nil
 }
 
 func (db *ddb) SelectTransferTasksOrderByTaskID(ctx context.Context, shardID, pageSize int, pageToken []byte, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.TransferTask, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.selectShardTasks(ctx, cadence.TransferTaskTableName, shardID, pageSize, pageToken, exclusiveMinTaskID, inclusiveMaxTaskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.TransferTask, 0, len(items))
+	for _, item := range items {
+		task := &nosqlplugin.TransferTask{}
+		if err := json.Unmarshal(item.Data, task); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *ddb) DeleteTransferTask(ctx context.Context, shardID int, taskID int64) error {
-	panic("TODO")
+	return db.deleteItem(ctx, cadence.TransferTaskTableName, shardTaskKey(shardID, taskID))
 }
 
 func (db *ddb) RangeDeleteTransferTasks(ctx context.Context, shardID int, exclusiveBeginTaskID, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	_, err := db.deleteByQuery(ctx, cadence.TransferTaskTableName, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("shard_id = :shard_id AND task_id BETWEEN :min_task_id AND :max_task_id"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id":    numberAttr(int64(shardID)),
+			":min_task_id": numberAttr(exclusiveBeginTaskID + 1),
+			":max_task_id": numberAttr(inclusiveEndTaskID),
+		},
+	}, "shard_id", "task_id")
+	return err
 }
 
 func (db *ddb) SelectTimerTasksOrderByVisibilityTime(ctx context.Context, shardID, pageSize int, pageToken []byte, inclusiveMinTime, exclusiveMaxTime time.Time) ([]*nosqlplugin.TimerTask, []byte, error) {
-	panic("TODO")
+	// timer_key is visibility_timestamp#task_id, so a key with the max timestamp is always greater than the padded
+	// max timestamp itself, which makes the upper bound exclusive
+	items, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.TimerTaskTableName),
+		KeyConditionExpression: aws.String("shard_id = :shard_id AND timer_key BETWEEN :min_timer_key AND :max_timer_key"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id":      numberAttr(int64(shardID)),
+			":min_timer_key": stringAttr(paddedTimestamp(inclusiveMinTime)),
+			":max_timer_key": stringAttr(paddedTimestamp(exclusiveMaxTime)),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tasks := make([]*nosqlplugin.TimerTask, 0, len(items))
+	for _, av := range items {
+		var item timerTaskItem
+		if err := dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+			return nil, nil, err
+		}
+		task := &nosqlplugin.TimerTask{}
+		if err := json.Unmarshal(item.Data, task); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *ddb) DeleteTimerTask(ctx context.Context, shardID int, taskID int64, visibilityTimestamp time.Time) error {
-	panic("TODO")
+	return db.deleteItem(ctx, cadence.TimerTaskTableName, timerTaskKey(shardID, visibilityTimestamp, taskID))
 }
 
 func (db *ddb) RangeDeleteTimerTasks(ctx context.Context, shardID int, inclusiveMinTime, exclusiveMaxTime time.Time) error {
-	panic("TODO")
+	_, err := db.deleteByQuery(ctx, cadence.TimerTaskTableName, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("shard_id = :shard_id AND timer_key BETWEEN :min_timer_key AND :max_timer_key"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id":      numberAttr(int64(shardID)),
+			":min_timer_key": stringAttr(paddedTimestamp(inclusiveMinTime)),
+			":max_timer_key": stringAttr(paddedTimestamp(exclusiveMaxTime)),
+		},
+	}, "shard_id", "timer_key")
+	return err
 }
 
 func (db *ddb) SelectReplicationTasksOrderByTaskID(ctx context.Context, shardID, pageSize int, pageToken []byte, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.ReplicationTask, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.selectShardTasks(ctx, cadence.ReplicationTaskTableName, shardID, pageSize, pageToken, exclusiveMinTaskID, inclusiveMaxTaskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.ReplicationTask, 0, len(items))
+	for _, item := range items {
+		task := &nosqlplugin.ReplicationTask{}
+		if err := json.Unmarshal(item.Data, task); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *ddb) DeleteReplicationTask(ctx context.Context, shardID int, taskID int64) error {
-	panic("TODO")
+	return db.deleteItem(ctx, cadence.ReplicationTaskTableName, shardTaskKey(shardID, taskID))
 }
 
 func (db *ddb) RangeDeleteReplicationTasks(ctx context.Context, shardID int, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	_, err := db.deleteByQuery(ctx, cadence.ReplicationTaskTableName, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("shard_id = :shard_id AND task_id <= :max_task_id"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id":    numberAttr(int64(shardID)),
+			":max_task_id": numberAttr(inclusiveEndTaskID),
+		},
+	}, "shard_id", "task_id")
+	return err
 }
 
 func (db *ddb) InsertReplicationTask(ctx context.Context, tasks []*nosqlplugin.ReplicationTask, condition nosqlplugin.ShardCondition) error {
-	panic("TODO")
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	items, err := db.createTasks(condition.ShardID, nil, nil, tasks, nil)
+	if err != nil {
+		return err
+	}
+	// all the tasks are written in one transaction together with the shard condition, so that either all or none
+	// of them are written
+	shardIndex := len(items)
+	items = append(items, db.newShardConditionCheck(&condition))
+	if err := checkTransactionSize(items); err != nil {
+		return err
+	}
+	reasons, err := db.executeTransaction(ctx, items)
+	if !db.IsConditionFailedError(err) {
+		return err
+	}
+
+	if isConditionFailedReason(reasons, shardIndex) {
+		var shard shardItem
+		if err := db.unmarshalOldItem(ctx, reasons[shardIndex], cadence.ShardTableName, shardKey(condition.ShardID), &shard); err != nil {
+			return err
+		}
+		if shard.RangeID != condition.RangeID {
+			return &nosqlplugin.ShardOperationConditionFailure{
+				RangeID: shard.RangeID,
+			}
+		}
+	}
+	// At this point we only know that the write was not applied.
+	// It's much safer to return ShardOperationConditionFailure(which will become ShardOwnershipLostError later) as the default to force the application to reload
+	// shard to recover from such errors
+	return &nosqlplugin.ShardOperationConditionFailure{
+		RangeID: -1,
+		Details: *newUnknownConditionFailureReason(condition.RangeID, reasons).UnknownConditionFailureDetails,
+	}
 }
 
 func (db *ddb) SelectCrossClusterTasksOrderByTaskID(ctx context.Context, shardID, pageSize int, pageToken []byte, targetCluster string, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.CrossClusterTask, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.selectClusterTasks(ctx, cadence.CrossClusterTaskTableName, shardID, targetCluster, pageSize, pageToken, exclusiveMinTaskID, inclusiveMaxTaskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.CrossClusterTask, 0, len(items))
+	for _, item := range items {
+		task := &nosqlplugin.CrossClusterTask{
+			TargetCluster: targetCluster,
+		}
+		if err := json.Unmarshal(item.Data, &task.TransferTask); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *ddb) DeleteCrossClusterTask(ctx context.Context, shardID int, targetCluster string, taskID int64) error {
-	panic("TODO")
+	return db.deleteItem(ctx, cadence.CrossClusterTaskTableName, clusterTaskKey(shardID, targetCluster, taskID))
 }
 
 func (db *ddb) RangeDeleteCrossClusterTasks(ctx context.Context, shardID int, targetCluster string, exclusiveBeginTaskID, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	return db.rangeDeleteClusterTasks(ctx, cadence.CrossClusterTaskTableName, shardID, targetCluster, exclusiveBeginTaskID, inclusiveEndTaskID)
 }
 
 func (db *ddb) InsertReplicationDLQTask(ctx context.Context, shardID int, sourceCluster string, task nosqlplugin.ReplicationTask) error {
-	panic("TODO")
+	data, err := json.Marshal(&task)
+	if err != nil {
+		return err
+	}
+	return db.putItem(ctx, cadence.ReplicationDLQTaskTableName, &clusterTaskItem{
+		ShardCluster: shardClusterKey(shardID, sourceCluster),
+		TaskID:       task.TaskID,
+		Data:         data,
+	}, "", nil, nil)
 }
 
 func (db *ddb) SelectReplicationDLQTasksOrderByTaskID(ctx context.Context, shardID int, sourceCluster string, pageSize int, pageToken []byte, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.ReplicationTask, []byte, error) {
-	panic("TODO")
+	items, nextPageToken, err := db.selectClusterTasks(ctx, cadence.ReplicationDLQTaskTableName, shardID, sourceCluster, pageSize, pageToken, exclusiveMinTaskID, inclusiveMaxTaskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.ReplicationTask, 0, len(items))
+	for _, item := range items {
+		task := &nosqlplugin.ReplicationTask{}
+		if err := json.Unmarshal(item.Data, task); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *ddb) SelectReplicationDLQTasksCount(ctx context.Context, shardID int, sourceCluster string) (int64, error) {
-	panic("TODO")
+	var count int64
+	err := db.client.QueryPagesWithContext(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.ReplicationDLQTaskTableName),
+		KeyConditionExpression: aws.String("shard_cluster = :shard_cluster"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_cluster": stringAttr(shardClusterKey(shardID, sourceCluster)),
+		},
+		Select:         aws.String(dynamodb.SelectCount),
+		ConsistentRead: aws.Bool(true),
+	}, func(page *dynamodb.QueryOutput, lastPage bool) bool {
+		count += aws.Int64Value(page.Count)
+		return true
+	})
+	if err != nil {
+		return -1, err
+	}
+	return count, nil
 }
 
 func (db *ddb) DeleteReplicationDLQTask(ctx context.Context, shardID int, sourceCluster string, taskID int64) error {
-	panic("TODO")
+	return db.deleteItem(ctx, cadence.ReplicationDLQTaskTableName, clusterTaskKey(shardID, sourceCluster, taskID))
 }
 
 func (db *ddb) RangeDeleteReplicationDLQTasks(ctx context.Context, shardID int, sourceCluster string, exclusiveBeginTaskID, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	return db.rangeDeleteClusterTasks(ctx, cadence.ReplicationDLQTaskTableName, shardID, sourceCluster, exclusiveBeginTaskID, inclusiveEndTaskID)
+}
+
+// selectShardTasks queries the tasks of transfer_task or replication_task table within (exclusiveMinTaskID, inclusiveMaxTaskID]
+func (db *ddb) selectShardTasks(
+	ctx context.Context,
+	table string,
+	shardID, pageSize int,
+	pageToken []byte,
+	exclusiveMinTaskID, inclusiveMaxTaskID int64,
+) ([]*shardTaskItem, []byte, error) {
+	avs, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(table),
+		KeyConditionExpression: aws.String("shard_id = :shard_id AND task_id BETWEEN :min_task_id AND :max_task_id"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id":    numberAttr(int64(shardID)),
+			":min_task_id": numberAttr(exclusiveMinTaskID + 1),
+			":max_task_id": numberAttr(inclusiveMaxTaskID),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	items := make([]*shardTaskItem, 0, len(avs))
+	for _, av := range avs {
+		item := &shardTaskItem{}
+		if err := dynamodbattribute.UnmarshalMap(av, item); err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nextPageToken, nil
+}
+
+// selectClusterTasks queries the tasks of cross_cluster_task or replication_dlq_task table within (exclusiveMinTaskID, inclusiveMaxTaskID]
+func (db *ddb) selectClusterTasks(
+	ctx context.Context,
+	table string,
+	shardID int,
+	cluster string,
+	pageSize int,
+	pageToken []byte,
+	exclusiveMinTaskID, inclusiveMaxTaskID int64,
+) ([]*clusterTaskItem, []byte, error) {
+	avs, nextPageToken, err := db.queryPage(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(table),
+		KeyConditionExpression: aws.String("shard_cluster = :shard_cluster AND task_id BETWEEN :min_task_id AND :max_task_id"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_cluster": stringAttr(shardClusterKey(shardID, cluster)),
+			":min_task_id":   numberAttr(exclusiveMinTaskID + 1),
+			":max_task_id":   numberAttr(inclusiveMaxTaskID),
+		},
+		ConsistentRead: aws.Bool(true),
+	}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	items := make([]*clusterTaskItem, 0, len(avs))
+	for _, av := range avs {
+		item := &clusterTaskItem{}
+		if err := dynamodbattribute.UnmarshalMap(av, item); err != nil {
+			return nil, nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nextPageToken, nil
+}
+
+func (db *ddb) rangeDeleteClusterTasks(
+	ctx context.Context,
+	table string,
+	shardID int,
+	cluster string,
+	exclusiveBeginTaskID, inclusiveEndTaskID int64,
+) error {
+	_, err := db.deleteByQuery(ctx, table, &dynamodb.QueryInput{
+		KeyConditionExpression: aws.String("shard_cluster = :shard_cluster AND task_id BETWEEN :min_task_id AND :max_task_id"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_cluster": stringAttr(shardClusterKey(shardID, cluster)),
+			":min_task_id":   numberAttr(exclusiveBeginTaskID + 1),
+			":max_task_id":   numberAttr(inclusiveEndTaskID),
+		},
+	}, "shard_cluster", "task_id")
+	return err
 }
@@ -0,0 +1,1060 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+// Portions of the Software are attributed to Copyright (c) 2020 Temporal Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package dynamodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/checksum"
+	"github.com/uber/cadence/common/log/tag"
+	p "github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/dynamodb/cadence"
+)
+
+const (
+	// maxItemSize is the max size of a single DynamoDB item
+	maxItemSize = 400 * 1024
+	// maxTransactionSize is the max aggregate size of the items in a single TransactWriteItems request
+	maxTransactionSize = 4 * 1024 * 1024
+)
+
+// below are the types of the workflow_execution_entry items
+const (
+	entryTypeActivity        = "activity"
+	entryTypeTimer           = "timer"
+	entryTypeChild           = "child"
+	entryTypeRequestCancel   = "cancel"
+	entryTypeSignal          = "signal"
+	entryTypeSignalRequested = "signal_requested"
+	entryTypeBufferedEvents  = "buffer"
+)
+
+type (
+	// currentWorkflowItem is the item of current_workflow table.
+	// current_run_id, last_write_version and state are the condition columns, the row is stored as a JSON blob.
+	currentWorkflowItem struct {
+		ShardID          int    `dynamodbav:"shard_id"`
+		WorkflowKey      string `dynamodbav:"workflow_key"`
+		CurrentRunID     string `dynamodbav:"current_run_id"`
+		LastWriteVersion int64  `dynamodbav:"last_write_version"`
+		State            int    `dynamodbav:"state"`
+		Data             []byte `dynamodbav:"data"`
+	}
+
+	// executionItem is the item of workflow_execution table, it only contains the execution info.
+	// Every entry of the maps(activities, timers, etc) and every batch of the buffered events is a separate item in
+	// workflow_execution_entry table, so that each item stays small and can be upserted or deleted on its own.
+	//
+	// Generation is increased when the workflow is reset. Entries of an older generation are not visible anymore
+	// and are deleted in the background, so that a reset doesn't need to delete all the existing entries in
+	// the transaction.
+	// Buffered events batches are numbered by BufferedEventSeq. Clearing the buffer moves BufferStartSeq
+	// forward for the same reason.
+	executionItem struct {
+		ShardID          int    `dynamodbav:"shard_id"`
+		ExecutionKey     string `dynamodbav:"execution_key"`
+		NextEventID      int64  `dynamodbav:"next_event_id"`
+		Generation       int64  `dynamodbav:"generation"`
+		BufferStartSeq   int64  `dynamodbav:"buffer_start_seq"`
+		BufferedEventSeq int64  `dynamodbav:"buffered_event_seq"`
+		Data             []byte `dynamodbav:"data"`
+	}
+
+	executionData struct {
+		ExecutionInfo    *p.InternalWorkflowExecutionInfo
+		VersionHistories *p.DataBlob
+		Checksum         checksum.Checksum
+		LastWriteVersion int64
+	}
+
+	// executionEntryItem is the item of workflow_execution_entry table.
+	// entry_key is domain_id#workflow_id#run_id#entry_type#key. workflow_id and run_id are also stored as attributes,
+	// because workflow_id may contain the separator.
+	executionEntryItem struct {
+		ShardID    int    `dynamodbav:"shard_id"`
+		EntryKey   string `dynamodbav:"entry_key"`
+		WorkflowID string `dynamodbav:"workflow_id"`
+		RunID      string `dynamodbav:"run_id"`
+		Generation int64  `dynamodbav:"generation"`
+		Data       []byte `dynamodbav:"data,omitempty"`
+	}
+
+	// shardTaskItem is the item of transfer_task and replication_task tables
+	shardTaskItem struct {
+		ShardID int    `dynamodbav:"shard_id"`
+		TaskID  int64  `dynamodbav:"task_id"`
+		Data    []byte `dynamodbav:"data"`
+	}
+
+	// timerTaskItem is the item of timer_task table, timer_key is visibility_timestamp#task_id
+	timerTaskItem struct {
+		ShardID  int    `dynamodbav:"shard_id"`
+		TimerKey string `dynamodbav:"timer_key"`
+		Data     []byte `dynamodbav:"data"`
+	}
+
+	// clusterTaskItem is the item of cross_cluster_task and replication_dlq_task tables, shard_cluster is shard_id#cluster
+	clusterTaskItem struct {
+		ShardCluster string `dynamodbav:"shard_cluster"`
+		TaskID       int64  `dynamodbav:"task_id"`
+		Data         []byte `dynamodbav:"data"`
+	}
+
+	// workflowTransaction is a TransactWriteItems request of a workflow write.
+	// It remembers which item is for which purpose, so that the cancellation reasons can be mapped back.
+	workflowTransaction struct {
+		items                []*dynamodb.TransactWriteItem
+		currentWorkflowIndex int
+		executionIndexes     map[int]*executionWrite
+		// cleanups delete the entries that are not visible anymore once the transaction is applied.
+		// They are best effort, the entries left behind are deleted together with the workflow execution.
+		cleanups []func(ctx context.Context) error
+	}
+
+	executionWrite struct {
+		request  *nosqlplugin.WorkflowExecutionRequest
+		isCreate bool
+		// previous is the execution before the write, nil if isCreate is true
+		previous *executionItem
+	}
+)
+
+func currentWorkflowKey(shardID int, domainID, workflowID string) attributeMap {
+	return attributeMap{
+		"shard_id":     numberAttr(int64(shardID)),
+		"workflow_key": stringAttr(compositeKey(domainID, workflowID)),
+	}
+}
+
+func executionKey(shardID int, domainID, workflowID, runID string) attributeMap {
+	return attributeMap{
+		"shard_id":      numberAttr(int64(shardID)),
+		"execution_key": stringAttr(compositeKey(domainID, workflowID, runID)),
+	}
+}
+
+// entryKeyPrefix is the common prefix of the entry_key of all the entries of a workflow execution
+func entryKeyPrefix(domainID, workflowID, runID string) string {
+	return compositeKey(domainID, workflowID, runID, "")
+}
+
+func entryKey(shardID int, domainID, workflowID, runID, entryType, key string) attributeMap {
+	return attributeMap{
+		"shard_id":  numberAttr(int64(shardID)),
+		"entry_key": stringAttr(entryKeyPrefix(domainID, workflowID, runID) + compositeKey(entryType, key)),
+	}
+}
+
+func shardTaskKey(shardID int, taskID int64) attributeMap {
+	return attributeMap{
+		"shard_id": numberAttr(int64(shardID)),
+		"task_id":  numberAttr(taskID),
+	}
+}
+
+func timerKey(visibilityTimestamp time.Time, taskID int64) string {
+	return compositeKey(paddedTimestamp(visibilityTimestamp), paddedInt64(taskID))
+}
+
+func timerTaskKey(shardID int, visibilityTimestamp time.Time, taskID int64) attributeMap {
+	return attributeMap{
+		"shard_id":  numberAttr(int64(shardID)),
+		"timer_key": stringAttr(timerKey(visibilityTimestamp, taskID)),
+	}
+}
+
+func shardClusterKey(shardID int, cluster string) string {
+	return compositeKey(strconv.Itoa(shardID), cluster)
+}
+
+func clusterTaskKey(shardID int, cluster string, taskID int64) attributeMap {
+	return attributeMap{
+		"shard_cluster": stringAttr(shardClusterKey(shardID, cluster)),
+		"task_id":       numberAttr(taskID),
+	}
+}
+
+func newWorkflowTransaction() *workflowTransaction {
+	return &workflowTransaction{
+		currentWorkflowIndex: -1,
+		executionIndexes:     make(map[int]*executionWrite),
+	}
+}
+
+func (db *ddb) newShardConditionCheck(shardCondition *nosqlplugin.ShardCondition) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		ConditionCheck: &dynamodb.ConditionCheck{
+			TableName:           db.tableName(cadence.ShardTableName),
+			Key:                 shardKey(shardCondition.ShardID),
+			ConditionExpression: aws.String("range_id = :range_id"),
+			ExpressionAttributeValues: attributeMap{
+				":range_id": numberAttr(shardCondition.RangeID),
+			},
+			ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+		},
+	}
+}
+
+func (db *ddb) createOrUpdateCurrentWorkflow(
+	txn *workflowTransaction,
+	shardID int,
+	domainID, workflowID string,
+	request *nosqlplugin.CurrentWorkflowWriteRequest,
+) error {
+	if request.WriteMode == nosqlplugin.CurrentWorkflowWriteModeNoop {
+		return nil
+	}
+
+	row := request.Row
+	row.ShardID = shardID
+	row.DomainID = domainID
+	row.WorkflowID = workflowID
+	data, err := json.Marshal(&row)
+	if err != nil {
+		return err
+	}
+	item := &currentWorkflowItem{
+		ShardID:          shardID,
+		WorkflowKey:      compositeKey(domainID, workflowID),
+		CurrentRunID:     row.RunID,
+		LastWriteVersion: row.LastWriteVersion,
+		State:            row.State,
+		Data:             data,
+	}
+
+	var condition string
+	values := attributeMap{}
+	switch request.WriteMode {
+	case nosqlplugin.CurrentWorkflowWriteModeInsert:
+		condition = "attribute_not_exists(workflow_key)"
+		values = nil
+	case nosqlplugin.CurrentWorkflowWriteModeUpdate:
+		if request.Condition == nil || request.Condition.GetCurrentRunID() == "" {
+			return fmt.Errorf("CurrentWorkflowWriteModeUpdate require Condition.CurrentRunID")
+		}
+		condition = "current_run_id = :current_run_id"
+		values[":current_run_id"] = stringAttr(request.Condition.GetCurrentRunID())
+		if request.Condition.LastWriteVersion != nil && request.Condition.State != nil {
+			condition += " AND last_write_version = :last_write_version AND #state = :state"
+			values[":last_write_version"] = numberAttr(*request.Condition.LastWriteVersion)
+			values[":state"] = numberAttr(int64(*request.Condition.State))
+		}
+	default:
+		return fmt.Errorf("unknown mode %v", request.WriteMode)
+	}
+
+	var names map[string]*string
+	if request.WriteMode == nosqlplugin.CurrentWorkflowWriteModeUpdate && request.Condition.State != nil {
+		// state is a reserved word of DynamoDB
+		names = map[string]*string{"#state": aws.String("state")}
+	}
+	put, err := db.newPutTransactionItem(cadence.CurrentWorkflowTableName, item, condition, names, values)
+	if err != nil {
+		return err
+	}
+	txn.currentWorkflowIndex = len(txn.items)
+	txn.items = append(txn.items, put)
+	return nil
+}
+
+// createWorkflowExecutionWithMergeMaps adds the creation of a workflow execution into the transaction
+func (db *ddb) createWorkflowExecutionWithMergeMaps(
+	txn *workflowTransaction,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+) error {
+	if execution.MapsWriteMode != nosqlplugin.WorkflowExecutionMapsWriteModeCreate {
+		return fmt.Errorf("should only support WorkflowExecutionMapsWriteModeCreate")
+	}
+	item := &executionItem{}
+	entries := db.newExecutionEntries(shardID, execution, item.Generation)
+	if err := entries.merge(execution); err != nil {
+		return err
+	}
+	return db.putExecution(txn, shardID, execution, item, nil, entries)
+}
+
+// updateWorkflowExecutionAndEventBufferWithMergeAndDeleteMaps adds the update of a workflow execution into the transaction
+func (db *ddb) updateWorkflowExecutionAndEventBufferWithMergeAndDeleteMaps(
+	ctx context.Context,
+	txn *workflowTransaction,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+) error {
+	if execution.MapsWriteMode != nosqlplugin.WorkflowExecutionMapsWriteModeUpdate {
+		return fmt.Errorf("should only support WorkflowExecutionMapsWriteModeUpdate")
+	}
+	previous, err := db.selectExecutionCondition(ctx, shardID, execution)
+	if err != nil {
+		return err
+	}
+	item := *previous
+	entries := db.newExecutionEntries(shardID, execution, item.Generation)
+	if err := entries.merge(execution); err != nil {
+		return err
+	}
+	entries.delete(execution)
+
+	switch execution.EventBufferWriteMode {
+	case nosqlplugin.EventBufferWriteModeNone:
+	case nosqlplugin.EventBufferWriteModeAppend:
+		if err := entries.put(entryTypeBufferedEvents, paddedInt64(item.BufferedEventSeq), execution.NewBufferedEventBatch); err != nil {
+			return err
+		}
+		item.BufferedEventSeq++
+	case nosqlplugin.EventBufferWriteModeClear:
+		txn.cleanups = append(txn.cleanups, db.newBufferedEventsCleanup(shardID, execution, &item))
+		item.BufferStartSeq = item.BufferedEventSeq
+	default:
+		return fmt.Errorf("unknown EventBufferWriteMode %v", execution.EventBufferWriteMode)
+	}
+	return db.putExecution(txn, shardID, execution, &item, previous, entries)
+}
+
+// resetWorkflowExecutionAndMapsAndEventBuffer adds the reset of a workflow execution into the transaction
+func (db *ddb) resetWorkflowExecutionAndMapsAndEventBuffer(
+	ctx context.Context,
+	txn *workflowTransaction,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+) error {
+	if execution.MapsWriteMode != nosqlplugin.WorkflowExecutionMapsWriteModeReset {
+		return fmt.Errorf("should only support WorkflowExecutionMapsWriteModeReset")
+	}
+	if execution.EventBufferWriteMode != nosqlplugin.EventBufferWriteModeClear {
+		return fmt.Errorf("should only support EventBufferWriteModeClear")
+	}
+	previous, err := db.selectExecutionCondition(ctx, shardID, execution)
+	if err != nil {
+		return err
+	}
+	item := *previous
+	item.Generation++
+	item.BufferStartSeq = item.BufferedEventSeq
+	entries := db.newExecutionEntries(shardID, execution, item.Generation)
+	if err := entries.merge(execution); err != nil {
+		return err
+	}
+	txn.cleanups = append(txn.cleanups, db.newGenerationCleanup(shardID, execution, item.Generation))
+	return db.putExecution(txn, shardID, execution, &item, previous, entries)
+}
+
+// selectExecutionCondition reads the condition columns of an execution before updating it.
+// If the execution doesn't exist, an empty item is returned, the transaction will then fail on the condition.
+func (db *ddb) selectExecutionCondition(
+	ctx context.Context,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+) (*executionItem, error) {
+	if execution.PreviousNextEventIDCondition == nil {
+		return nil, fmt.Errorf("PreviousNextEventIDCondition is required for updating workflow execution")
+	}
+	resp, err := db.client.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		TableName:            db.tableName(cadence.WorkflowExecutionTableName),
+		Key:                  executionKey(shardID, execution.DomainID, execution.WorkflowID, execution.RunID),
+		ProjectionExpression: aws.String("next_event_id, generation, buffer_start_seq, buffered_event_seq"),
+		ConsistentRead:       aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	item := &executionItem{}
+	if err := dynamodbattribute.UnmarshalMap(resp.Item, item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// putExecution adds the put of an execution item and its entries into the transaction.
+// If previous is nil, the execution must not exist, otherwise the execution must not be changed since it was read,
+// and its next_event_id must match the PreviousNextEventIDCondition of the request.
+func (db *ddb) putExecution(
+	txn *workflowTransaction,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+	item *executionItem,
+	previous *executionItem,
+	entries *executionEntries,
+) error {
+	data := &executionData{
+		ExecutionInfo:    &execution.InternalWorkflowExecutionInfo,
+		VersionHistories: execution.VersionHistories,
+		LastWriteVersion: execution.LastWriteVersion,
+	}
+	if execution.Checksums != nil {
+		data.Checksum = *execution.Checksums
+	}
+	blob, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	item.ShardID = shardID
+	item.ExecutionKey = compositeKey(execution.DomainID, execution.WorkflowID, execution.RunID)
+	item.NextEventID = execution.NextEventID
+	item.Data = blob
+
+	var put *dynamodb.TransactWriteItem
+	if previous == nil {
+		put, err = db.newPutTransactionItem(cadence.WorkflowExecutionTableName, item, "attribute_not_exists(execution_key)", nil, nil)
+	} else {
+		put, err = db.newPutTransactionItem(cadence.WorkflowExecutionTableName, item,
+			"next_event_id = :previous_next_event_id AND generation = :previous_generation AND buffered_event_seq = :previous_buffered_event_seq",
+			nil, attributeMap{
+				":previous_next_event_id":      numberAttr(*execution.PreviousNextEventIDCondition),
+				":previous_generation":         numberAttr(previous.Generation),
+				":previous_buffered_event_seq": numberAttr(previous.BufferedEventSeq),
+			})
+	}
+	if err != nil {
+		return err
+	}
+	txn.executionIndexes[len(txn.items)] = &executionWrite{
+		request:  execution,
+		isCreate: previous == nil,
+		previous: previous,
+	}
+	txn.items = append(txn.items, put)
+	txn.items = append(txn.items, entries.items()...)
+	return nil
+}
+
+// executionEntries collects the writes of the entries of an execution.
+// A key can only appear once in a transaction, so a later write of the same key replaces the earlier one.
+type executionEntries struct {
+	db         *ddb
+	shardID    int
+	execution  *nosqlplugin.WorkflowExecutionRequest
+	generation int64
+	keys       []string
+	writes     map[string]*dynamodb.TransactWriteItem
+}
+
+func (db *ddb) newExecutionEntries(shardID int, execution *nosqlplugin.WorkflowExecutionRequest, generation int64) *executionEntries {
+	return &executionEntries{
+		db:         db,
+		shardID:    shardID,
+		execution:  execution,
+		generation: generation,
+		writes:     make(map[string]*dynamodb.TransactWriteItem),
+	}
+}
+
+func (e *executionEntries) set(key string, write *dynamodb.TransactWriteItem) {
+	if _, ok := e.writes[key]; !ok {
+		e.keys = append(e.keys, key)
+	}
+	e.writes[key] = write
+}
+
+func (e *executionEntries) put(entryType, key string, value interface{}) error {
+	var data []byte
+	if value != nil {
+		var err error
+		if data, err = json.Marshal(value); err != nil {
+			return err
+		}
+	}
+	k := entryKey(e.shardID, e.execution.DomainID, e.execution.WorkflowID, e.execution.RunID, entryType, key)
+	av, err := dynamodbattribute.MarshalMap(&executionEntryItem{
+		ShardID:    e.shardID,
+		EntryKey:   aws.StringValue(k["entry_key"].S),
+		WorkflowID: e.execution.WorkflowID,
+		RunID:      e.execution.RunID,
+		Generation: e.generation,
+		Data:       data,
+	})
+	if err != nil {
+		return err
+	}
+	e.set(aws.StringValue(k["entry_key"].S), &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			TableName: e.db.tableName(cadence.ExecutionEntryTableName),
+			Item:      av,
+		},
+	})
+	return nil
+}
+
+func (e *executionEntries) remove(entryType, key string) {
+	k := entryKey(e.shardID, e.execution.DomainID, e.execution.WorkflowID, e.execution.RunID, entryType, key)
+	e.set(aws.StringValue(k["entry_key"].S), &dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			TableName: e.db.tableName(cadence.ExecutionEntryTableName),
+			Key:       k,
+		},
+	})
+}
+
+// merge upserts the map entries of the request
+func (e *executionEntries) merge(execution *nosqlplugin.WorkflowExecutionRequest) error {
+	for k, v := range execution.ActivityInfos {
+		if err := e.put(entryTypeActivity, strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.TimerInfos {
+		if err := e.put(entryTypeTimer, k, v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.ChildWorkflowInfos {
+		if err := e.put(entryTypeChild, strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.RequestCancelInfos {
+		if err := e.put(entryTypeRequestCancel, strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.SignalInfos {
+		if err := e.put(entryTypeSignal, strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for _, k := range execution.SignalRequestedIDs {
+		if err := e.put(entryTypeSignalRequested, k, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delete deletes the map entries of the request
+func (e *executionEntries) delete(execution *nosqlplugin.WorkflowExecutionRequest) {
+	for _, k := range execution.ActivityInfoKeysToDelete {
+		e.remove(entryTypeActivity, strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.TimerInfoKeysToDelete {
+		e.remove(entryTypeTimer, k)
+	}
+	for _, k := range execution.ChildWorkflowInfoKeysToDelete {
+		e.remove(entryTypeChild, strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.RequestCancelInfoKeysToDelete {
+		e.remove(entryTypeRequestCancel, strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.SignalInfoKeysToDelete {
+		e.remove(entryTypeSignal, strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.SignalRequestedIDsKeysToDelete {
+		e.remove(entryTypeSignalRequested, k)
+	}
+}
+
+func (e *executionEntries) items() []*dynamodb.TransactWriteItem {
+	items := make([]*dynamodb.TransactWriteItem, 0, len(e.keys))
+	for _, key := range e.keys {
+		items = append(items, e.writes[key])
+	}
+	return items
+}
+
+// newBufferedEventsCleanup deletes the buffered events batches that are cleared by the transaction
+func (db *ddb) newBufferedEventsCleanup(
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+	previous *executionItem,
+) func(ctx context.Context) error {
+	var keys []attributeMap
+	for seq := previous.BufferStartSeq; seq < previous.BufferedEventSeq; seq++ {
+		keys = append(keys, entryKey(shardID, execution.DomainID, execution.WorkflowID, execution.RunID, entryTypeBufferedEvents, paddedInt64(seq)))
+	}
+	return func(ctx context.Context) error {
+		return db.batchDelete(ctx, cadence.ExecutionEntryTableName, keys)
+	}
+}
+
+// newGenerationCleanup deletes the entries of the generations before the reset
+func (db *ddb) newGenerationCleanup(
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+	generation int64,
+) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		_, err := db.deleteExecutionEntries(ctx, shardID, execution.DomainID, execution.WorkflowID, execution.RunID, &generation)
+		return err
+	}
+}
+
+// deleteExecutionEntries deletes the entries of an execution, only the generations before beforeGeneration if not nil
+func (db *ddb) deleteExecutionEntries(
+	ctx context.Context,
+	shardID int,
+	domainID, workflowID, runID string,
+	beforeGeneration *int64,
+) (int, error) {
+	filter := "workflow_id = :workflow_id AND run_id = :run_id"
+	values := attributeMap{
+		":shard_id":    numberAttr(int64(shardID)),
+		":prefix":      stringAttr(entryKeyPrefix(domainID, workflowID, runID)),
+		":workflow_id": stringAttr(workflowID),
+		":run_id":      stringAttr(runID),
+	}
+	if beforeGeneration != nil {
+		filter += " AND generation < :generation"
+		values[":generation"] = numberAttr(*beforeGeneration)
+	}
+	return db.deleteByQuery(ctx, cadence.ExecutionEntryTableName, &dynamodb.QueryInput{
+		KeyConditionExpression:    aws.String("shard_id = :shard_id AND begins_with(entry_key, :prefix)"),
+		FilterExpression:          aws.String(filter),
+		ExpressionAttributeValues: values,
+	}, "shard_id", "entry_key")
+}
+
+// selectExecution reads an execution and all its visible entries.
+// NOTE: the execution item and the entries are read separately, which is safe because all the writes of an
+// execution are done by the owner of the shard while holding the workflow lock.
+func (db *ddb) selectExecution(ctx context.Context, shardID int, domainID, workflowID, runID string) (*nosqlplugin.WorkflowExecution, error) {
+	var item executionItem
+	if err := db.getItem(ctx, cadence.WorkflowExecutionTableName, executionKey(shardID, domainID, workflowID, runID), &item); err != nil {
+		return nil, err
+	}
+	data := &executionData{}
+	if err := json.Unmarshal(item.Data, data); err != nil {
+		return nil, err
+	}
+
+	prefix := entryKeyPrefix(domainID, workflowID, runID)
+	avs, err := db.queryAll(ctx, &dynamodb.QueryInput{
+		TableName:              db.tableName(cadence.ExecutionEntryTableName),
+		KeyConditionExpression: aws.String("shard_id = :shard_id AND begins_with(entry_key, :prefix)"),
+		FilterExpression:       aws.String("workflow_id = :workflow_id AND run_id = :run_id AND generation = :generation"),
+		ExpressionAttributeValues: attributeMap{
+			":shard_id":    numberAttr(int64(shardID)),
+			":prefix":      stringAttr(prefix),
+			":workflow_id": stringAttr(workflowID),
+			":run_id":      stringAttr(runID),
+			":generation":  numberAttr(item.Generation),
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	state := &nosqlplugin.WorkflowExecution{
+		ExecutionInfo:       data.ExecutionInfo,
+		VersionHistories:    data.VersionHistories,
+		ActivityInfos:       make(map[int64]*p.InternalActivityInfo),
+		TimerInfos:          make(map[string]*p.TimerInfo),
+		ChildExecutionInfos: make(map[int64]*p.InternalChildExecutionInfo),
+		RequestCancelInfos:  make(map[int64]*p.RequestCancelInfo),
+		SignalInfos:         make(map[int64]*p.SignalInfo),
+		SignalRequestedIDs:  make(map[string]struct{}),
+		BufferedEvents:      make([]*p.DataBlob, 0),
+		Checksum:            data.Checksum,
+	}
+	for _, av := range avs {
+		var entry executionEntryItem
+		if err := dynamodbattribute.UnmarshalMap(av, &entry); err != nil {
+			return nil, err
+		}
+		// the entry_key is prefix + entry_type#key, and entry_type never contains the separator
+		parts := strings.SplitN(strings.TrimPrefix(entry.EntryKey, prefix), keySeparator, 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid entry key %v", entry.EntryKey)
+		}
+		if err := addEntry(state, parts[0], parts[1], entry.Data, &item); err != nil {
+			return nil, err
+		}
+	}
+	return state, nil
+}
+
+// addEntry decodes an entry into the map or the buffered events of the workflow execution
+func addEntry(state *nosqlplugin.WorkflowExecution, entryType, key string, data []byte, item *executionItem) error {
+	parseInt64 := func() (int64, error) {
+		return strconv.ParseInt(key, 10, 64)
+	}
+	switch entryType {
+	case entryTypeActivity:
+		id, err := parseInt64()
+		if err != nil {
+			return err
+		}
+		info := &p.InternalActivityInfo{}
+		if err := json.Unmarshal(data, info); err != nil {
+			return err
+		}
+		state.ActivityInfos[id] = info
+	case entryTypeTimer:
+		info := &p.TimerInfo{}
+		if err := json.Unmarshal(data, info); err != nil {
+			return err
+		}
+		state.TimerInfos[key] = info
+	case entryTypeChild:
+		id, err := parseInt64()
+		if err != nil {
+			return err
+		}
+		info := &p.InternalChildExecutionInfo{}
+		if err := json.Unmarshal(data, info); err != nil {
+			return err
+		}
+		state.ChildExecutionInfos[id] = info
+	case entryTypeRequestCancel:
+		id, err := parseInt64()
+		if err != nil {
+			return err
+		}
+		info := &p.RequestCancelInfo{}
+		if err := json.Unmarshal(data, info); err != nil {
+			return err
+		}
+		state.RequestCancelInfos[id] = info
+	case entryTypeSignal:
+		id, err := parseInt64()
+		if err != nil {
+			return err
+		}
+		info := &p.SignalInfo{}
+		if err := json.Unmarshal(data, info); err != nil {
+			return err
+		}
+		state.SignalInfos[id] = info
+	case entryTypeSignalRequested:
+		state.SignalRequestedIDs[key] = struct{}{}
+	case entryTypeBufferedEvents:
+		// the keys are the padded sequences, so the batches are returned in order and the cleared ones can be
+		// skipped by comparing the keys
+		if key < paddedInt64(item.BufferStartSeq) {
+			return nil
+		}
+		blob := &p.DataBlob{}
+		if err := json.Unmarshal(data, blob); err != nil {
+			return err
+		}
+		state.BufferedEvents = append(state.BufferedEvents, blob)
+	default:
+		return fmt.Errorf("unknown entry type %v", entryType)
+	}
+	return nil
+}
+
+// createTasks builds the transaction items for all the tasks of a workflow write
+func (db *ddb) createTasks(
+	shardID int,
+	transferTasks []*nosqlplugin.TransferTask,
+	crossClusterTasks []*nosqlplugin.CrossClusterTask,
+	replicationTasks []*nosqlplugin.ReplicationTask,
+	timerTasks []*nosqlplugin.TimerTask,
+) ([]*dynamodb.TransactWriteItem, error) {
+	var items []*dynamodb.TransactWriteItem
+	add := func(table string, item interface{}) error {
+		put, err := db.newPutTransactionItem(table, item, "", nil, nil)
+		if err != nil {
+			return err
+		}
+		items = append(items, put)
+		return nil
+	}
+
+	for _, task := range transferTasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(cadence.TransferTaskTableName, &shardTaskItem{
+			ShardID: shardID,
+			TaskID:  task.TaskID,
+			Data:    data,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, task := range crossClusterTasks {
+		data, err := json.Marshal(&task.TransferTask)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(cadence.CrossClusterTaskTableName, &clusterTaskItem{
+			ShardCluster: shardClusterKey(shardID, task.TargetCluster),
+			TaskID:       task.TaskID,
+			Data:         data,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, task := range replicationTasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(cadence.ReplicationTaskTableName, &shardTaskItem{
+			ShardID: shardID,
+			TaskID:  task.TaskID,
+			Data:    data,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	for _, task := range timerTasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(cadence.TimerTaskTableName, &timerTaskItem{
+			ShardID:  shardID,
+			TimerKey: timerKey(task.VisibilityTimestamp, task.TaskID),
+			Data:     data,
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// executeWorkflowTransaction executes the workflow transaction together with the tasks and the shard condition,
+// as one single DynamoDB transaction.
+// A workflow write that doesn't fit into one transaction(see maxTransactionItems, maxItemSize and
+// maxTransactionSize) fails with TransactionSizeLimitError and nothing is written.
+func (db *ddb) executeWorkflowTransaction(
+	ctx context.Context,
+	txn *workflowTransaction,
+	tasks []*dynamodb.TransactWriteItem,
+	currentWorkflowRequest *nosqlplugin.CurrentWorkflowWriteRequest,
+	shardCondition *nosqlplugin.ShardCondition,
+) error {
+	shardIndex := len(txn.items) + len(tasks)
+	items := append(append(txn.items, tasks...), db.newShardConditionCheck(shardCondition))
+	if err := checkTransactionSize(items); err != nil {
+		return err
+	}
+
+	reasons, err := db.executeTransaction(ctx, items)
+	if err == nil {
+		for _, cleanup := range txn.cleanups {
+			if err := cleanup(ctx); err != nil && db.logger != nil {
+				db.logger.Warn("Failed to delete invisible workflow execution entries", tag.Error(err))
+			}
+		}
+		return nil
+	}
+	if !db.IsConditionFailedError(err) {
+		return err
+	}
+
+	if isConditionFailedReason(reasons, shardIndex) {
+		return db.getShardConditionFailure(ctx, reasons, shardIndex, shardCondition)
+	}
+	if isConditionFailedReason(reasons, txn.currentWorkflowIndex) {
+		return db.getCurrentWorkflowConditionFailure(ctx, reasons[txn.currentWorkflowIndex], currentWorkflowRequest, shardCondition)
+	}
+	for index, write := range txn.executionIndexes {
+		if isConditionFailedReason(reasons, index) {
+			return db.getExecutionConditionFailure(ctx, reasons[index], write, currentWorkflowRequest, shardCondition)
+		}
+	}
+	return newUnknownConditionFailureReason(shardCondition.RangeID, reasons)
+}
+
+// checkTransactionSize returns TransactionSizeLimitError if the transaction exceeds any of the DynamoDB limits
+func checkTransactionSize(items []*dynamodb.TransactWriteItem) error {
+	if len(items) > maxTransactionItems {
+		return &p.TransactionSizeLimitError{
+			Msg: fmt.Sprintf("transaction has %v items, exceeding the limit of %v items", len(items), maxTransactionItems),
+		}
+	}
+	total := 0
+	for _, item := range items {
+		size := 0
+		switch {
+		case item.Put != nil:
+			size = attributeMapSize(item.Put.Item)
+		case item.Delete != nil:
+			size = attributeMapSize(item.Delete.Key)
+		case item.Update != nil:
+			size = attributeMapSize(item.Update.Key)
+		case item.ConditionCheck != nil:
+			size = attributeMapSize(item.ConditionCheck.Key)
+		}
+		if size > maxItemSize {
+			return &p.TransactionSizeLimitError{
+				Msg: fmt.Sprintf("item size %v exceeds the limit of %v bytes", size, maxItemSize),
+			}
+		}
+		total += size
+	}
+	if total > maxTransactionSize {
+		return &p.TransactionSizeLimitError{
+			Msg: fmt.Sprintf("transaction size %v exceeds the limit of %v bytes", total, maxTransactionSize),
+		}
+	}
+	return nil
+}
+
+func (db *ddb) getShardConditionFailure(
+	ctx context.Context,
+	reasons []*dynamodb.CancellationReason,
+	shardIndex int,
+	shardCondition *nosqlplugin.ShardCondition,
+) error {
+	var shard shardItem
+	if err := db.unmarshalOldItem(ctx, reasons[shardIndex], cadence.ShardTableName, shardKey(shardCondition.ShardID), &shard); err != nil {
+		return err
+	}
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		ShardRangeIDNotMatch: common.Int64Ptr(shard.RangeID),
+	}
+}
+
+func (db *ddb) getCurrentWorkflowConditionFailure(
+	ctx context.Context,
+	reason *dynamodb.CancellationReason,
+	request *nosqlplugin.CurrentWorkflowWriteRequest,
+	shardCondition *nosqlplugin.ShardCondition,
+) error {
+	row := request.Row
+	var current currentWorkflowItem
+	err := db.unmarshalOldItem(ctx, reason, cadence.CurrentWorkflowTableName,
+		currentWorkflowKey(shardCondition.ShardID, row.DomainID, row.WorkflowID), &current)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Workflow execution condition failed, current workflow doesn't exist. WorkflowId: %v, Expected Current RunID: %v",
+			row.WorkflowID, request.Condition.GetCurrentRunID())
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			CurrentWorkflowConditionFailInfo: &msg,
+		}
+	}
+	previous := &nosqlplugin.CurrentWorkflowRow{}
+	if err := json.Unmarshal(current.Data, previous); err != nil {
+		return err
+	}
+
+	if request.WriteMode == nosqlplugin.CurrentWorkflowWriteModeInsert {
+		msg := fmt.Sprintf("Workflow execution already running. WorkflowId: %v, RunId: %v, rangeID: %v",
+			previous.WorkflowID, previous.RunID, shardCondition.RangeID)
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			WorkflowExecutionAlreadyExists: &nosqlplugin.WorkflowExecutionAlreadyExists{
+				OtherInfo:        msg,
+				CreateRequestID:  previous.CreateRequestID,
+				RunID:            previous.RunID,
+				State:            previous.State,
+				CloseStatus:      previous.CloseStatus,
+				LastWriteVersion: previous.LastWriteVersion,
+			},
+		}
+	}
+
+	if requestRunID := request.Condition.GetCurrentRunID(); current.CurrentRunID != requestRunID {
+		msg := fmt.Sprintf("Workflow execution condition failed by mismatch runID. WorkflowId: %v, Expected Current RunID: %v, Actual Current RunID: %v",
+			row.WorkflowID, requestRunID, current.CurrentRunID)
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			CurrentWorkflowConditionFailInfo: &msg,
+		}
+	}
+	msg := fmt.Sprintf("Workflow execution condition failed. WorkflowId: %v, CurrentRunID: %v, Actual LastWriteVersion: %v, Actual State: %v",
+		row.WorkflowID, current.CurrentRunID, current.LastWriteVersion, current.State)
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		CurrentWorkflowConditionFailInfo: &msg,
+	}
+}
+
+func (db *ddb) getExecutionConditionFailure(
+	ctx context.Context,
+	reason *dynamodb.CancellationReason,
+	write *executionWrite,
+	currentWorkflowRequest *nosqlplugin.CurrentWorkflowWriteRequest,
+	shardCondition *nosqlplugin.ShardCondition,
+) error {
+	execution := write.request
+	if write.isCreate {
+		msg := fmt.Sprintf("Workflow execution already running. WorkflowId: %v, RunId: %v, rangeID: %v",
+			execution.WorkflowID, execution.RunID, shardCondition.RangeID)
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			WorkflowExecutionAlreadyExists: &nosqlplugin.WorkflowExecutionAlreadyExists{
+				OtherInfo:        msg,
+				CreateRequestID:  execution.CreateRequestID,
+				RunID:            execution.RunID,
+				State:            execution.State,
+				CloseStatus:      execution.CloseStatus,
+				LastWriteVersion: execution.LastWriteVersion,
+			},
+		}
+	}
+
+	var actual executionItem
+	err := db.unmarshalOldItem(ctx, reason, cadence.WorkflowExecutionTableName,
+		executionKey(shardCondition.ShardID, execution.DomainID, execution.WorkflowID, execution.RunID), &actual)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	requestConditionalRunID := ""
+	if currentWorkflowRequest.Condition != nil {
+		requestConditionalRunID = currentWorkflowRequest.Condition.GetCurrentRunID()
+	}
+	msg := fmt.Sprintf("Failed to update mutable state.  Request Condition: %v, Actual Value: %v, Request Current RunID: %v, Request Generation: %v, Actual Value: %v",
+		*execution.PreviousNextEventIDCondition, actual.NextEventID, requestConditionalRunID, write.previous.Generation, actual.Generation)
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		UnknownConditionFailureDetails: &msg,
+	}
+}
+
+// unmarshalOldItem decodes the item returned with the cancellation reason.
+// Some endpoints don't return the item, in which case it's read again.
+func (db *ddb) unmarshalOldItem(
+	ctx context.Context,
+	reason *dynamodb.CancellationReason,
+	table string,
+	key attributeMap,
+	out interface{},
+) error {
+	if reason != nil && len(reason.Item) > 0 {
+		return dynamodbattribute.UnmarshalMap(reason.Item, out)
+	}
+	return db.getItem(ctx, table, key, out)
+}
+
+func newUnknownConditionFailureReason(
+	rangeID int64,
+	reasons []*dynamodb.CancellationReason,
+) *nosqlplugin.WorkflowOperationConditionFailure {
+	// At this point we only know that the write was not applied.
+	// It's much safer to return ShardOwnershipLostError as the default to force the application to reload
+	// shard to recover from such errors
+	var codes []string
+	for _, reason := range reasons {
+		if reason != nil {
+			codes = append(codes, aws.StringValue(reason.Code))
+		}
+	}
+	msg := fmt.Sprintf("Failed to operate on workflow execution.  Request RangeID: %v, cancellation reasons: %v",
+		rangeID, codes)
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		UnknownConditionFailureDetails: &msg,
+	}
+}
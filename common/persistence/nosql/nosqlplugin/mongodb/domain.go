@@ -22,26 +22,124 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/log/tag"
+	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
-	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin/cassandra/gocql"
+	"github.com/uber/cadence/common/types"
+	"github.com/uber/cadence/schema/mongodb/cadence"
 )
 
-// Insert a new record to domain, return error if failed or already exists
-// Return ConditionFailure if the condition doesn't meet
+// domain_metadata has only one document, it's okay because domain is serving very small volume of traffic
+const constDomainMetadataPartition = 0
+
+func newDomainEntry(row *nosqlplugin.DomainRow) (*cadence.DomainCollectionEntry, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	return &cadence.DomainCollectionEntry{
+		Name:     row.Info.Name,
+		DomainID: row.Info.ID,
+		Data:     data,
+	}, nil
+}
+
+func toDomainRow(raw bson.Raw) (*nosqlplugin.DomainRow, error) {
+	var entry cadence.DomainCollectionEntry
+	if err := bson.Unmarshal(raw, &entry); err != nil {
+		return nil, err
+	}
+	row := &nosqlplugin.DomainRow{}
+	if err := json.Unmarshal(entry.Data, row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// updateMetadata increases the notification version by one, conditioned on the current notification version
+func (db *mdb) updateMetadata(
+	sessCtx mongo.SessionContext,
+	notificationVersion int64,
+) error {
+	collection := db.dbConn.Collection(cadence.DomainMetadataCollectionName)
+	filter := bson.D{
+		{Key: "partition", Value: constDomainMetadataPartition},
+		{Key: "notificationversion", Value: notificationVersion},
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "notificationversion", Value: notificationVersion + 1}}}}
+	// the metadata document doesn't exist before the first domain is created
+	result, err := collection.UpdateOne(sessCtx, filter, update, options.Update().SetUpsert(notificationVersion == 0))
+	if mongo.IsDuplicateKeyError(err) {
+		return errConditionFailed
+	}
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 && result.UpsertedCount == 0 {
+		return errConditionFailed
+	}
+	return nil
+}
+
+// Insert a new record to domain
+// return types.DomainAlreadyExistsError error if failed or already exists
+// Must return ConditionFailure error if other condition doesn't match
 func (db *mdb) InsertDomain(
 	ctx context.Context,
 	row *nosqlplugin.DomainRow,
 ) error {
-	panic("TODO")
-}
+	metadataNotificationVersion, err := db.SelectDomainMetadata(ctx)
+	if err != nil {
+		return err
+	}
 
-func (db *mdb) updateMetadataBatch(
-	ctx context.Context,
-	batch gocql.Batch,
-	notificationVersion int64,
-) {
-	panic("TODO")
+	domain := *row
+	domain.NotificationVersion = metadataNotificationVersion
+	domain.FailoverNotificationVersion = p.InitialFailoverNotificationVersion
+	domain.PreviousFailoverVersion = common.InitialPreviousFailoverVersion
+	entry, err := newDomainEntry(&domain)
+	if err != nil {
+		return err
+	}
+
+	collection := db.dbConn.Collection(cadence.DomainCollectionName)
+	err = db.executeTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		count, err := collection.CountDocuments(sessCtx, bson.D{{Key: "name", Value: row.Info.Name}})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			db.logger.Warn("Domain already exists", tag.WorkflowDomainName(row.Info.Name))
+			return &types.DomainAlreadyExistsError{
+				Message: fmt.Sprintf("Domain %v already exists", row.Info.Name),
+			}
+		}
+		count, err = collection.CountDocuments(sessCtx, bson.D{{Key: "domainid", Value: row.Info.ID}})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return fmt.Errorf("CreateDomain operation failed because of uuid collision")
+		}
+
+		if _, err := collection.InsertOne(sessCtx, entry); err != nil {
+			return err
+		}
+		return db.updateMetadata(sessCtx, metadataNotificationVersion)
+	})
+	if err == errConditionFailed || mongo.IsDuplicateKeyError(err) {
+		db.logger.Warn("Create domain operation failed because of condition update failure on domain metadata record")
+		return nosqlplugin.NewConditionFailure("domain")
+	}
+	return err
 }
 
 // Update domain
@@ -49,7 +147,21 @@ func (db *mdb) UpdateDomain(
 	ctx context.Context,
 	row *nosqlplugin.DomainRow,
 ) error {
-	panic("TODO")
+	entry, err := newDomainEntry(row)
+	if err != nil {
+		return err
+	}
+	err = db.executeTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		_, err := db.dbConn.Collection(cadence.DomainCollectionName).ReplaceOne(sessCtx, bson.D{{Key: "name", Value: row.Info.Name}}, entry)
+		if err != nil {
+			return err
+		}
+		return db.updateMetadata(sessCtx, row.NotificationVersion)
+	})
+	if err == errConditionFailed {
+		return nosqlplugin.NewConditionFailure("domain")
+	}
+	return err
 }
 
 // Get one domain data, either by domainID or domainName
@@ -58,7 +170,22 @@ func (db *mdb) SelectDomain(
 	domainID *string,
 	domainName *string,
 ) (*nosqlplugin.DomainRow, error) {
-	panic("TODO")
+	var filter bson.D
+	if domainID != nil && domainName != nil {
+		return nil, fmt.Errorf("GetDomain operation failed.  Both ID and Name specified in request")
+	} else if domainID == nil && domainName == nil {
+		return nil, fmt.Errorf("GetDomain operation failed.  Both ID and Name are empty")
+	} else if domainID != nil {
+		filter = bson.D{{Key: "domainid", Value: *domainID}}
+	} else {
+		filter = bson.D{{Key: "name", Value: *domainName}}
+	}
+
+	raw, err := db.dbConn.Collection(cadence.DomainCollectionName).FindOne(ctx, filter).DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+	return toDomainRow(raw)
 }
 
 // Get all domain data
@@ -67,20 +194,52 @@ func (db *mdb) SelectAllDomains(
 	pageSize int,
 	pageToken []byte,
 ) ([]*nosqlplugin.DomainRow, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.DomainCollectionName, bson.D{}, bson.D{{Key: "name", Value: 1}}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows := make([]*nosqlplugin.DomainRow, 0, len(docs))
+	for _, doc := range docs {
+		row, err := toDomainRow(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nextPageToken, nil
 }
 
-//  Delete a domain, either by domainID or domainName
+// Delete a domain, either by domainID or domainName
 func (db *mdb) DeleteDomain(
 	ctx context.Context,
 	domainID *string,
 	domainName *string,
 ) error {
-	panic("TODO")
+	var filter bson.D
+	if domainName != nil {
+		filter = bson.D{{Key: "name", Value: *domainName}}
+	} else if domainID != nil {
+		filter = bson.D{{Key: "domainid", Value: *domainID}}
+	} else {
+		return fmt.Errorf("must provide either domainID or domainName")
+	}
+	_, err := db.dbConn.Collection(cadence.DomainCollectionName).DeleteOne(ctx, filter)
+	return err
 }
 
 func (db *mdb) SelectDomainMetadata(
 	ctx context.Context,
 ) (int64, error) {
-	panic("TODO")
+	var entry cadence.DomainMetadataCollectionEntry
+	err := db.dbConn.Collection(cadence.DomainMetadataCollectionName).
+		FindOne(ctx, bson.D{{Key: "partition", Value: constDomainMetadataPartition}}).Decode(&entry)
+	if err != nil {
+		if db.IsNotFoundError(err) {
+			// this error can be thrown in the very beginning,
+			// i.e. when domain_metadata is initialized
+			return 0, nil
+		}
+		return 0, err
+	}
+	return entry.NotificationVersion, nil
 }
@@ -22,31 +22,219 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/common/types"
+	"github.com/uber/cadence/schema/mongodb/cadence"
 )
 
+// historyTreeData is the non-significant fields of history_tree
+type historyTreeData struct {
+	Ancestors       []*types.HistoryBranchRange `json:"ancestors"`
+	CreateTimestamp time.Time                   `json:"create_timestamp"`
+	Info            string                      `json:"info"`
+}
+
+// the nodes are sorted by nodeID ASC, txnID DESC
+var historyNodeSort = bson.D{{Key: "nodeid", Value: 1}, {Key: "txnid", Value: -1}}
+
+func historyNodeFilter(filter *nosqlplugin.HistoryNodeFilter, nodeIDCondition bson.D) bson.D {
+	return bson.D{
+		{Key: "treeid", Value: filter.TreeID},
+		{Key: "branchid", Value: filter.BranchID},
+		{Key: "nodeid", Value: nodeIDCondition},
+	}
+}
+
+func toHistoryTreeRow(doc bson.Raw) (*nosqlplugin.HistoryTreeRow, error) {
+	var entry cadence.HistoryTreeCollectionEntry
+	if err := bson.Unmarshal(doc, &entry); err != nil {
+		return nil, err
+	}
+	var data historyTreeData
+	if err := json.Unmarshal(entry.Data, &data); err != nil {
+		return nil, err
+	}
+	ancestors := data.Ancestors
+	if len(ancestors) > 0 {
+		// sort ans based onf EndNodeID so that we can set BeginNodeID
+		sort.Slice(ancestors, func(i, j int) bool { return ancestors[i].EndNodeID < ancestors[j].EndNodeID })
+		ancestors[0].BeginNodeID = int64(1)
+		for i := 1; i < len(ancestors); i++ {
+			ancestors[i].BeginNodeID = ancestors[i-1].EndNodeID
+		}
+	}
+	return &nosqlplugin.HistoryTreeRow{
+		ShardID:         entry.ShardID,
+		TreeID:          entry.TreeID,
+		BranchID:        entry.BranchID,
+		Ancestors:       ancestors,
+		CreateTimestamp: data.CreateTimestamp,
+		Info:            data.Info,
+	}, nil
+}
+
+func toHistoryTreeRows(docs []bson.Raw) ([]*nosqlplugin.HistoryTreeRow, error) {
+	rows := make([]*nosqlplugin.HistoryTreeRow, 0, len(docs))
+	for _, doc := range docs {
+		row, err := toHistoryTreeRow(doc)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
 // InsertIntoHistoryTreeAndNode inserts one or two rows: tree row and node row(at least one of them)
 func (db *mdb) InsertIntoHistoryTreeAndNode(ctx context.Context, treeRow *nosqlplugin.HistoryTreeRow, nodeRow *nosqlplugin.HistoryNodeRow) error {
-	panic("TODO")
+	if treeRow == nil && nodeRow == nil {
+		return fmt.Errorf("require at least a tree row or a node row to insert")
+	}
+
+	var treeEntry *cadence.HistoryTreeCollectionEntry
+	if treeRow != nil {
+		data, err := json.Marshal(&historyTreeData{
+			Ancestors:       treeRow.Ancestors,
+			CreateTimestamp: treeRow.CreateTimestamp,
+			Info:            treeRow.Info,
+		})
+		if err != nil {
+			return err
+		}
+		treeEntry = &cadence.HistoryTreeCollectionEntry{
+			ShardID:  treeRow.ShardID,
+			TreeID:   treeRow.TreeID,
+			BranchID: treeRow.BranchID,
+			Data:     data,
+		}
+	}
+	var nodeEntry *cadence.HistoryNodeCollectionEntry
+	if nodeRow != nil {
+		nodeEntry = &cadence.HistoryNodeCollectionEntry{
+			ShardID:      nodeRow.ShardID,
+			TreeID:       nodeRow.TreeID,
+			BranchID:     nodeRow.BranchID,
+			NodeID:       nodeRow.NodeID,
+			TxnID:        common.Int64Default(nodeRow.TxnID),
+			Data:         nodeRow.Data,
+			DataEncoding: nodeRow.DataEncoding,
+		}
+	}
+
+	// like Cassandra, the insert overwrites the existing document
+	upsert := options.Replace().SetUpsert(true)
+	insert := func(ctx context.Context) error {
+		if treeEntry != nil {
+			filter := bson.D{
+				{Key: "treeid", Value: treeEntry.TreeID},
+				{Key: "branchid", Value: treeEntry.BranchID},
+			}
+			if _, err := db.dbConn.Collection(cadence.HistoryTreeCollectionName).ReplaceOne(ctx, filter, treeEntry, upsert); err != nil {
+				return err
+			}
+		}
+		if nodeEntry != nil {
+			filter := bson.D{
+				{Key: "treeid", Value: nodeEntry.TreeID},
+				{Key: "branchid", Value: nodeEntry.BranchID},
+				{Key: "nodeid", Value: nodeEntry.NodeID},
+				{Key: "txnid", Value: nodeEntry.TxnID},
+			}
+			if _, err := db.dbConn.Collection(cadence.HistoryNodeCollectionName).ReplaceOne(ctx, filter, nodeEntry, upsert); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if treeEntry == nil || nodeEntry == nil {
+		// single document write doesn't need a transaction
+		return insert(ctx)
+	}
+	return db.executeTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		return insert(sessCtx)
+	})
 }
 
 // SelectFromHistoryNode read nodes based on a filter
 func (db *mdb) SelectFromHistoryNode(ctx context.Context, filter *nosqlplugin.HistoryNodeFilter) ([]*nosqlplugin.HistoryNodeRow, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.HistoryNodeCollectionName,
+		historyNodeFilter(filter, bson.D{{Key: "$gte", Value: filter.MinNodeID}, {Key: "$lt", Value: filter.MaxNodeID}}),
+		historyNodeSort, filter.PageSize, filter.NextPageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rows := make([]*nosqlplugin.HistoryNodeRow, 0, len(docs))
+	for _, doc := range docs {
+		var entry cadence.HistoryNodeCollectionEntry
+		if err := bson.Unmarshal(doc, &entry); err != nil {
+			return nil, nil, err
+		}
+		txnID := entry.TxnID
+		rows = append(rows, &nosqlplugin.HistoryNodeRow{
+			ShardID:      entry.ShardID,
+			TreeID:       entry.TreeID,
+			BranchID:     entry.BranchID,
+			NodeID:       entry.NodeID,
+			TxnID:        &txnID,
+			Data:         entry.Data,
+			DataEncoding: entry.DataEncoding,
+		})
+	}
+	return rows, nextPageToken, nil
 }
 
 // DeleteFromHistoryTreeAndNode delete a branch record, and a list of ranges of nodes.
 func (db *mdb) DeleteFromHistoryTreeAndNode(ctx context.Context, treeFilter *nosqlplugin.HistoryTreeFilter, nodeFilters []*nosqlplugin.HistoryNodeFilter) error {
-	panic("TODO")
+	return db.executeTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		filter := bson.D{
+			{Key: "treeid", Value: treeFilter.TreeID},
+			{Key: "branchid", Value: common.StringDefault(treeFilter.BranchID)},
+		}
+		if _, err := db.dbConn.Collection(cadence.HistoryTreeCollectionName).DeleteOne(sessCtx, filter); err != nil {
+			return err
+		}
+		for _, nodeFilter := range nodeFilters {
+			_, err := db.dbConn.Collection(cadence.HistoryNodeCollectionName).DeleteMany(sessCtx,
+				historyNodeFilter(nodeFilter, bson.D{{Key: "$gte", Value: nodeFilter.MinNodeID}}))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // SelectAllHistoryTrees will return all tree branches with pagination
 func (db *mdb) SelectAllHistoryTrees(ctx context.Context, nextPageToken []byte, pageSize int) ([]*nosqlplugin.HistoryTreeRow, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.HistoryTreeCollectionName, bson.D{},
+		bson.D{{Key: "treeid", Value: 1}, {Key: "branchid", Value: 1}}, pageSize, nextPageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, err := toHistoryTreeRows(docs)
+	if err != nil {
+		return nil, nil, err
+	}
+	return rows, nextPageToken, nil
 }
 
 // SelectFromHistoryTree read branch records for a tree
 func (db *mdb) SelectFromHistoryTree(ctx context.Context, filter *nosqlplugin.HistoryTreeFilter) ([]*nosqlplugin.HistoryTreeRow, error) {
-	panic("TODO")
+	docs, err := db.findAll(ctx, cadence.HistoryTreeCollectionName, bson.D{{Key: "treeid", Value: filter.TreeID}},
+		bson.D{{Key: "branchid", Value: 1}})
+	if err != nil {
+		return nil, err
+	}
+	return toHistoryTreeRows(docs)
 }
@@ -22,19 +22,59 @@ package mongodb
 
 import (
 	"context"
-	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/mongodb/cadence"
 )
 
-//Insert message into queue, return error if failed or already exists
+func queueFilter(queueType persistence.QueueType) bson.D {
+	return bson.D{{Key: "queuetype", Value: int(queueType)}}
+}
+
+// messageIDFilter filters the messages of the queue in the range of (exclusiveBegin, inclusiveEnd]
+func messageIDFilter(queueType persistence.QueueType, exclusiveBegin, inclusiveEnd int64) bson.D {
+	return append(queueFilter(queueType), bson.E{Key: "messageid", Value: bson.D{
+		{Key: "$gt", Value: exclusiveBegin},
+		{Key: "$lte", Value: inclusiveEnd},
+	}})
+}
+
+func toQueueMessageRows(docs []bson.Raw) ([]nosqlplugin.QueueMessageRow, error) {
+	rows := make([]nosqlplugin.QueueMessageRow, 0, len(docs))
+	for _, doc := range docs {
+		var entry cadence.QueueMessageCollectionEntry
+		if err := bson.Unmarshal(doc, &entry); err != nil {
+			return nil, err
+		}
+		rows = append(rows, nosqlplugin.QueueMessageRow{
+			QueueType: persistence.QueueType(entry.QueueType),
+			ID:        entry.MessageID,
+			Payload:   entry.Payload,
+		})
+	}
+	return rows, nil
+}
+
+// Insert message into queue, return error if failed or already exists
 // Return ConditionFailure if the condition doesn't meet
 func (db *mdb) InsertIntoQueue(
 	ctx context.Context,
 	row *nosqlplugin.QueueMessageRow,
 ) error {
-	panic("TODO")
+	_, err := db.dbConn.Collection(cadence.QueueMessageCollectionName).InsertOne(ctx, &cadence.QueueMessageCollectionEntry{
+		QueueType: int(row.QueueType),
+		MessageID: row.ID,
+		Payload:   row.Payload,
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		return nosqlplugin.NewConditionFailure("queue")
+	}
+	return err
 }
 
 // Get the ID of last message inserted into the queue
@@ -42,7 +82,14 @@ func (db *mdb) SelectLastEnqueuedMessageID(
 	ctx context.Context,
 	queueType persistence.QueueType,
 ) (int64, error) {
-	panic("TODO")
+	var entry cadence.QueueMessageCollectionEntry
+	err := db.dbConn.Collection(cadence.QueueMessageCollectionName).
+		FindOne(ctx, queueFilter(queueType), options.FindOne().SetSort(bson.D{{Key: "messageid", Value: -1}})).
+		Decode(&entry)
+	if err != nil {
+		return 0, err
+	}
+	return entry.MessageID, nil
 }
 
 // Read queue messages starting from the exclusiveBeginMessageID
@@ -52,7 +99,20 @@ func (db *mdb) SelectMessagesFrom(
 	exclusiveBeginMessageID int64,
 	maxRows int,
 ) ([]*nosqlplugin.QueueMessageRow, error) {
-	panic("TODO")
+	filter := append(queueFilter(queueType), bson.E{Key: "messageid", Value: bson.D{{Key: "$gt", Value: exclusiveBeginMessageID}}})
+	docs, _, err := db.findPage(ctx, cadence.QueueMessageCollectionName, filter, bson.D{{Key: "messageid", Value: 1}}, maxRows, nil)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := toQueueMessageRows(docs)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*nosqlplugin.QueueMessageRow, 0, len(rows))
+	for i := range rows {
+		result = append(result, &rows[i])
+	}
+	return result, nil
 }
 
 // Read queue message starting from exclusiveBeginMessageID int64, inclusiveEndMessageID int64
@@ -60,7 +120,20 @@ func (db *mdb) SelectMessagesBetween(
 	ctx context.Context,
 	request nosqlplugin.SelectMessagesBetweenRequest,
 ) (*nosqlplugin.SelectMessagesBetweenResponse, error) {
-	panic("TODO")
+	filter := messageIDFilter(request.QueueType, request.ExclusiveBeginMessageID, request.InclusiveEndMessageID)
+	docs, nextPageToken, err := db.findPage(ctx, cadence.QueueMessageCollectionName, filter,
+		bson.D{{Key: "messageid", Value: 1}}, request.PageSize, request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := toQueueMessageRows(docs)
+	if err != nil {
+		return nil, err
+	}
+	return &nosqlplugin.SelectMessagesBetweenResponse{
+		Rows:          rows,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
 // Delete all messages before exclusiveBeginMessageID
@@ -69,7 +142,9 @@ func (db *mdb) DeleteMessagesBefore(
 	queueType persistence.QueueType,
 	exclusiveBeginMessageID int64,
 ) error {
-	panic("TODO")
+	filter := append(queueFilter(queueType), bson.E{Key: "messageid", Value: bson.D{{Key: "$lt", Value: exclusiveBeginMessageID}}})
+	_, err := db.dbConn.Collection(cadence.QueueMessageCollectionName).DeleteMany(ctx, filter)
+	return err
 }
 
 // Delete all messages in a range between exclusiveBeginMessageID and inclusiveEndMessageID
@@ -79,7 +154,9 @@ func (db *mdb) DeleteMessagesInRange(
 	exclusiveBeginMessageID int64,
 	inclusiveEndMessageID int64,
 ) error {
-	panic("TODO")
+	filter := messageIDFilter(queueType, exclusiveBeginMessageID, inclusiveEndMessageID)
+	_, err := db.dbConn.Collection(cadence.QueueMessageCollectionName).DeleteMany(ctx, filter)
+	return err
 }
 
 // Delete one message
@@ -88,7 +165,9 @@ func (db *mdb) DeleteMessage(
 	queueType persistence.QueueType,
 	messageID int64,
 ) error {
-	panic("TODO")
+	filter := append(queueFilter(queueType), bson.E{Key: "messageid", Value: messageID})
+	_, err := db.dbConn.Collection(cadence.QueueMessageCollectionName).DeleteOne(ctx, filter)
+	return err
 }
 
 // Insert an empty metadata row, starting from a version
@@ -97,8 +176,16 @@ func (db *mdb) InsertQueueMetadata(
 	queueType persistence.QueueType,
 	version int64,
 ) error {
-	fmt.Println("not implemented, ignore the eror for testing")
-	return nil
+	_, err := db.dbConn.Collection(cadence.QueueMetadataCollectionName).InsertOne(ctx, &cadence.QueueMetadataCollectionEntry{
+		QueueType:        int(queueType),
+		Version:          version,
+		ClusterAckLevels: map[string]int64{},
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// it's ok if the document exists already.
+		return nil
+	}
+	return err
 }
 
 // **Conditionally** update a queue metadata row, if current version is matched(meaning current == row.Version - 1),
@@ -108,7 +195,19 @@ func (db *mdb) UpdateQueueMetadataCas(
 	ctx context.Context,
 	row nosqlplugin.QueueMetadataRow,
 ) error {
-	panic("TODO")
+	filter := append(queueFilter(row.QueueType), bson.E{Key: "version", Value: row.Version - 1})
+	result, err := db.dbConn.Collection(cadence.QueueMetadataCollectionName).ReplaceOne(ctx, filter, &cadence.QueueMetadataCollectionEntry{
+		QueueType:        int(row.QueueType),
+		Version:          row.Version,
+		ClusterAckLevels: row.ClusterAckLevels,
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return nosqlplugin.NewConditionFailure("queue")
+	}
+	return nil
 }
 
 // Read a QueueMetadata
@@ -116,13 +215,24 @@ func (db *mdb) SelectQueueMetadata(
 	ctx context.Context,
 	queueType persistence.QueueType,
 ) (*nosqlplugin.QueueMetadataRow, error) {
-	fmt.Println("not implemented, ignore the eror for testing")
-	return nil, nil
+	var entry cadence.QueueMetadataCollectionEntry
+	if err := db.dbConn.Collection(cadence.QueueMetadataCollectionName).FindOne(ctx, queueFilter(queueType)).Decode(&entry); err != nil {
+		return nil, err
+	}
+	// if record exist but ackLevels is empty, we initialize the map
+	if entry.ClusterAckLevels == nil {
+		entry.ClusterAckLevels = make(map[string]int64)
+	}
+	return &nosqlplugin.QueueMetadataRow{
+		QueueType:        queueType,
+		ClusterAckLevels: entry.ClusterAckLevels,
+		Version:          entry.Version,
+	}, nil
 }
 
 func (db *mdb) GetQueueSize(
 	ctx context.Context,
 	queueType persistence.QueueType,
 ) (int64, error) {
-	panic("TODO")
+	return db.dbConn.Collection(cadence.QueueMessageCollectionName).CountDocuments(ctx, queueFilter(queueType))
 }
@@ -22,32 +22,121 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
-	log "github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/mongodb/cadence"
 )
 
+func shardFilter(shardID int) bson.D {
+	return bson.D{{Key: "shardid", Value: shardID}}
+}
+
+func newShardEntry(row *nosqlplugin.ShardRow) (*cadence.ShardCollectionEntry, error) {
+	shard := *row
+	shard.UpdatedAt = time.Now()
+	data, err := json.Marshal(&shard)
+	if err != nil {
+		return nil, err
+	}
+	return &cadence.ShardCollectionEntry{
+		ShardID: row.ShardID,
+		RangeID: row.RangeID,
+		Data:    data,
+	}, nil
+}
+
 // InsertShard creates a new shard, return error is there is any.
 // Return ShardOperationConditionFailure if the condition doesn't meet
 func (db *mdb) InsertShard(ctx context.Context, row *nosqlplugin.ShardRow) error {
-	log.Warn("not implemented...ignore the error for testing...")
-	return nil
+	entry, err := newShardEntry(row)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbConn.Collection(cadence.ShardCollectionName).InsertOne(ctx, entry)
+	if mongo.IsDuplicateKeyError(err) {
+		return db.getConflictedShard(ctx, row.ShardID)
+	}
+	return err
 }
 
 // SelectShard gets a shard
 func (db *mdb) SelectShard(ctx context.Context, shardID int, currentClusterName string) (int64, *nosqlplugin.ShardRow, error) {
-	panic("TODO")
+	var entry cadence.ShardCollectionEntry
+	if err := db.dbConn.Collection(cadence.ShardCollectionName).FindOne(ctx, shardFilter(shardID)).Decode(&entry); err != nil {
+		return 0, nil, err
+	}
+	shard := &nosqlplugin.ShardRow{}
+	if err := json.Unmarshal(entry.Data, shard); err != nil {
+		return 0, nil, err
+	}
+	if shard.ClusterTransferAckLevel == nil {
+		shard.ClusterTransferAckLevel = map[string]int64{
+			currentClusterName: shard.TransferAckLevel,
+		}
+	}
+	if shard.ClusterTimerAckLevel == nil {
+		shard.ClusterTimerAckLevel = map[string]time.Time{
+			currentClusterName: shard.TimerAckLevel,
+		}
+	}
+	if shard.ClusterReplicationLevel == nil {
+		shard.ClusterReplicationLevel = make(map[string]int64)
+	}
+	if shard.ReplicationDLQAckLevel == nil {
+		shard.ReplicationDLQAckLevel = make(map[string]int64)
+	}
+	return entry.RangeID, shard, nil
 }
 
 // UpdateRangeID updates the rangeID, return error is there is any
 // Return ShardOperationConditionFailure if the condition doesn't meet
 func (db *mdb) UpdateRangeID(ctx context.Context, shardID int, rangeID int64, previousRangeID int64) error {
-	panic("TODO")
+	filter := append(shardFilter(shardID), bson.E{Key: "rangeid", Value: previousRangeID})
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "rangeid", Value: rangeID}}}}
+	result, err := db.dbConn.Collection(cadence.ShardCollectionName).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return db.getConflictedShard(ctx, shardID)
+	}
+	return nil
 }
 
 // UpdateShard updates a shard, return error is there is any.
 // Return ShardOperationConditionFailure if the condition doesn't meet
 func (db *mdb) UpdateShard(ctx context.Context, row *nosqlplugin.ShardRow, previousRangeID int64) error {
-	panic("TODO")
+	entry, err := newShardEntry(row)
+	if err != nil {
+		return err
+	}
+	filter := append(shardFilter(row.ShardID), bson.E{Key: "rangeid", Value: previousRangeID})
+	result, err := db.dbConn.Collection(cadence.ShardCollectionName).ReplaceOne(ctx, filter, entry)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return db.getConflictedShard(ctx, row.ShardID)
+	}
+	return nil
+}
+
+// getConflictedShard reads the current shard after a conditional write fails,
+// because MongoDB doesn't return the previous document on a failed conditional write
+func (db *mdb) getConflictedShard(ctx context.Context, shardID int) error {
+	var entry cadence.ShardCollectionEntry
+	err := db.dbConn.Collection(cadence.ShardCollectionName).FindOne(ctx, shardFilter(shardID)).Decode(&entry)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	return &nosqlplugin.ShardOperationConditionFailure{
+		RangeID: entry.RangeID,
+		Details: fmt.Sprintf("shardid=%v, rangeid=%v", shardID, entry.RangeID),
+	}
 }
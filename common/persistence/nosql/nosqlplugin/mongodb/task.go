@@ -1,4 +1,4 @@
-// Copyright (c) 2021 Uber Technologies, Inc.
+// Copyright (c) 2020 Uber Technologies, Inc.
 //
 // Permission is hereby granted, free of charge, to any person obtaining a copy
 // of this software and associated documentation files (the "Software"), to deal
@@ -22,20 +22,114 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/mongodb/cadence"
+)
+
+const (
+	initialRangeID  = 1 // Id of the first range of a new task list
+	initialAckLevel = 0
 )
 
+func taskListFilter(filter *nosqlplugin.TaskListFilter) bson.D {
+	return bson.D{
+		{Key: "domainid", Value: filter.DomainID},
+		{Key: "name", Value: filter.TaskListName},
+		{Key: "tasklisttype", Value: filter.TaskListType},
+	}
+}
+
+func taskListRangeFilter(filter *nosqlplugin.TaskListFilter, rangeID int64) bson.D {
+	return append(taskListFilter(filter), bson.E{Key: "rangeid", Value: rangeID})
+}
+
+func tasksFilter(filter *nosqlplugin.TasksFilter) bson.D {
+	return bson.D{
+		{Key: "domainid", Value: filter.DomainID},
+		{Key: "tasklistname", Value: filter.TaskListName},
+		{Key: "tasklisttype", Value: filter.TaskListType},
+		{Key: "taskid", Value: bson.D{
+			{Key: "$gt", Value: filter.MinTaskID},
+			{Key: "$lte", Value: filter.MaxTaskID},
+		}},
+	}
+}
+
+func toTaskListFilter(row *nosqlplugin.TaskListRow) *nosqlplugin.TaskListFilter {
+	return &nosqlplugin.TaskListFilter{
+		DomainID:     row.DomainID,
+		TaskListName: row.TaskListName,
+		TaskListType: row.TaskListType,
+	}
+}
+
+// newTaskListEntry stores the TaskListRow as a JSON blob, rangeid is the only significant field
+func newTaskListEntry(row *nosqlplugin.TaskListRow, ttlSeconds int64) (*cadence.TaskListCollectionEntry, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	return &cadence.TaskListCollectionEntry{
+		DomainID:     row.DomainID,
+		Name:         row.TaskListName,
+		TaskListType: row.TaskListType,
+		RangeID:      row.RangeID,
+		Data:         data,
+		ExpireAt:     expireAt(ttlSeconds),
+	}, nil
+}
+
+func toTaskListRow(doc bson.Raw) (*nosqlplugin.TaskListRow, error) {
+	var entry cadence.TaskListCollectionEntry
+	if err := bson.Unmarshal(doc, &entry); err != nil {
+		return nil, err
+	}
+	row := &nosqlplugin.TaskListRow{}
+	if err := json.Unmarshal(entry.Data, row); err != nil {
+		return nil, err
+	}
+	row.RangeID = entry.RangeID
+	return row, nil
+}
+
 // SelectTaskList returns a single tasklist row.
 // Return IsNotFoundError if the row doesn't exist
 func (db *mdb) SelectTaskList(ctx context.Context, filter *nosqlplugin.TaskListFilter) (*nosqlplugin.TaskListRow, error) {
-	panic("TODO")
+	doc, err := db.dbConn.Collection(cadence.TaskListCollectionName).
+		FindOne(ctx, append(taskListFilter(filter), notExpiredFilter())).DecodeBytes()
+	if err != nil {
+		return nil, err
+	}
+	return toTaskListRow(doc)
 }
 
 // InsertTaskList insert a single tasklist row
 // Return IsConditionFailedError if the row already exists, and also the existing row
 func (db *mdb) InsertTaskList(ctx context.Context, row *nosqlplugin.TaskListRow) error {
-	panic("TODO")
+	taskList := *row
+	taskList.RangeID = initialRangeID
+	taskList.AckLevel = initialAckLevel
+	entry, err := newTaskListEntry(&taskList, 0)
+	if err != nil {
+		return err
+	}
+	// the filter only matches an expired document that is not yet deleted by MongoDB, which is overwritten.
+	// Otherwise the upsert fails with a duplicate key error if the document exists
+	filter := append(taskListFilter(toTaskListFilter(row)), bson.E{Key: "expireat", Value: bson.D{{Key: "$lte", Value: time.Now()}}})
+	_, err = db.dbConn.Collection(cadence.TaskListCollectionName).ReplaceOne(ctx, filter, entry, options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return db.getTaskListConditionFailure(ctx, toTaskListFilter(row))
+	}
+	return err
 }
 
 // UpdateTaskList updates a single tasklist row
@@ -45,7 +139,7 @@ func (db *mdb) UpdateTaskList(
 	row *nosqlplugin.TaskListRow,
 	previousRangeID int64,
 ) error {
-	panic("TODO")
+	return db.updateTaskList(ctx, 0, row, previousRangeID)
 }
 
 // UpdateTaskList updates a single tasklist row, and set an TTL on the record
@@ -58,19 +152,66 @@ func (db *mdb) UpdateTaskListWithTTL(
 	row *nosqlplugin.TaskListRow,
 	previousRangeID int64,
 ) error {
-	panic("TODO")
+	taskList := *row
+	taskList.LastUpdatedTime = time.Now()
+	return db.updateTaskList(ctx, ttlSeconds, &taskList, previousRangeID)
+}
+
+func (db *mdb) updateTaskList(
+	ctx context.Context,
+	ttlSeconds int64,
+	row *nosqlplugin.TaskListRow,
+	previousRangeID int64,
+) error {
+	entry, err := newTaskListEntry(row, ttlSeconds)
+	if err != nil {
+		return err
+	}
+	filter := toTaskListFilter(row)
+	result, err := db.dbConn.Collection(cadence.TaskListCollectionName).
+		ReplaceOne(ctx, taskListRangeFilter(filter, previousRangeID), entry)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return db.getTaskListConditionFailure(ctx, filter)
+	}
+	return nil
 }
 
 // ListTaskList returns all tasklists.
 // Noop if TTL is already implemented in other methods
 func (db *mdb) ListTaskList(ctx context.Context, pageSize int, nextPageToken []byte) (*nosqlplugin.ListTaskListResult, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.TaskListCollectionName, bson.D{notExpiredFilter()},
+		bson.D{{Key: "domainid", Value: 1}, {Key: "name", Value: 1}, {Key: "tasklisttype", Value: 1}}, pageSize, nextPageToken)
+	if err != nil {
+		return nil, err
+	}
+	result := &nosqlplugin.ListTaskListResult{
+		TaskLists:     make([]*nosqlplugin.TaskListRow, 0, len(docs)),
+		NextPageToken: nextPageToken,
+	}
+	for _, doc := range docs {
+		row, err := toTaskListRow(doc)
+		if err != nil {
+			return nil, err
+		}
+		result.TaskLists = append(result.TaskLists, row)
+	}
+	return result, nil
 }
 
 // DeleteTaskList deletes a single tasklist row
 // Return TaskOperationConditionFailure if the condition doesn't meet
 func (db *mdb) DeleteTaskList(ctx context.Context, filter *nosqlplugin.TaskListFilter, previousRangeID int64) error {
-	panic("TODO")
+	result, err := db.dbConn.Collection(cadence.TaskListCollectionName).DeleteOne(ctx, taskListRangeFilter(filter, previousRangeID))
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return db.getTaskListConditionFailure(ctx, filter)
+	}
+	return nil
 }
 
 // InsertTasks inserts a batch of tasks
@@ -80,18 +221,91 @@ func (db *mdb) InsertTasks(
 	tasksToInsert []*nosqlplugin.TaskRowForInsert,
 	tasklistCondition *nosqlplugin.TaskListRow,
 ) error {
-	panic("TODO")
+	filter := toTaskListFilter(tasklistCondition)
+	entries := make([]interface{}, 0, len(tasksToInsert))
+	for _, task := range tasksToInsert {
+		data, err := json.Marshal(&task.TaskRow)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &cadence.TaskCollectionEntry{
+			DomainID:     filter.DomainID,
+			TaskListName: filter.TaskListName,
+			TaskListType: filter.TaskListType,
+			TaskID:       task.TaskID,
+			Data:         data,
+			ExpireAt:     expireAt(int64(task.TTLSeconds)),
+		})
+	}
+
+	err := db.executeTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		// the write on the tasklist makes the transaction conflict with any concurrent change of the rangeID,
+		// a read of the tasklist is not enough because the transaction reads from a snapshot
+		result, err := db.dbConn.Collection(cadence.TaskListCollectionName).UpdateOne(sessCtx,
+			taskListRangeFilter(filter, tasklistCondition.RangeID),
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "writecount", Value: 1}}}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return errConditionFailed
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		_, err = db.dbConn.Collection(cadence.TaskCollectionName).InsertMany(sessCtx, entries)
+		return err
+	})
+	if err == errConditionFailed {
+		return db.getTaskListConditionFailure(ctx, filter)
+	}
+	return err
 }
 
 // SelectTasks return tasks that associated to a tasklist
 func (db *mdb) SelectTasks(ctx context.Context, filter *nosqlplugin.TasksFilter) ([]*nosqlplugin.TaskRow, error) {
-	panic("TODO")
+	docs, _, err := db.findPage(ctx, cadence.TaskCollectionName, append(tasksFilter(filter), notExpiredFilter()),
+		bson.D{{Key: "taskid", Value: 1}}, filter.BatchSize, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]*nosqlplugin.TaskRow, 0, len(docs))
+	for _, doc := range docs {
+		var entry cadence.TaskCollectionEntry
+		if err := bson.Unmarshal(doc, &entry); err != nil {
+			return nil, err
+		}
+		row := &nosqlplugin.TaskRow{}
+		if err := json.Unmarshal(entry.Data, row); err != nil {
+			return nil, err
+		}
+		row.TaskID = entry.TaskID
+		response = append(response, row)
+	}
+	return response, nil
 }
 
 // DeleteTask delete a batch tasks that taskIDs less than the row
 // If TTL is not implemented, then should also return the number of rows deleted, otherwise persistence.UnknownNumRowsAffected
 // NOTE: This API ignores the `BatchSize` request parameter i.e. either all tasks leq the task_id will be deleted or an error will
-// be returned to the caller, because rowsDeleted is not supported by Cassandra
+// be returned to the caller, because rowsDeleted is not supported with TTL
 func (db *mdb) RangeDeleteTasks(ctx context.Context, filter *nosqlplugin.TasksFilter) (rowsDeleted int, err error) {
-	panic("TODO")
+	_, err = db.dbConn.Collection(cadence.TaskCollectionName).DeleteMany(ctx, tasksFilter(filter))
+	return p.UnknownNumRowsAffected, err
+}
+
+// getTaskListConditionFailure reads the current tasklist after a conditional write fails,
+// because MongoDB doesn't return the existing document on a failed conditional write
+func (db *mdb) getTaskListConditionFailure(ctx context.Context, filter *nosqlplugin.TaskListFilter) error {
+	var entry cadence.TaskListCollectionEntry
+	err := db.dbConn.Collection(cadence.TaskListCollectionName).FindOne(ctx, taskListFilter(filter)).Decode(&entry)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	return &nosqlplugin.TaskOperationConditionFailure{
+		RangeID: entry.RangeID,
+		Details: fmt.Sprintf("domainid=%v, name=%v, tasklisttype=%v, rangeid=%v",
+			filter.DomainID, filter.TaskListName, filter.TaskListType, entry.RangeID),
+	}
 }
@@ -0,0 +1,194 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mongodb
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	// errConditionFailed is returned from a transaction to abort it when a condition is not met
+	errConditionFailed = errors.New("internal condition fail error")
+
+	minUnixNanoTime = time.Unix(0, math.MinInt64)
+	maxUnixNanoTime = time.Unix(0, math.MaxInt64)
+)
+
+// unixNano returns the UnixNano of the time.
+// UnixNano is undefined when the time is out of the int64 range(e.g. a zero time.Time), so it's clamped.
+func unixNano(t time.Time) int64 {
+	switch {
+	case t.Before(minUnixNanoTime):
+		return math.MinInt64
+	case t.After(maxUnixNanoTime):
+		return math.MaxInt64
+	}
+	return t.UnixNano()
+}
+
+// expireAt returns the time for the TTL index, nil means never expire
+func expireAt(ttlSeconds int64) *time.Time {
+	if ttlSeconds <= 0 {
+		return nil
+	}
+	t := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	return &t
+}
+
+// notExpiredFilter filters out the documents that are expired but not yet deleted,
+// because MongoDB deletes expired documents by a background task that runs every 60 seconds
+func notExpiredFilter() bson.E {
+	return bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: "expireat", Value: nil}},
+		bson.D{{Key: "expireat", Value: bson.D{{Key: "$gt", Value: time.Now()}}}},
+	}}
+}
+
+// escapeKey encodes a user provided string so that it can be used as a field name,
+// which can't contain '.' or start with '$'
+func escapeKey(key string) string {
+	return hex.EncodeToString([]byte(key))
+}
+
+func unescapeKey(key string) (string, error) {
+	decoded, err := hex.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid field name %v: %v", key, err)
+	}
+	return string(decoded), nil
+}
+
+// executeTransaction runs fn in a multi-document transaction.
+// The transaction is retried by the driver on transient errors, e.g. write conflicts with a concurrent transaction.
+// NOTE: transactions require MongoDB to be deployed as a replica set or a sharded cluster.
+func (db *mdb) executeTransaction(ctx context.Context, fn func(sessCtx mongo.SessionContext) error) error {
+	session, err := db.client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
+
+// findPage runs the query sorted by the sort fields, and returns at most pageSize documents after the page token.
+// The sort fields must identify a document uniquely within the filter. The page token is the values of the sort
+// fields of the last document of the previous page, so that the pagination is stable while documents are being
+// inserted or deleted. A non-positive pageSize means no limit.
+func (db *mdb) findPage(
+	ctx context.Context,
+	collection string,
+	filter bson.D,
+	sort bson.D,
+	pageSize int,
+	pageToken []byte,
+) ([]bson.Raw, []byte, error) {
+	if len(pageToken) > 0 {
+		var last bson.D
+		if err := bson.Unmarshal(pageToken, &last); err != nil {
+			return nil, nil, fmt.Errorf("invalid page token: %v", err)
+		}
+		after, err := afterSortKeys(sort, last)
+		if err != nil {
+			return nil, nil, err
+		}
+		// the filter may have its own $or, so the conditions are combined by $and
+		filter = bson.D{{Key: "$and", Value: bson.A{filter, bson.D{after}}}}
+	}
+
+	findOptions := options.Find().SetSort(sort)
+	if pageSize > 0 {
+		// read one more document to know if there is a next page
+		findOptions.SetLimit(int64(pageSize) + 1)
+	}
+	cursor, err := db.dbConn.Collection(collection).Find(ctx, filter, findOptions)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []bson.Raw
+	for cursor.Next(ctx) {
+		docs = append(docs, append(bson.Raw{}, cursor.Current...))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, nil, err
+	}
+	if pageSize <= 0 || len(docs) <= pageSize {
+		return docs, nil, nil
+	}
+
+	docs = docs[:pageSize]
+	last := make(bson.D, 0, len(sort))
+	for _, field := range sort {
+		value, err := docs[pageSize-1].LookupErr(field.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("sort field %v not found: %v", field.Key, err)
+		}
+		last = append(last, bson.E{Key: field.Key, Value: value})
+	}
+	nextPageToken, err := bson.Marshal(last)
+	if err != nil {
+		return nil, nil, err
+	}
+	return docs, nextPageToken, nil
+}
+
+// afterSortKeys builds the filter for the documents that come after the last sort keys, i.e.
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ..., using $lt instead for the descending fields
+func afterSortKeys(sort bson.D, last bson.D) (bson.E, error) {
+	if len(last) != len(sort) {
+		return bson.E{}, fmt.Errorf("invalid page token: %v", last)
+	}
+	conditions := make(bson.A, 0, len(sort))
+	for i, field := range sort {
+		if last[i].Key != field.Key {
+			return bson.E{}, fmt.Errorf("invalid page token: %v", last)
+		}
+		operator := "$gt"
+		if direction, ok := field.Value.(int); ok && direction < 0 {
+			operator = "$lt"
+		}
+		condition := make(bson.D, 0, i+1)
+		condition = append(condition, last[:i]...)
+		condition = append(condition, bson.E{Key: field.Key, Value: bson.D{{Key: operator, Value: last[i].Value}}})
+		conditions = append(conditions, condition)
+	}
+	return bson.E{Key: "$or", Value: conditions}, nil
+}
+
+// findAll runs the query sorted by the sort fields and returns all the documents
+func (db *mdb) findAll(ctx context.Context, collection string, filter bson.D, sort bson.D) ([]bson.Raw, error) {
+	docs, _, err := db.findPage(ctx, collection, filter, sort, 0, nil)
+	return docs, err
+}
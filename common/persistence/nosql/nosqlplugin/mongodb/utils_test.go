@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mongodb
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+)
+
+type utilsSuite struct {
+	suite.Suite
+}
+
+func TestUtilsSuite(t *testing.T) {
+	suite.Run(t, new(utilsSuite))
+}
+
+func (s *utilsSuite) TestUnixNano_Clamped() {
+	s.Equal(int64(math.MinInt64), unixNano(time.Time{}))
+	s.Equal(int64(math.MaxInt64), unixNano(maxUnixNanoTime.Add(time.Hour)))
+	s.Equal(int64(1000), unixNano(time.Unix(0, 1000)))
+}
+
+func (s *utilsSuite) TestEscapeKey_RoundTrip() {
+	for _, key := range []string{"", "timer.1", "$signal", "中文"} {
+		escaped := escapeKey(key)
+		s.NotContains(escaped, ".")
+		s.NotContains(escaped, "$")
+		unescaped, err := unescapeKey(escaped)
+		s.NoError(err)
+		s.Equal(key, unescaped)
+	}
+	_, err := unescapeKey("not hex")
+	s.Error(err)
+}
+
+func (s *utilsSuite) TestAfterSortKeys() {
+	sort := bson.D{{Key: "starttime", Value: -1}, {Key: "runid", Value: 1}}
+	after, err := afterSortKeys(sort, bson.D{{Key: "starttime", Value: 10}, {Key: "runid", Value: "a"}})
+	s.NoError(err)
+	s.Equal(bson.E{Key: "$or", Value: bson.A{
+		bson.D{{Key: "starttime", Value: bson.D{{Key: "$lt", Value: 10}}}},
+		bson.D{{Key: "starttime", Value: 10}, {Key: "runid", Value: bson.D{{Key: "$gt", Value: "a"}}}},
+	}}, after)
+
+	_, err = afterSortKeys(sort, bson.D{{Key: "runid", Value: "a"}, {Key: "starttime", Value: 10}})
+	s.Error(err)
+	_, err = afterSortKeys(sort, bson.D{{Key: "starttime", Value: 10}})
+	s.Error(err)
+}
+
+func (s *utilsSuite) TestNewExecutionEntry() {
+	execution := &nosqlplugin.WorkflowExecutionRequest{
+		TimerInfos:         map[string]*persistence.TimerInfo{"timer.1": {TimerID: "timer.1"}},
+		SignalRequestedIDs: []string{"signal$1"},
+	}
+	execution.DomainID = "domain"
+	execution.WorkflowID = "workflow"
+	execution.RunID = "run"
+
+	entry, err := newExecutionEntry(1, execution)
+	s.NoError(err)
+	s.NotNil(entry.ActivityInfos)
+	s.NotNil(entry.BufferedEvents)
+	s.Contains(entry.TimerInfos, escapeKey("timer.1"))
+	s.True(entry.SignalRequestedIDs[escapeKey("signal$1")])
+
+	state, err := toWorkflowExecution(entry)
+	s.NoError(err)
+	s.Equal("timer.1", state.TimerInfos["timer.1"].TimerID)
+	s.Contains(state.SignalRequestedIDs, "signal$1")
+	s.Equal("workflow", state.ExecutionInfo.WorkflowID)
+}
+
+func (s *utilsSuite) TestMapKeysToDelete() {
+	execution := &nosqlplugin.WorkflowExecutionRequest{
+		ActivityInfoKeysToDelete:       []int64{5},
+		TimerInfoKeysToDelete:          []string{"timer.1"},
+		SignalRequestedIDsKeysToDelete: []string{"signal"},
+	}
+	s.Equal([]string{
+		"activityinfos.5",
+		"timerinfos." + escapeKey("timer.1"),
+		"signalrequestedids." + escapeKey("signal"),
+	}, mapKeysToDelete(execution))
+}
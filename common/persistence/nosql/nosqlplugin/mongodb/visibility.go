@@ -1,4 +1,4 @@
-// Copyright (c) 2021 Uber Technologies, Inc.
+// Copyright (c) 2020 Uber Technologies, Inc.
 //
 // Permission is hereby granted, free of charge, to any person obtaining a copy
 // of this software and associated documentation files (the "Software"), to deal
@@ -22,43 +22,174 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/mongodb/cadence"
 )
 
+func visibilityFilter(domainID, workflowID, runID string) bson.D {
+	return bson.D{
+		{Key: "domainid", Value: domainID},
+		{Key: "workflowid", Value: workflowID},
+		{Key: "runid", Value: runID},
+	}
+}
+
+// newVisibilityEntry stores the VisibilityRow as a JSON blob, the significant fields are used by the indexes
+func newVisibilityEntry(domainID string, row *nosqlplugin.VisibilityRow, closed bool, ttlSeconds int64) (*cadence.VisibilityCollectionEntry, error) {
+	data, err := json.Marshal(row)
+	if err != nil {
+		return nil, err
+	}
+	entry := &cadence.VisibilityCollectionEntry{
+		DomainID:     domainID,
+		WorkflowID:   row.WorkflowID,
+		RunID:        row.RunID,
+		WorkflowType: row.TypeName,
+		StartTime:    unixNano(row.StartTime),
+		Data:         data,
+		ExpireAt:     expireAt(ttlSeconds),
+	}
+	if closed {
+		closeTime := unixNano(row.CloseTime)
+		entry.CloseTime = &closeTime
+		if row.Status != nil {
+			closeStatus := int32(*row.Status)
+			entry.CloseStatus = &closeStatus
+		}
+	}
+	return entry, nil
+}
+
+func toVisibilityRow(doc bson.Raw) (*nosqlplugin.VisibilityRow, error) {
+	var entry cadence.VisibilityCollectionEntry
+	if err := bson.Unmarshal(doc, &entry); err != nil {
+		return nil, err
+	}
+	row := &nosqlplugin.VisibilityRow{}
+	if err := json.Unmarshal(entry.Data, row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+func (db *mdb) upsertVisibility(ctx context.Context, entry *cadence.VisibilityCollectionEntry) error {
+	_, err := db.dbConn.Collection(cadence.VisibilityCollectionName).ReplaceOne(ctx,
+		visibilityFilter(entry.DomainID, entry.WorkflowID, entry.RunID), entry, options.Replace().SetUpsert(true))
+	return err
+}
+
 func (db *mdb) InsertVisibility(
 	ctx context.Context,
 	ttlSeconds int64,
 	row *nosqlplugin.VisibilityRowForInsert,
 ) error {
-	panic("TODO")
+	entry, err := newVisibilityEntry(row.DomainID, &row.VisibilityRow, false, ttlSeconds)
+	if err != nil {
+		return err
+	}
+	return db.upsertVisibility(ctx, entry)
 }
 
+// UpdateVisibility overrides the visibility record.
+// Unlike Cassandra, open and closed records are in the same collection, so UpdateOpenToClose/UpdateCloseToOpen can be ignored.
 func (db *mdb) UpdateVisibility(
 	ctx context.Context,
 	ttlSeconds int64,
 	row *nosqlplugin.VisibilityRowForUpdate,
 ) error {
-	panic("TODO")
+	entry, err := newVisibilityEntry(row.DomainID, &row.VisibilityRow, !row.UpdateCloseToOpen, ttlSeconds)
+	if err != nil {
+		return err
+	}
+	return db.upsertVisibility(ctx, entry)
 }
 
 func (db *mdb) SelectVisibility(
 	ctx context.Context,
 	filter *nosqlplugin.VisibilityFilter,
 ) (*nosqlplugin.SelectVisibilityResponse, error) {
-	panic("TODO")
+	request := &filter.ListRequest
+	isOpen := filter.FilterType == nosqlplugin.AllOpen ||
+		filter.FilterType == nosqlplugin.OpenByWorkflowType ||
+		filter.FilterType == nosqlplugin.OpenByWorkflowID
+
+	// open workflows are always sorted by start time
+	timeField := "starttime"
+	if !isOpen && filter.SortType == nosqlplugin.SortByClosedTime {
+		timeField = "closetime"
+	}
+	query := bson.D{
+		{Key: "domainid", Value: request.DomainUUID},
+		{Key: timeField, Value: bson.D{
+			{Key: "$gte", Value: unixNano(request.EarliestTime)},
+			{Key: "$lte", Value: unixNano(request.LatestTime)},
+		}},
+		notExpiredFilter(),
+	}
+	if isOpen {
+		query = append(query, bson.E{Key: "closetime", Value: nil})
+	} else if timeField != "closetime" {
+		query = append(query, bson.E{Key: "closetime", Value: bson.D{{Key: "$ne", Value: nil}}})
+	}
+
+	switch filter.FilterType {
+	case nosqlplugin.OpenByWorkflowType, nosqlplugin.ClosedByWorkflowType:
+		query = append(query, bson.E{Key: "workflowtype", Value: filter.WorkflowType})
+	case nosqlplugin.OpenByWorkflowID, nosqlplugin.ClosedByWorkflowID:
+		query = append(query, bson.E{Key: "workflowid", Value: filter.WorkflowID})
+	case nosqlplugin.ClosedByClosedStatus:
+		query = append(query, bson.E{Key: "closestatus", Value: filter.CloseStatus})
+	}
+
+	// newest first, the workflowid and runid make the sort keys unique for the pagination
+	docs, nextPageToken, err := db.findPage(ctx, cadence.VisibilityCollectionName, query, bson.D{
+		{Key: timeField, Value: -1},
+		{Key: "workflowid", Value: 1},
+		{Key: "runid", Value: 1},
+	}, request.PageSize, request.NextPageToken)
+	if err != nil {
+		return nil, err
+	}
+
+	executions := make([]*nosqlplugin.VisibilityRow, 0, len(docs))
+	for _, doc := range docs {
+		row, err := toVisibilityRow(doc)
+		if err != nil {
+			return nil, err
+		}
+		executions = append(executions, row)
+	}
+	return &nosqlplugin.SelectVisibilityResponse{
+		Executions:    executions,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
 func (db *mdb) DeleteVisibility(
 	ctx context.Context,
 	domainID, workflowID, runID string,
 ) error {
-	panic("TODO")
+	_, err := db.dbConn.Collection(cadence.VisibilityCollectionName).DeleteOne(ctx, visibilityFilter(domainID, workflowID, runID))
+	return err
 }
 
 func (db *mdb) SelectOneClosedWorkflow(
 	ctx context.Context,
 	domainID, workflowID, runID string,
 ) (*nosqlplugin.VisibilityRow, error) {
-	panic("TODO")
+	filter := append(visibilityFilter(domainID, workflowID, runID), bson.E{Key: "closetime", Value: bson.D{{Key: "$ne", Value: nil}}})
+	doc, err := db.dbConn.Collection(cadence.VisibilityCollectionName).FindOne(ctx, filter).DecodeBytes()
+	if db.IsNotFoundError(err) {
+		// Special case: return nil,nil if not found(since we will deprecate it, it's not worth refactor to be consistent)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return toVisibilityRow(doc)
 }
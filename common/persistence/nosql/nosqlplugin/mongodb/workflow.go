@@ -1,5 +1,4 @@
-// Copyright (c) 2021 Uber Technologies, Inc.
-// Portions of the Software are attributed to Copyright (c) 2020 Temporal Technologies Inc.
+// Copyright (c) 2020 Uber Technologies, Inc.
 //
 // Permission is hereby granted, free of charge, to any person obtaining a copy
 // of this software and associated documentation files (the "Software"), to deal
@@ -23,14 +22,23 @@ package mongodb
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/mongodb/cadence"
 )
 
 var _ nosqlplugin.WorkflowCRUD = (*mdb)(nil)
 
+var taskIDSort = bson.D{{Key: "taskid", Value: 1}}
+
 func (db *mdb) InsertWorkflowExecutionWithTasks(
 	ctx context.Context,
 	currentWorkflowRequest *nosqlplugin.CurrentWorkflowWriteRequest,
@@ -41,7 +49,26 @@ func (db *mdb) InsertWorkflowExecutionWithTasks(
 	timerTasks []*nosqlplugin.TimerTask,
 	shardCondition *nosqlplugin.ShardCondition,
 ) error {
-	panic("TODO")
+	shardID := shardCondition.ShardID
+	txn := &workflowTransaction{}
+	db.addShardCondition(txn, shardCondition)
+
+	err := db.createOrUpdateCurrentWorkflow(txn, shardID, execution.DomainID, execution.WorkflowID, currentWorkflowRequest)
+	if err != nil {
+		return err
+	}
+
+	err = db.createWorkflowExecutionWithMergeMaps(txn, shardID, execution)
+	if err != nil {
+		return err
+	}
+
+	err = db.createTasks(txn, shardID, transferTasks, crossClusterTasks, replicationTasks, timerTasks)
+	if err != nil {
+		return err
+	}
+
+	return db.executeWorkflowTransaction(ctx, txn, currentWorkflowRequest, shardCondition)
 }
 
 func (db *mdb) UpdateWorkflowExecutionWithTasks(
@@ -56,105 +83,380 @@ func (db *mdb) UpdateWorkflowExecutionWithTasks(
 	timerTasks []*nosqlplugin.TimerTask,
 	shardCondition *nosqlplugin.ShardCondition,
 ) error {
-	panic("TODO")
+	shardID := shardCondition.ShardID
+	var domainID, workflowID string
+	if mutatedExecution != nil {
+		domainID = mutatedExecution.DomainID
+		workflowID = mutatedExecution.WorkflowID
+	} else if resetExecution != nil {
+		domainID = resetExecution.DomainID
+		workflowID = resetExecution.WorkflowID
+	} else {
+		return fmt.Errorf("at least one of mutatedExecution and resetExecution should be provided")
+	}
+
+	txn := &workflowTransaction{}
+	db.addShardCondition(txn, shardCondition)
+
+	err := db.createOrUpdateCurrentWorkflow(txn, shardID, domainID, workflowID, currentWorkflowRequest)
+	if err != nil {
+		return err
+	}
+
+	if mutatedExecution != nil {
+		err = db.updateWorkflowExecutionAndEventBufferWithMergeAndDeleteMaps(txn, shardID, mutatedExecution)
+		if err != nil {
+			return err
+		}
+	}
+
+	if insertedExecution != nil {
+		err = db.createWorkflowExecutionWithMergeMaps(txn, shardID, insertedExecution)
+		if err != nil {
+			return err
+		}
+	}
+
+	if resetExecution != nil {
+		err = db.resetWorkflowExecutionAndMapsAndEventBuffer(txn, shardID, resetExecution)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = db.createTasks(txn, shardID, transferTasks, crossClusterTasks, replicationTasks, timerTasks)
+	if err != nil {
+		return err
+	}
+
+	return db.executeWorkflowTransaction(ctx, txn, currentWorkflowRequest, shardCondition)
 }
 
 func (db *mdb) SelectCurrentWorkflow(ctx context.Context, shardID int, domainID, workflowID string) (*nosqlplugin.CurrentWorkflowRow, error) {
-	panic("TODO")
+	var entry cadence.CurrentWorkflowCollectionEntry
+	err := db.dbConn.Collection(cadence.CurrentWorkflowCollectionName).
+		FindOne(ctx, currentWorkflowFilter(shardID, domainID, workflowID)).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	row := &nosqlplugin.CurrentWorkflowRow{
+		LastWriteVersion: common.EmptyVersion,
+	}
+	if err := json.Unmarshal(entry.Data, row); err != nil {
+		return nil, err
+	}
+	row.ShardID = shardID
+	row.DomainID = domainID
+	row.WorkflowID = workflowID
+	row.RunID = entry.CurrentRunID
+	return row, nil
 }
 
 func (db *mdb) SelectWorkflowExecution(ctx context.Context, shardID int, domainID, workflowID, runID string) (*nosqlplugin.WorkflowExecution, error) {
-	panic("TODO")
+	var entry cadence.WorkflowExecutionCollectionEntry
+	err := db.dbConn.Collection(cadence.WorkflowExecutionCollectionName).
+		FindOne(ctx, executionFilter(shardID, domainID, workflowID, runID)).Decode(&entry)
+	if err != nil {
+		return nil, err
+	}
+	return toWorkflowExecution(&entry)
 }
 
 func (db *mdb) DeleteCurrentWorkflow(ctx context.Context, shardID int, domainID, workflowID, currentRunIDCondition string) error {
-	panic("TODO")
+	// the current workflow is not deleted if it has moved to another run
+	filter := append(currentWorkflowFilter(shardID, domainID, workflowID), bson.E{Key: "currentrunid", Value: currentRunIDCondition})
+	_, err := db.dbConn.Collection(cadence.CurrentWorkflowCollectionName).DeleteOne(ctx, filter)
+	return err
 }
 
 func (db *mdb) DeleteWorkflowExecution(ctx context.Context, shardID int, domainID, workflowID, runID string) error {
-	panic("TODO")
+	_, err := db.dbConn.Collection(cadence.WorkflowExecutionCollectionName).DeleteOne(ctx, executionFilter(shardID, domainID, workflowID, runID))
+	return err
 }
 
 func (db *mdb) SelectAllCurrentWorkflows(ctx context.Context, shardID int, pageToken []byte, pageSize int) ([]*persistence.CurrentWorkflowExecution, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.CurrentWorkflowCollectionName, shardFilter(shardID),
+		bson.D{{Key: "domainid", Value: 1}, {Key: "workflowid", Value: 1}}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	executions := make([]*persistence.CurrentWorkflowExecution, 0, len(docs))
+	for _, doc := range docs {
+		var entry cadence.CurrentWorkflowCollectionEntry
+		if err := bson.Unmarshal(doc, &entry); err != nil {
+			return nil, nil, err
+		}
+		executions = append(executions, &persistence.CurrentWorkflowExecution{
+			DomainID:     entry.DomainID,
+			WorkflowID:   entry.WorkflowID,
+			RunID:        entry.CurrentRunID,
+			State:        entry.State,
+			CurrentRunID: entry.CurrentRunID,
+		})
+	}
+	return executions, nextPageToken, nil
 }
 
 func (db *mdb) SelectAllWorkflowExecutions(ctx context.Context, shardID int, pageToken []byte, pageSize int) ([]*persistence.InternalListConcreteExecutionsEntity, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.WorkflowExecutionCollectionName, shardFilter(shardID),
+		bson.D{{Key: "domainid", Value: 1}, {Key: "workflowid", Value: 1}, {Key: "runid", Value: 1}}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	executions := make([]*persistence.InternalListConcreteExecutionsEntity, 0, len(docs))
+	for _, doc := range docs {
+		var entry cadence.WorkflowExecutionCollectionEntry
+		if err := bson.Unmarshal(doc, &entry); err != nil {
+			return nil, nil, err
+		}
+		data := &executionData{}
+		if err := json.Unmarshal(entry.Data, data); err != nil {
+			return nil, nil, err
+		}
+		executions = append(executions, &persistence.InternalListConcreteExecutionsEntity{
+			ExecutionInfo:    data.ExecutionInfo,
+			VersionHistories: data.VersionHistories,
+		})
+	}
+	return executions, nextPageToken, nil
 }
 
 func (db *mdb) IsWorkflowExecutionExists(ctx context.Context, shardID int, domainID, workflowID, runID string) (bool, error) {
-	panic("TODO")
+	count, err := db.dbConn.Collection(cadence.WorkflowExecutionCollectionName).
+		CountDocuments(ctx, executionFilter(shardID, domainID, workflowID, runID))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
 }
 
 func (db *mdb) SelectTransferTasksOrderByTaskID(ctx context.Context, shardID, pageSize int, pageToken []byte, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.TransferTask, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.TransferTaskCollectionName,
+		shardTaskFilter(shardID, exclusiveMinTaskID, inclusiveMaxTaskID), taskIDSort, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.TransferTask, 0, len(docs))
+	for _, doc := range docs {
+		task := &nosqlplugin.TransferTask{}
+		if err := unmarshalTaskData(doc, task); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *mdb) DeleteTransferTask(ctx context.Context, shardID int, taskID int64) error {
-	panic("TODO")
+	return db.deleteOne(ctx, cadence.TransferTaskCollectionName, bson.D{{Key: "shardid", Value: shardID}, {Key: "taskid", Value: taskID}})
 }
 
 func (db *mdb) RangeDeleteTransferTasks(ctx context.Context, shardID int, exclusiveBeginTaskID, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	return db.deleteMany(ctx, cadence.TransferTaskCollectionName, shardTaskFilter(shardID, exclusiveBeginTaskID, inclusiveEndTaskID))
 }
 
 func (db *mdb) SelectTimerTasksOrderByVisibilityTime(ctx context.Context, shardID, pageSize int, pageToken []byte, inclusiveMinTime, exclusiveMaxTime time.Time) ([]*nosqlplugin.TimerTask, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.TimerTaskCollectionName,
+		timerTaskFilter(shardID, inclusiveMinTime, exclusiveMaxTime),
+		bson.D{{Key: "visibilitytimestamp", Value: 1}, {Key: "taskid", Value: 1}}, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.TimerTask, 0, len(docs))
+	for _, doc := range docs {
+		task := &nosqlplugin.TimerTask{}
+		if err := unmarshalTaskData(doc, task); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *mdb) DeleteTimerTask(ctx context.Context, shardID int, taskID int64, visibilityTimestamp time.Time) error {
-	panic("TODO")
+	return db.deleteOne(ctx, cadence.TimerTaskCollectionName, bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "visibilitytimestamp", Value: unixNano(visibilityTimestamp)},
+		{Key: "taskid", Value: taskID},
+	})
 }
 
 func (db *mdb) RangeDeleteTimerTasks(ctx context.Context, shardID int, inclusiveMinTime, exclusiveMaxTime time.Time) error {
-	panic("TODO")
+	return db.deleteMany(ctx, cadence.TimerTaskCollectionName, timerTaskFilter(shardID, inclusiveMinTime, exclusiveMaxTime))
 }
 
 func (db *mdb) SelectReplicationTasksOrderByTaskID(ctx context.Context, shardID, pageSize int, pageToken []byte, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.ReplicationTask, []byte, error) {
-	panic("TODO")
+	return db.selectReplicationTasks(ctx, cadence.ReplicationTaskCollectionName,
+		shardTaskFilter(shardID, exclusiveMinTaskID, inclusiveMaxTaskID), pageSize, pageToken)
 }
 
 func (db *mdb) DeleteReplicationTask(ctx context.Context, shardID int, taskID int64) error {
-	panic("TODO")
+	return db.deleteOne(ctx, cadence.ReplicationTaskCollectionName, bson.D{{Key: "shardid", Value: shardID}, {Key: "taskid", Value: taskID}})
 }
 
 func (db *mdb) RangeDeleteReplicationTasks(ctx context.Context, shardID int, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	return db.deleteMany(ctx, cadence.ReplicationTaskCollectionName, bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "taskid", Value: bson.D{{Key: "$lte", Value: inclusiveEndTaskID}}},
+	})
 }
 
 func (db *mdb) InsertReplicationTask(ctx context.Context, tasks []*nosqlplugin.ReplicationTask, condition nosqlplugin.ShardCondition) error {
-	panic("TODO")
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	// all the tasks are written in one transaction together with the shard condition, so that either all or none
+	// of them are written
+	txn := &workflowTransaction{}
+	db.addShardCondition(txn, &condition)
+	if err := db.createTasks(txn, condition.ShardID, nil, nil, tasks, nil); err != nil {
+		return err
+	}
+	err := db.executeTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		for _, write := range txn.writes {
+			if err := write(sessCtx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if _, ok := err.(*workflowConditionFailed); !ok {
+		return err
+	}
+
+	var shard cadence.ShardCollectionEntry
+	if err := db.dbConn.Collection(cadence.ShardCollectionName).FindOne(ctx, shardFilter(condition.ShardID)).Decode(&shard); err != nil {
+		return err
+	}
+	return &nosqlplugin.ShardOperationConditionFailure{
+		RangeID: shard.RangeID,
+		Details: fmt.Sprintf("shardid=%v, rangeid=%v", condition.ShardID, shard.RangeID),
+	}
 }
 
 func (db *mdb) SelectCrossClusterTasksOrderByTaskID(ctx context.Context, shardID, pageSize int, pageToken []byte, targetCluster string, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.CrossClusterTask, []byte, error) {
-	panic("TODO")
+	docs, nextPageToken, err := db.findPage(ctx, cadence.CrossClusterTaskCollectionName,
+		clusterTaskFilter(shardID, targetCluster, exclusiveMinTaskID, inclusiveMaxTaskID), taskIDSort, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.CrossClusterTask, 0, len(docs))
+	for _, doc := range docs {
+		task := &nosqlplugin.CrossClusterTask{
+			TargetCluster: targetCluster,
+		}
+		if err := unmarshalTaskData(doc, &task.TransferTask); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
 }
 
 func (db *mdb) DeleteCrossClusterTask(ctx context.Context, shardID int, targetCluster string, taskID int64) error {
-	panic("TODO")
+	return db.deleteOne(ctx, cadence.CrossClusterTaskCollectionName, bson.D{{Key: "shardid", Value: shardID}, {Key: "cluster", Value: targetCluster}, {Key: "taskid", Value: taskID}})
 }
 
 func (db *mdb) RangeDeleteCrossClusterTasks(ctx context.Context, shardID int, targetCluster string, exclusiveBeginTaskID, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	return db.deleteMany(ctx, cadence.CrossClusterTaskCollectionName,
+		clusterTaskFilter(shardID, targetCluster, exclusiveBeginTaskID, inclusiveEndTaskID))
 }
 
 func (db *mdb) InsertReplicationDLQTask(ctx context.Context, shardID int, sourceCluster string, task nosqlplugin.ReplicationTask) error {
-	panic("TODO")
+	data, err := json.Marshal(&task)
+	if err != nil {
+		return err
+	}
+	_, err = db.dbConn.Collection(cadence.ReplicationDLQTaskCollectionName).InsertOne(ctx, &cadence.ClusterTaskCollectionEntry{
+		ShardID: shardID,
+		Cluster: sourceCluster,
+		TaskID:  task.TaskID,
+		Data:    data,
+	})
+	return err
 }
 
 func (db *mdb) SelectReplicationDLQTasksOrderByTaskID(ctx context.Context, shardID int, sourceCluster string, pageSize int, pageToken []byte, exclusiveMinTaskID, inclusiveMaxTaskID int64) ([]*nosqlplugin.ReplicationTask, []byte, error) {
-	panic("TODO")
+	return db.selectReplicationTasks(ctx, cadence.ReplicationDLQTaskCollectionName,
+		clusterTaskFilter(shardID, sourceCluster, exclusiveMinTaskID, inclusiveMaxTaskID), pageSize, pageToken)
 }
 
 func (db *mdb) SelectReplicationDLQTasksCount(ctx context.Context, shardID int, sourceCluster string) (int64, error) {
-	panic("TODO")
+	count, err := db.dbConn.Collection(cadence.ReplicationDLQTaskCollectionName).CountDocuments(ctx, bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "cluster", Value: sourceCluster},
+	})
+	if err != nil {
+		return -1, err
+	}
+	return count, nil
 }
 
 func (db *mdb) DeleteReplicationDLQTask(ctx context.Context, shardID int, sourceCluster string, taskID int64) error {
-	panic("TODO")
+	return db.deleteOne(ctx, cadence.ReplicationDLQTaskCollectionName, bson.D{{Key: "shardid", Value: shardID}, {Key: "cluster", Value: sourceCluster}, {Key: "taskid", Value: taskID}})
 }
 
 func (db *mdb) RangeDeleteReplicationDLQTasks(ctx context.Context, shardID int, sourceCluster string, exclusiveBeginTaskID, inclusiveEndTaskID int64) error {
-	panic("TODO")
+	return db.deleteMany(ctx, cadence.ReplicationDLQTaskCollectionName,
+		clusterTaskFilter(shardID, sourceCluster, exclusiveBeginTaskID, inclusiveEndTaskID))
+}
+
+// timerTaskFilter filters the timer tasks within [inclusiveMinTime, exclusiveMaxTime)
+func timerTaskFilter(shardID int, inclusiveMinTime, exclusiveMaxTime time.Time) bson.D {
+	return bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "visibilitytimestamp", Value: bson.D{
+			{Key: "$gte", Value: unixNano(inclusiveMinTime)},
+			{Key: "$lt", Value: unixNano(exclusiveMaxTime)},
+		}},
+	}
+}
+
+// unmarshalTaskData decodes the JSON blob in the data field of a task document
+func unmarshalTaskData(doc bson.Raw, task interface{}) error {
+	value, err := doc.LookupErr("data")
+	if err != nil {
+		return err
+	}
+	_, data, ok := value.BinaryOK()
+	if !ok {
+		return fmt.Errorf("invalid task data type %v", value.Type)
+	}
+	return json.Unmarshal(data, task)
+}
+
+// selectReplicationTasks queries the tasks of replication_task or replication_dlq_task collection sorted by taskid
+func (db *mdb) selectReplicationTasks(
+	ctx context.Context,
+	collection string,
+	filter bson.D,
+	pageSize int,
+	pageToken []byte,
+) ([]*nosqlplugin.ReplicationTask, []byte, error) {
+	docs, nextPageToken, err := db.findPage(ctx, collection, filter, taskIDSort, pageSize, pageToken)
+	if err != nil {
+		return nil, nil, err
+	}
+	tasks := make([]*nosqlplugin.ReplicationTask, 0, len(docs))
+	for _, doc := range docs {
+		task := &nosqlplugin.ReplicationTask{}
+		if err := unmarshalTaskData(doc, task); err != nil {
+			return nil, nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, nextPageToken, nil
+}
+
+func (db *mdb) deleteOne(ctx context.Context, collection string, filter bson.D) error {
+	_, err := db.dbConn.Collection(collection).DeleteOne(ctx, filter)
+	return err
+}
+
+func (db *mdb) deleteMany(ctx context.Context, collection string, filter bson.D) error {
+	_, err := db.dbConn.Collection(collection).DeleteMany(ctx, filter)
+	return err
 }
@@ -0,0 +1,759 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package mongodb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/checksum"
+	p "github.com/uber/cadence/common/persistence"
+	"github.com/uber/cadence/common/persistence/nosql/nosqlplugin"
+	"github.com/uber/cadence/schema/mongodb/cadence"
+)
+
+type (
+	// executionData is the non-significant fields of workflow_execution
+	executionData struct {
+		ExecutionInfo    *p.InternalWorkflowExecutionInfo
+		VersionHistories *p.DataBlob
+		Checksum         checksum.Checksum
+		LastWriteVersion int64
+	}
+
+	// workflowConditionFailed is returned from the workflow transaction to abort it when a condition is not met.
+	// The condition failure is built after the transaction is aborted, because the transaction can't be used
+	// anymore after a write error, e.g. duplicate key.
+	workflowConditionFailed struct {
+		shard           bool
+		currentWorkflow bool
+		execution       *executionWrite
+	}
+
+	executionWrite struct {
+		request  *nosqlplugin.WorkflowExecutionRequest
+		isCreate bool
+	}
+
+	// workflowTransaction is the writes of a workflow transaction, all of them are executed in one
+	// multi-document transaction
+	workflowTransaction struct {
+		writes []func(sessCtx mongo.SessionContext) error
+	}
+)
+
+func (e *workflowConditionFailed) Error() string {
+	return "workflow condition failed"
+}
+
+func currentWorkflowFilter(shardID int, domainID, workflowID string) bson.D {
+	return bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "domainid", Value: domainID},
+		{Key: "workflowid", Value: workflowID},
+	}
+}
+
+func executionFilter(shardID int, domainID, workflowID, runID string) bson.D {
+	return bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "domainid", Value: domainID},
+		{Key: "workflowid", Value: workflowID},
+		{Key: "runid", Value: runID},
+	}
+}
+
+func shardTaskFilter(shardID int, exclusiveMinTaskID, inclusiveMaxTaskID int64) bson.D {
+	return bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "taskid", Value: bson.D{
+			{Key: "$gt", Value: exclusiveMinTaskID},
+			{Key: "$lte", Value: inclusiveMaxTaskID},
+		}},
+	}
+}
+
+func clusterTaskFilter(shardID int, cluster string, exclusiveMinTaskID, inclusiveMaxTaskID int64) bson.D {
+	return bson.D{
+		{Key: "shardid", Value: shardID},
+		{Key: "cluster", Value: cluster},
+		{Key: "taskid", Value: bson.D{
+			{Key: "$gt", Value: exclusiveMinTaskID},
+			{Key: "$lte", Value: inclusiveMaxTaskID},
+		}},
+	}
+}
+
+func (txn *workflowTransaction) add(write func(sessCtx mongo.SessionContext) error) {
+	txn.writes = append(txn.writes, write)
+}
+
+// addShardCondition increases the writecount of the shard if the rangeID matches. The write makes the transaction
+// conflict with any concurrent change of the rangeID, a read of the shard is not enough because the transaction
+// reads from a snapshot.
+func (db *mdb) addShardCondition(txn *workflowTransaction, shardCondition *nosqlplugin.ShardCondition) {
+	txn.add(func(sessCtx mongo.SessionContext) error {
+		filter := append(shardFilter(shardCondition.ShardID), bson.E{Key: "rangeid", Value: shardCondition.RangeID})
+		result, err := db.dbConn.Collection(cadence.ShardCollectionName).UpdateOne(sessCtx, filter,
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "writecount", Value: 1}}}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return &workflowConditionFailed{shard: true}
+		}
+		return nil
+	})
+}
+
+func (db *mdb) createOrUpdateCurrentWorkflow(
+	txn *workflowTransaction,
+	shardID int,
+	domainID, workflowID string,
+	request *nosqlplugin.CurrentWorkflowWriteRequest,
+) error {
+	if request.WriteMode == nosqlplugin.CurrentWorkflowWriteModeNoop {
+		return nil
+	}
+
+	row := request.Row
+	row.ShardID = shardID
+	row.DomainID = domainID
+	row.WorkflowID = workflowID
+	data, err := json.Marshal(&row)
+	if err != nil {
+		return err
+	}
+	entry := &cadence.CurrentWorkflowCollectionEntry{
+		ShardID:          shardID,
+		DomainID:         domainID,
+		WorkflowID:       workflowID,
+		CurrentRunID:     row.RunID,
+		LastWriteVersion: row.LastWriteVersion,
+		State:            row.State,
+		Data:             data,
+	}
+	collection := db.dbConn.Collection(cadence.CurrentWorkflowCollectionName)
+
+	switch request.WriteMode {
+	case nosqlplugin.CurrentWorkflowWriteModeInsert:
+		txn.add(func(sessCtx mongo.SessionContext) error {
+			_, err := collection.InsertOne(sessCtx, entry)
+			if mongo.IsDuplicateKeyError(err) {
+				return &workflowConditionFailed{currentWorkflow: true}
+			}
+			return err
+		})
+	case nosqlplugin.CurrentWorkflowWriteModeUpdate:
+		if request.Condition == nil || request.Condition.GetCurrentRunID() == "" {
+			return fmt.Errorf("CurrentWorkflowWriteModeUpdate require Condition.CurrentRunID")
+		}
+		filter := append(currentWorkflowFilter(shardID, domainID, workflowID),
+			bson.E{Key: "currentrunid", Value: request.Condition.GetCurrentRunID()})
+		if request.Condition.LastWriteVersion != nil && request.Condition.State != nil {
+			filter = append(filter,
+				bson.E{Key: "lastwriteversion", Value: *request.Condition.LastWriteVersion},
+				bson.E{Key: "state", Value: *request.Condition.State})
+		}
+		txn.add(func(sessCtx mongo.SessionContext) error {
+			result, err := collection.ReplaceOne(sessCtx, filter, entry)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return &workflowConditionFailed{currentWorkflow: true}
+			}
+			return nil
+		})
+	default:
+		return fmt.Errorf("unknown mode %v", request.WriteMode)
+	}
+	return nil
+}
+
+func newExecutionData(execution *nosqlplugin.WorkflowExecutionRequest) ([]byte, error) {
+	data := &executionData{
+		ExecutionInfo:    &execution.InternalWorkflowExecutionInfo,
+		VersionHistories: execution.VersionHistories,
+		LastWriteVersion: execution.LastWriteVersion,
+	}
+	if execution.Checksums != nil {
+		data.Checksum = *execution.Checksums
+	}
+	return json.Marshal(data)
+}
+
+// newExecutionEntry builds the whole workflow_execution document with the maps of the request
+func newExecutionEntry(shardID int, execution *nosqlplugin.WorkflowExecutionRequest) (*cadence.WorkflowExecutionCollectionEntry, error) {
+	data, err := newExecutionData(execution)
+	if err != nil {
+		return nil, err
+	}
+	// the maps must not be nil, otherwise they can't be updated by the dotted field names later
+	entry := &cadence.WorkflowExecutionCollectionEntry{
+		ShardID:             shardID,
+		DomainID:            execution.DomainID,
+		WorkflowID:          execution.WorkflowID,
+		RunID:               execution.RunID,
+		NextEventID:         execution.NextEventID,
+		Data:                data,
+		ActivityInfos:       make(map[string][]byte),
+		TimerInfos:          make(map[string][]byte),
+		ChildExecutionInfos: make(map[string][]byte),
+		RequestCancelInfos:  make(map[string][]byte),
+		SignalInfos:         make(map[string][]byte),
+		SignalRequestedIDs:  make(map[string]bool),
+		BufferedEvents:      make([][]byte, 0),
+	}
+	err = forEachMapEntry(execution, func(field, key string, value interface{}) error {
+		var target map[string][]byte
+		switch field {
+		case "activityinfos":
+			target = entry.ActivityInfos
+		case "timerinfos":
+			target = entry.TimerInfos
+		case "childexecutioninfos":
+			target = entry.ChildExecutionInfos
+		case "requestcancelinfos":
+			target = entry.RequestCancelInfos
+		case "signalinfos":
+			target = entry.SignalInfos
+		case "signalrequestedids":
+			entry.SignalRequestedIDs[key] = true
+			return nil
+		}
+		blob, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		target[key] = blob
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// forEachMapEntry calls fn with the field name, the field key and the value of every map entry to upsert
+func forEachMapEntry(execution *nosqlplugin.WorkflowExecutionRequest, fn func(field, key string, value interface{}) error) error {
+	for k, v := range execution.ActivityInfos {
+		if err := fn("activityinfos", strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.TimerInfos {
+		if err := fn("timerinfos", escapeKey(k), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.ChildWorkflowInfos {
+		if err := fn("childexecutioninfos", strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.RequestCancelInfos {
+		if err := fn("requestcancelinfos", strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for k, v := range execution.SignalInfos {
+		if err := fn("signalinfos", strconv.FormatInt(k, 10), v); err != nil {
+			return err
+		}
+	}
+	for _, k := range execution.SignalRequestedIDs {
+		if err := fn("signalrequestedids", escapeKey(k), true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapKeysToDelete returns the dotted field names of the map entries to delete
+func mapKeysToDelete(execution *nosqlplugin.WorkflowExecutionRequest) []string {
+	var fields []string
+	for _, k := range execution.ActivityInfoKeysToDelete {
+		fields = append(fields, "activityinfos."+strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.TimerInfoKeysToDelete {
+		fields = append(fields, "timerinfos."+escapeKey(k))
+	}
+	for _, k := range execution.ChildWorkflowInfoKeysToDelete {
+		fields = append(fields, "childexecutioninfos."+strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.RequestCancelInfoKeysToDelete {
+		fields = append(fields, "requestcancelinfos."+strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.SignalInfoKeysToDelete {
+		fields = append(fields, "signalinfos."+strconv.FormatInt(k, 10))
+	}
+	for _, k := range execution.SignalRequestedIDsKeysToDelete {
+		fields = append(fields, "signalrequestedids."+escapeKey(k))
+	}
+	return fields
+}
+
+// createWorkflowExecutionWithMergeMaps adds the creation of a workflow execution into the transaction
+func (db *mdb) createWorkflowExecutionWithMergeMaps(
+	txn *workflowTransaction,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+) error {
+	if execution.MapsWriteMode != nosqlplugin.WorkflowExecutionMapsWriteModeCreate {
+		return fmt.Errorf("should only support WorkflowExecutionMapsWriteModeCreate")
+	}
+	entry, err := newExecutionEntry(shardID, execution)
+	if err != nil {
+		return err
+	}
+	txn.add(func(sessCtx mongo.SessionContext) error {
+		_, err := db.dbConn.Collection(cadence.WorkflowExecutionCollectionName).InsertOne(sessCtx, entry)
+		if mongo.IsDuplicateKeyError(err) {
+			return &workflowConditionFailed{execution: &executionWrite{request: execution, isCreate: true}}
+		}
+		return err
+	})
+	return nil
+}
+
+// updateWorkflowExecutionAndEventBufferWithMergeAndDeleteMaps adds the update of a workflow execution into the transaction
+func (db *mdb) updateWorkflowExecutionAndEventBufferWithMergeAndDeleteMaps(
+	txn *workflowTransaction,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+) error {
+	if execution.MapsWriteMode != nosqlplugin.WorkflowExecutionMapsWriteModeUpdate {
+		return fmt.Errorf("should only support WorkflowExecutionMapsWriteModeUpdate")
+	}
+	if execution.PreviousNextEventIDCondition == nil {
+		return fmt.Errorf("PreviousNextEventIDCondition is required for updating workflow execution")
+	}
+	data, err := newExecutionData(execution)
+	if err != nil {
+		return err
+	}
+
+	// a field can't be both set and unset in one update, the deletion wins
+	unset := bson.D{}
+	deleted := make(map[string]struct{})
+	for _, field := range mapKeysToDelete(execution) {
+		deleted[field] = struct{}{}
+		unset = append(unset, bson.E{Key: field, Value: ""})
+	}
+	set := bson.D{
+		{Key: "nexteventid", Value: execution.NextEventID},
+		{Key: "data", Value: data},
+	}
+	err = forEachMapEntry(execution, func(field, key string, value interface{}) error {
+		name := field + "." + key
+		if _, ok := deleted[name]; ok {
+			return nil
+		}
+		if field == "signalrequestedids" {
+			set = append(set, bson.E{Key: name, Value: true})
+			return nil
+		}
+		blob, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		set = append(set, bson.E{Key: name, Value: blob})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	update := bson.D{}
+	switch execution.EventBufferWriteMode {
+	case nosqlplugin.EventBufferWriteModeNone:
+	case nosqlplugin.EventBufferWriteModeAppend:
+		blob, err := json.Marshal(execution.NewBufferedEventBatch)
+		if err != nil {
+			return err
+		}
+		update = append(update, bson.E{Key: "$push", Value: bson.D{{Key: "bufferedevents", Value: blob}}})
+	case nosqlplugin.EventBufferWriteModeClear:
+		set = append(set, bson.E{Key: "bufferedevents", Value: bson.A{}})
+	default:
+		return fmt.Errorf("unknown EventBufferWriteMode %v", execution.EventBufferWriteMode)
+	}
+	update = append(update, bson.E{Key: "$set", Value: set})
+	if len(unset) > 0 {
+		update = append(update, bson.E{Key: "$unset", Value: unset})
+	}
+
+	filter := append(executionFilter(shardID, execution.DomainID, execution.WorkflowID, execution.RunID),
+		bson.E{Key: "nexteventid", Value: *execution.PreviousNextEventIDCondition})
+	txn.add(func(sessCtx mongo.SessionContext) error {
+		result, err := db.dbConn.Collection(cadence.WorkflowExecutionCollectionName).UpdateOne(sessCtx, filter, update)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return &workflowConditionFailed{execution: &executionWrite{request: execution}}
+		}
+		return nil
+	})
+	return nil
+}
+
+// resetWorkflowExecutionAndMapsAndEventBuffer adds the reset of a workflow execution into the transaction
+func (db *mdb) resetWorkflowExecutionAndMapsAndEventBuffer(
+	txn *workflowTransaction,
+	shardID int,
+	execution *nosqlplugin.WorkflowExecutionRequest,
+) error {
+	if execution.MapsWriteMode != nosqlplugin.WorkflowExecutionMapsWriteModeReset {
+		return fmt.Errorf("should only support WorkflowExecutionMapsWriteModeReset")
+	}
+	if execution.EventBufferWriteMode != nosqlplugin.EventBufferWriteModeClear {
+		return fmt.Errorf("should only support EventBufferWriteModeClear")
+	}
+	if execution.PreviousNextEventIDCondition == nil {
+		return fmt.Errorf("PreviousNextEventIDCondition is required for resetting workflow execution")
+	}
+	entry, err := newExecutionEntry(shardID, execution)
+	if err != nil {
+		return err
+	}
+	filter := append(executionFilter(shardID, execution.DomainID, execution.WorkflowID, execution.RunID),
+		bson.E{Key: "nexteventid", Value: *execution.PreviousNextEventIDCondition})
+	txn.add(func(sessCtx mongo.SessionContext) error {
+		result, err := db.dbConn.Collection(cadence.WorkflowExecutionCollectionName).ReplaceOne(sessCtx, filter, entry)
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return &workflowConditionFailed{execution: &executionWrite{request: execution}}
+		}
+		return nil
+	})
+	return nil
+}
+
+// createTasks adds the inserts of all the tasks of a workflow write into the transaction
+func (db *mdb) createTasks(
+	txn *workflowTransaction,
+	shardID int,
+	transferTasks []*nosqlplugin.TransferTask,
+	crossClusterTasks []*nosqlplugin.CrossClusterTask,
+	replicationTasks []*nosqlplugin.ReplicationTask,
+	timerTasks []*nosqlplugin.TimerTask,
+) error {
+	insert := func(collection string, entries []interface{}) {
+		if len(entries) == 0 {
+			return
+		}
+		txn.add(func(sessCtx mongo.SessionContext) error {
+			_, err := db.dbConn.Collection(collection).InsertMany(sessCtx, entries)
+			return err
+		})
+	}
+
+	var entries []interface{}
+	for _, task := range transferTasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &cadence.ShardTaskCollectionEntry{ShardID: shardID, TaskID: task.TaskID, Data: data})
+	}
+	insert(cadence.TransferTaskCollectionName, entries)
+
+	entries = nil
+	for _, task := range crossClusterTasks {
+		data, err := json.Marshal(&task.TransferTask)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &cadence.ClusterTaskCollectionEntry{
+			ShardID: shardID,
+			Cluster: task.TargetCluster,
+			TaskID:  task.TaskID,
+			Data:    data,
+		})
+	}
+	insert(cadence.CrossClusterTaskCollectionName, entries)
+
+	entries = nil
+	for _, task := range replicationTasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &cadence.ShardTaskCollectionEntry{ShardID: shardID, TaskID: task.TaskID, Data: data})
+	}
+	insert(cadence.ReplicationTaskCollectionName, entries)
+
+	entries = nil
+	for _, task := range timerTasks {
+		data, err := json.Marshal(task)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &cadence.TimerTaskCollectionEntry{
+			ShardID:             shardID,
+			VisibilityTimestamp: unixNano(task.VisibilityTimestamp),
+			TaskID:              task.TaskID,
+			Data:                data,
+		})
+	}
+	insert(cadence.TimerTaskCollectionName, entries)
+	return nil
+}
+
+// executeWorkflowTransaction executes the shard condition, the workflow writes and the tasks in one transaction.
+// The shard condition is executed first, so that a shard ownership change is reported before any other condition.
+func (db *mdb) executeWorkflowTransaction(
+	ctx context.Context,
+	txn *workflowTransaction,
+	currentWorkflowRequest *nosqlplugin.CurrentWorkflowWriteRequest,
+	shardCondition *nosqlplugin.ShardCondition,
+) error {
+	err := db.executeTransaction(ctx, func(sessCtx mongo.SessionContext) error {
+		for _, write := range txn.writes {
+			if err := write(sessCtx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	failure, ok := err.(*workflowConditionFailed)
+	if !ok {
+		return err
+	}
+
+	switch {
+	case failure.shard:
+		return db.getShardConditionFailure(ctx, shardCondition)
+	case failure.currentWorkflow:
+		return db.getCurrentWorkflowConditionFailure(ctx, currentWorkflowRequest, shardCondition)
+	case failure.execution != nil:
+		return db.getExecutionConditionFailure(ctx, failure.execution, currentWorkflowRequest, shardCondition)
+	}
+	msg := fmt.Sprintf("Failed to operate on workflow execution.  Request RangeID: %v", shardCondition.RangeID)
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		UnknownConditionFailureDetails: &msg,
+	}
+}
+
+func (db *mdb) getShardConditionFailure(ctx context.Context, shardCondition *nosqlplugin.ShardCondition) error {
+	var shard cadence.ShardCollectionEntry
+	err := db.dbConn.Collection(cadence.ShardCollectionName).FindOne(ctx, shardFilter(shardCondition.ShardID)).Decode(&shard)
+	if err != nil {
+		return err
+	}
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		ShardRangeIDNotMatch: common.Int64Ptr(shard.RangeID),
+	}
+}
+
+func (db *mdb) getCurrentWorkflowConditionFailure(
+	ctx context.Context,
+	request *nosqlplugin.CurrentWorkflowWriteRequest,
+	shardCondition *nosqlplugin.ShardCondition,
+) error {
+	row := request.Row
+	var current cadence.CurrentWorkflowCollectionEntry
+	err := db.dbConn.Collection(cadence.CurrentWorkflowCollectionName).
+		FindOne(ctx, currentWorkflowFilter(shardCondition.ShardID, row.DomainID, row.WorkflowID)).Decode(&current)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Workflow execution condition failed, current workflow doesn't exist. WorkflowId: %v, Expected Current RunID: %v",
+			row.WorkflowID, request.Condition.GetCurrentRunID())
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			CurrentWorkflowConditionFailInfo: &msg,
+		}
+	}
+	previous := &nosqlplugin.CurrentWorkflowRow{}
+	if err := json.Unmarshal(current.Data, previous); err != nil {
+		return err
+	}
+
+	if request.WriteMode == nosqlplugin.CurrentWorkflowWriteModeInsert {
+		msg := fmt.Sprintf("Workflow execution already running. WorkflowId: %v, RunId: %v, rangeID: %v",
+			previous.WorkflowID, previous.RunID, shardCondition.RangeID)
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			WorkflowExecutionAlreadyExists: &nosqlplugin.WorkflowExecutionAlreadyExists{
+				OtherInfo:        msg,
+				CreateRequestID:  previous.CreateRequestID,
+				RunID:            previous.RunID,
+				State:            previous.State,
+				CloseStatus:      previous.CloseStatus,
+				LastWriteVersion: previous.LastWriteVersion,
+			},
+		}
+	}
+
+	if requestRunID := request.Condition.GetCurrentRunID(); current.CurrentRunID != requestRunID {
+		msg := fmt.Sprintf("Workflow execution condition failed by mismatch runID. WorkflowId: %v, Expected Current RunID: %v, Actual Current RunID: %v",
+			row.WorkflowID, requestRunID, current.CurrentRunID)
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			CurrentWorkflowConditionFailInfo: &msg,
+		}
+	}
+	msg := fmt.Sprintf("Workflow execution condition failed. WorkflowId: %v, CurrentRunID: %v, Actual LastWriteVersion: %v, Actual State: %v",
+		row.WorkflowID, current.CurrentRunID, current.LastWriteVersion, current.State)
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		CurrentWorkflowConditionFailInfo: &msg,
+	}
+}
+
+func (db *mdb) getExecutionConditionFailure(
+	ctx context.Context,
+	write *executionWrite,
+	currentWorkflowRequest *nosqlplugin.CurrentWorkflowWriteRequest,
+	shardCondition *nosqlplugin.ShardCondition,
+) error {
+	execution := write.request
+	if write.isCreate {
+		msg := fmt.Sprintf("Workflow execution already running. WorkflowId: %v, RunId: %v, rangeID: %v",
+			execution.WorkflowID, execution.RunID, shardCondition.RangeID)
+		return &nosqlplugin.WorkflowOperationConditionFailure{
+			WorkflowExecutionAlreadyExists: &nosqlplugin.WorkflowExecutionAlreadyExists{
+				OtherInfo:        msg,
+				CreateRequestID:  execution.CreateRequestID,
+				RunID:            execution.RunID,
+				State:            execution.State,
+				CloseStatus:      execution.CloseStatus,
+				LastWriteVersion: execution.LastWriteVersion,
+			},
+		}
+	}
+
+	var actual cadence.WorkflowExecutionCollectionEntry
+	err := db.dbConn.Collection(cadence.WorkflowExecutionCollectionName).
+		FindOne(ctx, executionFilter(shardCondition.ShardID, execution.DomainID, execution.WorkflowID, execution.RunID)).
+		Decode(&actual)
+	if err != nil && !db.IsNotFoundError(err) {
+		return err
+	}
+	requestConditionalRunID := ""
+	if currentWorkflowRequest.Condition != nil {
+		requestConditionalRunID = currentWorkflowRequest.Condition.GetCurrentRunID()
+	}
+	msg := fmt.Sprintf("Failed to update mutable state.  Request Condition: %v, Actual Value: %v, Request Current RunID: %v",
+		*execution.PreviousNextEventIDCondition, actual.NextEventID, requestConditionalRunID)
+	return &nosqlplugin.WorkflowOperationConditionFailure{
+		UnknownConditionFailureDetails: &msg,
+	}
+}
+
+// toWorkflowExecution decodes a workflow_execution document
+func toWorkflowExecution(entry *cadence.WorkflowExecutionCollectionEntry) (*nosqlplugin.WorkflowExecution, error) {
+	data := &executionData{}
+	if err := json.Unmarshal(entry.Data, data); err != nil {
+		return nil, err
+	}
+	state := &nosqlplugin.WorkflowExecution{
+		ExecutionInfo:       data.ExecutionInfo,
+		VersionHistories:    data.VersionHistories,
+		ActivityInfos:       make(map[int64]*p.InternalActivityInfo),
+		TimerInfos:          make(map[string]*p.TimerInfo),
+		ChildExecutionInfos: make(map[int64]*p.InternalChildExecutionInfo),
+		RequestCancelInfos:  make(map[int64]*p.RequestCancelInfo),
+		SignalInfos:         make(map[int64]*p.SignalInfo),
+		SignalRequestedIDs:  make(map[string]struct{}),
+		BufferedEvents:      make([]*p.DataBlob, 0, len(entry.BufferedEvents)),
+		Checksum:            data.Checksum,
+	}
+
+	for k, v := range entry.ActivityInfos {
+		id, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		info := &p.InternalActivityInfo{}
+		if err := json.Unmarshal(v, info); err != nil {
+			return nil, err
+		}
+		state.ActivityInfos[id] = info
+	}
+	for k, v := range entry.TimerInfos {
+		id, err := unescapeKey(k)
+		if err != nil {
+			return nil, err
+		}
+		info := &p.TimerInfo{}
+		if err := json.Unmarshal(v, info); err != nil {
+			return nil, err
+		}
+		state.TimerInfos[id] = info
+	}
+	for k, v := range entry.ChildExecutionInfos {
+		id, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		info := &p.InternalChildExecutionInfo{}
+		if err := json.Unmarshal(v, info); err != nil {
+			return nil, err
+		}
+		state.ChildExecutionInfos[id] = info
+	}
+	for k, v := range entry.RequestCancelInfos {
+		id, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		info := &p.RequestCancelInfo{}
+		if err := json.Unmarshal(v, info); err != nil {
+			return nil, err
+		}
+		state.RequestCancelInfos[id] = info
+	}
+	for k, v := range entry.SignalInfos {
+		id, err := strconv.ParseInt(k, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		info := &p.SignalInfo{}
+		if err := json.Unmarshal(v, info); err != nil {
+			return nil, err
+		}
+		state.SignalInfos[id] = info
+	}
+	for k := range entry.SignalRequestedIDs {
+		id, err := unescapeKey(k)
+		if err != nil {
+			return nil, err
+		}
+		state.SignalRequestedIDs[id] = struct{}{}
+	}
+	for _, v := range entry.BufferedEvents {
+		blob := &p.DataBlob{}
+		if err := json.Unmarshal(v, blob); err != nil {
+			return nil, err
+		}
+		state.BufferedEvents = append(state.BufferedEvents, blob)
+	}
+	return state, nil
+}
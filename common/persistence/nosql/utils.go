@@ -49,6 +49,11 @@ func convertCommonErrors(
 		}
 	}
 
+	if sizeErr, ok := err.(*p.TransactionSizeLimitError); ok {
+		// the plugin has rejected the write before sending it to the database
+		return sizeErr
+	}
+
 	return &types.InternalServiceError{
 		Message: fmt.Sprintf("%v operation failed. Error: %v", operation, err),
 	}
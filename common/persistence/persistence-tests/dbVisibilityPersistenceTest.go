@@ -22,7 +22,9 @@ package persistencetests
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"strconv"
 	"testing"
 	"time"
 
@@ -800,6 +802,12 @@ func (s *DBVisibilityPersistenceSuite) TestUpsertWorkflowExecution() {
 	ctx, cancel := context.WithTimeout(context.Background(), testContextTimeout)
 	defer cancel()
 
+	// upsert is a noop on NoSQL stores when only CadenceChangeVersion is set and not supported otherwise
+	var errNotSupported error
+	if !s.supportsQueryVisibility() {
+		errNotSupported = p.NewOperationNotSupportErrorForVis()
+	}
+
 	tests := []struct {
 		request  *p.UpsertWorkflowExecutionRequest
 		expected error
@@ -816,7 +824,7 @@ func (s *DBVisibilityPersistenceSuite) TestUpsertWorkflowExecution() {
 				TaskID:             0,
 				Memo:               nil,
 				SearchAttributes: map[string][]byte{
-					definition.CadenceChangeVersion: []byte("dummy"),
+					definition.CadenceChangeVersion: []byte(`"dummy"`),
 				},
 			},
 			expected: nil,
@@ -834,7 +842,7 @@ func (s *DBVisibilityPersistenceSuite) TestUpsertWorkflowExecution() {
 				Memo:               nil,
 				SearchAttributes:   nil,
 			},
-			expected: p.NewOperationNotSupportErrorForVis(),
+			expected: errNotSupported,
 		},
 	}
 
@@ -843,6 +851,110 @@ func (s *DBVisibilityPersistenceSuite) TestUpsertWorkflowExecution() {
 	}
 }
 
+// TestListWorkflowExecutionsByQuery test
+func (s *DBVisibilityPersistenceSuite) TestListWorkflowExecutionsByQuery() {
+	ctx, cancel := context.WithTimeout(context.Background(), testContextTimeout)
+	defer cancel()
+
+	if !s.supportsQueryVisibility() {
+		s.T().Skipf("query-based visibility is not supported in %v", s.VisibilityMgr.GetName())
+	}
+
+	testDomainUUID := uuid.New()
+	startTime := time.Now().Add(time.Second * -5).UnixNano()
+	var executions []types.WorkflowExecution
+	for i := 0; i < 3; i++ {
+		workflowExecution := types.WorkflowExecution{
+			WorkflowID: fmt.Sprintf("visibility-query-workflow-%v", i),
+			RunID:      uuid.New(),
+		}
+		err0 := s.VisibilityMgr.RecordWorkflowExecutionStarted(ctx, &p.RecordWorkflowExecutionStartedRequest{
+			DomainUUID:       testDomainUUID,
+			Execution:        workflowExecution,
+			WorkflowTypeName: "visibility-workflow",
+			StartTimestamp:   startTime + int64(i),
+			SearchAttributes: map[string][]byte{
+				definition.CustomIntField: []byte(strconv.Itoa(i)),
+			},
+		})
+		s.Nil(err0)
+		executions = append(executions, workflowExecution)
+	}
+
+	err1 := s.VisibilityMgr.UpsertWorkflowExecution(ctx, &p.UpsertWorkflowExecutionRequest{
+		DomainUUID:       testDomainUUID,
+		Execution:        executions[0],
+		WorkflowTypeName: "visibility-workflow",
+		StartTimestamp:   startTime,
+		SearchAttributes: map[string][]byte{
+			definition.CustomIntField:     []byte("10"),
+			definition.CustomKeywordField: []byte(`"upserted"`),
+		},
+	})
+	s.Nil(err1)
+
+	resp, err2 := s.VisibilityMgr.ListWorkflowExecutions(ctx, &p.ListWorkflowExecutionsByQueryRequest{
+		DomainUUID: testDomainUUID,
+		PageSize:   10,
+		Query:      "`Attr.CustomIntField` >= 1 and CloseTime = missing order by `Attr.CustomIntField` desc",
+	})
+	s.Nil(err2)
+	s.Equal(3, len(resp.Executions))
+	s.Equal(executions[0].RunID, resp.Executions[0].Execution.RunID)
+	s.Equal(executions[2].RunID, resp.Executions[1].Execution.RunID)
+	s.Equal(executions[1].RunID, resp.Executions[2].Execution.RunID)
+
+	resp, err3 := s.VisibilityMgr.ListWorkflowExecutions(ctx, &p.ListWorkflowExecutionsByQueryRequest{
+		DomainUUID: testDomainUUID,
+		PageSize:   10,
+		Query:      "`Attr.CustomKeywordField` = 'upserted'",
+	})
+	s.Nil(err3)
+	s.Equal(1, len(resp.Executions))
+	s.Equal(executions[0].RunID, resp.Executions[0].Execution.RunID)
+	s.Equal([]byte(`"upserted"`), resp.Executions[0].SearchAttributes.IndexedFields[definition.CustomKeywordField])
+
+	var runIDs []string
+	var nextPageToken []byte
+	for {
+		resp, err4 := s.VisibilityMgr.ScanWorkflowExecutions(ctx, &p.ListWorkflowExecutionsByQueryRequest{
+			DomainUUID:    testDomainUUID,
+			PageSize:      2,
+			NextPageToken: nextPageToken,
+			Query:         "WorkflowType = 'visibility-workflow'",
+		})
+		s.Nil(err4)
+		for _, execution := range resp.Executions {
+			runIDs = append(runIDs, execution.Execution.RunID)
+		}
+		nextPageToken = resp.NextPageToken
+		if len(nextPageToken) == 0 {
+			break
+		}
+	}
+	s.Equal([]string{executions[2].RunID, executions[1].RunID, executions[0].RunID}, runIDs)
+
+	countResp, err5 := s.VisibilityMgr.CountWorkflowExecutions(ctx, &p.CountWorkflowExecutionsRequest{
+		DomainUUID: testDomainUUID,
+		Query:      "`Attr.CustomIntField` < 10",
+	})
+	s.Nil(err5)
+	s.Equal(int64(2), countResp.Count)
+
+	_, err6 := s.VisibilityMgr.CountWorkflowExecutions(ctx, &p.CountWorkflowExecutionsRequest{
+		DomainUUID: testDomainUUID,
+		Query:      "`Attr.UnknownField` = 1",
+	})
+	s.IsType(&types.BadRequestError{}, err6)
+}
+
+// supportsQueryVisibility returns true if the visibility store supports query-based visibility and search
+// attribute upserts, which are only implemented by the SQL stores. All the NoSQL stores share nosqlVisibilityStore.
+func (s *DBVisibilityPersistenceSuite) supportsQueryVisibility() bool {
+	vCfg := s.VisibilityTestCluster.Config()
+	return vCfg.DataStores[vCfg.VisibilityStore].SQL != nil
+}
+
 func (s *DBVisibilityPersistenceSuite) assertClosedExecutionEquals(
 	req *p.RecordWorkflowExecutionClosedRequest, resp *types.WorkflowExecutionInfo) {
 	s.Equal(req.Execution.RunID, resp.Execution.RunID)
@@ -31,6 +31,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 
+	"github.com/uber/cadence/common/config"
 	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/types"
 )
@@ -555,8 +556,8 @@ func (s *MatchingPersistenceSuite) TestGetOrphanTasks() {
 	if os.Getenv("SKIP_GET_ORPHAN_TASKS") != "" {
 		s.T().Skipf("GetOrphanTasks not supported in %v", s.TaskMgr.GetName())
 	}
-	if s.TaskMgr.GetName() == "cassandra" {
-		// GetOrphanTasks API is currently not supported in cassandra"
+	if cfg := s.Config(); cfg.DefaultStoreType() != config.StoreTypeSQL {
+		// GetOrphanTasks API is currently not supported in NoSQL stores
 		return
 	}
 	s.deleteAllTaskList()
@@ -0,0 +1,735 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package persistence
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/pborman/uuid"
+
+	"github.com/uber/cadence/common/dynamicconfig"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/metrics"
+)
+
+type (
+	// persistenceDualWriteClientBase mirrors successful writes of the primary store to the secondary store,
+	// and optionally shadows reads to the secondary store to compare the results.
+	// The primary store is always the source of truth: failures of the secondary store are only
+	// logged and emitted as metrics, they are never returned to the caller.
+	persistenceDualWriteClientBase struct {
+		metricClient      metrics.Client
+		logger            log.Logger
+		dualWriteEnabled  dynamicconfig.BoolPropertyFn
+		shadowReadEnabled dynamicconfig.BoolPropertyFn
+	}
+
+	shardDualWriteClient struct {
+		persistenceDualWriteClientBase
+		primary   ShardManager
+		secondary ShardManager
+	}
+
+	workflowExecutionDualWriteClient struct {
+		persistenceDualWriteClientBase
+		primary   ExecutionManager
+		secondary ExecutionManager
+	}
+
+	taskDualWriteClient struct {
+		persistenceDualWriteClientBase
+		primary   TaskManager
+		secondary TaskManager
+	}
+
+	historyDualWriteClient struct {
+		persistenceDualWriteClientBase
+		primary   HistoryManager
+		secondary HistoryManager
+	}
+)
+
+var _ ShardManager = (*shardDualWriteClient)(nil)
+var _ ExecutionManager = (*workflowExecutionDualWriteClient)(nil)
+var _ TaskManager = (*taskDualWriteClient)(nil)
+var _ HistoryManager = (*historyDualWriteClient)(nil)
+
+// NewShardPersistenceDualWriteClient creates a client to manage shards, mirroring writes to the secondary store
+func NewShardPersistenceDualWriteClient(
+	primary ShardManager,
+	secondary ShardManager,
+	dualWriteEnabled dynamicconfig.BoolPropertyFn,
+	shadowReadEnabled dynamicconfig.BoolPropertyFn,
+	metricClient metrics.Client,
+	logger log.Logger,
+) ShardManager {
+	return &shardDualWriteClient{
+		primary:                        primary,
+		secondary:                      secondary,
+		persistenceDualWriteClientBase: newPersistenceDualWriteClientBase(dualWriteEnabled, shadowReadEnabled, metricClient, logger),
+	}
+}
+
+// NewWorkflowExecutionPersistenceDualWriteClient creates a client to manage executions, mirroring writes to the secondary store
+func NewWorkflowExecutionPersistenceDualWriteClient(
+	primary ExecutionManager,
+	secondary ExecutionManager,
+	dualWriteEnabled dynamicconfig.BoolPropertyFn,
+	shadowReadEnabled dynamicconfig.BoolPropertyFn,
+	metricClient metrics.Client,
+	logger log.Logger,
+) ExecutionManager {
+	return &workflowExecutionDualWriteClient{
+		primary:   primary,
+		secondary: secondary,
+		persistenceDualWriteClientBase: newPersistenceDualWriteClientBase(
+			dualWriteEnabled,
+			shadowReadEnabled,
+			metricClient,
+			logger.WithTags(tag.ShardID(primary.GetShardID())),
+		),
+	}
+}
+
+// NewTaskPersistenceDualWriteClient creates a client to manage tasks, mirroring writes to the secondary store
+func NewTaskPersistenceDualWriteClient(
+	primary TaskManager,
+	secondary TaskManager,
+	dualWriteEnabled dynamicconfig.BoolPropertyFn,
+	shadowReadEnabled dynamicconfig.BoolPropertyFn,
+	metricClient metrics.Client,
+	logger log.Logger,
+) TaskManager {
+	return &taskDualWriteClient{
+		primary:                        primary,
+		secondary:                      secondary,
+		persistenceDualWriteClientBase: newPersistenceDualWriteClientBase(dualWriteEnabled, shadowReadEnabled, metricClient, logger),
+	}
+}
+
+// NewHistoryPersistenceDualWriteClient creates a HistoryManager client to manage workflow execution history,
+// mirroring writes to the secondary store
+func NewHistoryPersistenceDualWriteClient(
+	primary HistoryManager,
+	secondary HistoryManager,
+	dualWriteEnabled dynamicconfig.BoolPropertyFn,
+	shadowReadEnabled dynamicconfig.BoolPropertyFn,
+	metricClient metrics.Client,
+	logger log.Logger,
+) HistoryManager {
+	return &historyDualWriteClient{
+		primary:                        primary,
+		secondary:                      secondary,
+		persistenceDualWriteClientBase: newPersistenceDualWriteClientBase(dualWriteEnabled, shadowReadEnabled, metricClient, logger),
+	}
+}
+
+func newPersistenceDualWriteClientBase(
+	dualWriteEnabled dynamicconfig.BoolPropertyFn,
+	shadowReadEnabled dynamicconfig.BoolPropertyFn,
+	metricClient metrics.Client,
+	logger log.Logger,
+) persistenceDualWriteClientBase {
+	if dualWriteEnabled == nil {
+		dualWriteEnabled = dynamicconfig.GetBoolPropertyFn(false)
+	}
+	if shadowReadEnabled == nil {
+		shadowReadEnabled = dynamicconfig.GetBoolPropertyFn(false)
+	}
+	return persistenceDualWriteClientBase{
+		metricClient:      metricClient,
+		logger:            logger,
+		dualWriteEnabled:  dualWriteEnabled,
+		shadowReadEnabled: shadowReadEnabled,
+	}
+}
+
+// mirror applies a write to the secondary store once it succeeded on the primary store
+func (p *persistenceDualWriteClientBase) mirror(scope int, primaryErr error, op func() error) {
+	if primaryErr != nil || !p.dualWriteEnabled() {
+		return
+	}
+	if err := op(); err != nil {
+		p.metricClient.IncCounter(scope, metrics.PersistenceDualWriteFailures)
+		p.logger.Warn("Failed to mirror write to secondary store.", tag.Error(err), tag.MetricScope(scope))
+	}
+}
+
+// shadow reads from the secondary store and compares the result with the one of the primary store
+func (p *persistenceDualWriteClientBase) shadow(scope int, primaryResult interface{}, primaryErr error, op func() (interface{}, error)) {
+	if primaryErr != nil || !p.shadowReadEnabled() {
+		return
+	}
+	result, err := op()
+	if err != nil {
+		p.metricClient.IncCounter(scope, metrics.PersistenceShadowReadFailures)
+		p.logger.Warn("Failed to shadow read from secondary store.", tag.Error(err), tag.MetricScope(scope))
+		return
+	}
+	if !reflect.DeepEqual(primaryResult, result) {
+		p.metricClient.IncCounter(scope, metrics.PersistenceShadowReadMismatches)
+		p.logger.Warn("Shadow read from secondary store does not match primary store.", tag.MetricScope(scope))
+	}
+}
+
+func (p *shardDualWriteClient) GetName() string {
+	return p.primary.GetName()
+}
+
+func (p *shardDualWriteClient) CreateShard(
+	ctx context.Context,
+	request *CreateShardRequest,
+) error {
+	err := p.primary.CreateShard(ctx, request)
+	p.mirror(metrics.PersistenceCreateShardScope, err, func() error {
+		return p.secondary.CreateShard(ctx, request)
+	})
+	return err
+}
+
+func (p *shardDualWriteClient) GetShard(
+	ctx context.Context,
+	request *GetShardRequest,
+) (*GetShardResponse, error) {
+	resp, err := p.primary.GetShard(ctx, request)
+	p.shadow(metrics.PersistenceGetShardScope, resp, err, func() (interface{}, error) {
+		return p.secondary.GetShard(ctx, request)
+	})
+	return resp, err
+}
+
+func (p *shardDualWriteClient) UpdateShard(
+	ctx context.Context,
+	request *UpdateShardRequest,
+) error {
+	err := p.primary.UpdateShard(ctx, request)
+	p.mirror(metrics.PersistenceUpdateShardScope, err, func() error {
+		return p.secondary.UpdateShard(ctx, request)
+	})
+	return err
+}
+
+func (p *shardDualWriteClient) Close() {
+	p.primary.Close()
+	p.secondary.Close()
+}
+
+func (p *workflowExecutionDualWriteClient) GetName() string {
+	return p.primary.GetName()
+}
+
+func (p *workflowExecutionDualWriteClient) GetShardID() int {
+	return p.primary.GetShardID()
+}
+
+func (p *workflowExecutionDualWriteClient) CreateWorkflowExecution(
+	ctx context.Context,
+	request *CreateWorkflowExecutionRequest,
+) (*CreateWorkflowExecutionResponse, error) {
+	resp, err := p.primary.CreateWorkflowExecution(ctx, request)
+	p.mirror(metrics.PersistenceCreateWorkflowExecutionScope, err, func() error {
+		_, err := p.secondary.CreateWorkflowExecution(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) GetWorkflowExecution(
+	ctx context.Context,
+	request *GetWorkflowExecutionRequest,
+) (*GetWorkflowExecutionResponse, error) {
+	resp, err := p.primary.GetWorkflowExecution(ctx, request)
+	if err == nil {
+		// the stats depend on the encoding of each store, only the state is compared
+		p.shadow(metrics.PersistenceGetWorkflowExecutionScope, resp.State, err, func() (interface{}, error) {
+			secondaryResp, err := p.secondary.GetWorkflowExecution(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			return secondaryResp.State, nil
+		})
+	}
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) UpdateWorkflowExecution(
+	ctx context.Context,
+	request *UpdateWorkflowExecutionRequest,
+) (*UpdateWorkflowExecutionResponse, error) {
+	resp, err := p.primary.UpdateWorkflowExecution(ctx, request)
+	p.mirror(metrics.PersistenceUpdateWorkflowExecutionScope, err, func() error {
+		_, err := p.secondary.UpdateWorkflowExecution(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) ConflictResolveWorkflowExecution(
+	ctx context.Context,
+	request *ConflictResolveWorkflowExecutionRequest,
+) (*ConflictResolveWorkflowExecutionResponse, error) {
+	resp, err := p.primary.ConflictResolveWorkflowExecution(ctx, request)
+	p.mirror(metrics.PersistenceConflictResolveWorkflowExecutionScope, err, func() error {
+		_, err := p.secondary.ConflictResolveWorkflowExecution(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) DeleteWorkflowExecution(
+	ctx context.Context,
+	request *DeleteWorkflowExecutionRequest,
+) error {
+	err := p.primary.DeleteWorkflowExecution(ctx, request)
+	p.mirror(metrics.PersistenceDeleteWorkflowExecutionScope, err, func() error {
+		return p.secondary.DeleteWorkflowExecution(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) DeleteCurrentWorkflowExecution(
+	ctx context.Context,
+	request *DeleteCurrentWorkflowExecutionRequest,
+) error {
+	err := p.primary.DeleteCurrentWorkflowExecution(ctx, request)
+	p.mirror(metrics.PersistenceDeleteCurrentWorkflowExecutionScope, err, func() error {
+		return p.secondary.DeleteCurrentWorkflowExecution(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) GetCurrentExecution(
+	ctx context.Context,
+	request *GetCurrentExecutionRequest,
+) (*GetCurrentExecutionResponse, error) {
+	resp, err := p.primary.GetCurrentExecution(ctx, request)
+	p.shadow(metrics.PersistenceGetCurrentExecutionScope, resp, err, func() (interface{}, error) {
+		return p.secondary.GetCurrentExecution(ctx, request)
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) IsWorkflowExecutionExists(
+	ctx context.Context,
+	request *IsWorkflowExecutionExistsRequest,
+) (*IsWorkflowExecutionExistsResponse, error) {
+	return p.primary.IsWorkflowExecutionExists(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) GetTransferTasks(
+	ctx context.Context,
+	request *GetTransferTasksRequest,
+) (*GetTransferTasksResponse, error) {
+	return p.primary.GetTransferTasks(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) CompleteTransferTask(
+	ctx context.Context,
+	request *CompleteTransferTaskRequest,
+) error {
+	err := p.primary.CompleteTransferTask(ctx, request)
+	p.mirror(metrics.PersistenceCompleteTransferTaskScope, err, func() error {
+		return p.secondary.CompleteTransferTask(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) RangeCompleteTransferTask(
+	ctx context.Context,
+	request *RangeCompleteTransferTaskRequest,
+) (*RangeCompleteTransferTaskResponse, error) {
+	resp, err := p.primary.RangeCompleteTransferTask(ctx, request)
+	p.mirror(metrics.PersistenceRangeCompleteTransferTaskScope, err, func() error {
+		_, err := p.secondary.RangeCompleteTransferTask(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) GetCrossClusterTasks(
+	ctx context.Context,
+	request *GetCrossClusterTasksRequest,
+) (*GetCrossClusterTasksResponse, error) {
+	return p.primary.GetCrossClusterTasks(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) CompleteCrossClusterTask(
+	ctx context.Context,
+	request *CompleteCrossClusterTaskRequest,
+) error {
+	err := p.primary.CompleteCrossClusterTask(ctx, request)
+	p.mirror(metrics.PersistenceCompleteCrossClusterTaskScope, err, func() error {
+		return p.secondary.CompleteCrossClusterTask(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) RangeCompleteCrossClusterTask(
+	ctx context.Context,
+	request *RangeCompleteCrossClusterTaskRequest,
+) (*RangeCompleteCrossClusterTaskResponse, error) {
+	resp, err := p.primary.RangeCompleteCrossClusterTask(ctx, request)
+	p.mirror(metrics.PersistenceRangeCompleteCrossClusterTaskScope, err, func() error {
+		_, err := p.secondary.RangeCompleteCrossClusterTask(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) GetReplicationTasks(
+	ctx context.Context,
+	request *GetReplicationTasksRequest,
+) (*GetReplicationTasksResponse, error) {
+	return p.primary.GetReplicationTasks(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) CompleteReplicationTask(
+	ctx context.Context,
+	request *CompleteReplicationTaskRequest,
+) error {
+	err := p.primary.CompleteReplicationTask(ctx, request)
+	p.mirror(metrics.PersistenceCompleteReplicationTaskScope, err, func() error {
+		return p.secondary.CompleteReplicationTask(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) RangeCompleteReplicationTask(
+	ctx context.Context,
+	request *RangeCompleteReplicationTaskRequest,
+) (*RangeCompleteReplicationTaskResponse, error) {
+	resp, err := p.primary.RangeCompleteReplicationTask(ctx, request)
+	p.mirror(metrics.PersistenceRangeCompleteReplicationTaskScope, err, func() error {
+		_, err := p.secondary.RangeCompleteReplicationTask(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) PutReplicationTaskToDLQ(
+	ctx context.Context,
+	request *PutReplicationTaskToDLQRequest,
+) error {
+	err := p.primary.PutReplicationTaskToDLQ(ctx, request)
+	p.mirror(metrics.PersistencePutReplicationTaskToDLQScope, err, func() error {
+		return p.secondary.PutReplicationTaskToDLQ(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) GetReplicationTasksFromDLQ(
+	ctx context.Context,
+	request *GetReplicationTasksFromDLQRequest,
+) (*GetReplicationTasksFromDLQResponse, error) {
+	return p.primary.GetReplicationTasksFromDLQ(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) GetReplicationDLQSize(
+	ctx context.Context,
+	request *GetReplicationDLQSizeRequest,
+) (*GetReplicationDLQSizeResponse, error) {
+	return p.primary.GetReplicationDLQSize(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) DeleteReplicationTaskFromDLQ(
+	ctx context.Context,
+	request *DeleteReplicationTaskFromDLQRequest,
+) error {
+	err := p.primary.DeleteReplicationTaskFromDLQ(ctx, request)
+	p.mirror(metrics.PersistenceDeleteReplicationTaskFromDLQScope, err, func() error {
+		return p.secondary.DeleteReplicationTaskFromDLQ(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) RangeDeleteReplicationTaskFromDLQ(
+	ctx context.Context,
+	request *RangeDeleteReplicationTaskFromDLQRequest,
+) (*RangeDeleteReplicationTaskFromDLQResponse, error) {
+	resp, err := p.primary.RangeDeleteReplicationTaskFromDLQ(ctx, request)
+	p.mirror(metrics.PersistenceRangeDeleteReplicationTaskFromDLQScope, err, func() error {
+		_, err := p.secondary.RangeDeleteReplicationTaskFromDLQ(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) CreateFailoverMarkerTasks(
+	ctx context.Context,
+	request *CreateFailoverMarkersRequest,
+) error {
+	err := p.primary.CreateFailoverMarkerTasks(ctx, request)
+	p.mirror(metrics.PersistenceCreateFailoverMarkerTasksScope, err, func() error {
+		return p.secondary.CreateFailoverMarkerTasks(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) GetTimerIndexTasks(
+	ctx context.Context,
+	request *GetTimerIndexTasksRequest,
+) (*GetTimerIndexTasksResponse, error) {
+	return p.primary.GetTimerIndexTasks(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) CompleteTimerTask(
+	ctx context.Context,
+	request *CompleteTimerTaskRequest,
+) error {
+	err := p.primary.CompleteTimerTask(ctx, request)
+	p.mirror(metrics.PersistenceCompleteTimerTaskScope, err, func() error {
+		return p.secondary.CompleteTimerTask(ctx, request)
+	})
+	return err
+}
+
+func (p *workflowExecutionDualWriteClient) RangeCompleteTimerTask(
+	ctx context.Context,
+	request *RangeCompleteTimerTaskRequest,
+) (*RangeCompleteTimerTaskResponse, error) {
+	resp, err := p.primary.RangeCompleteTimerTask(ctx, request)
+	p.mirror(metrics.PersistenceRangeCompleteTimerTaskScope, err, func() error {
+		_, err := p.secondary.RangeCompleteTimerTask(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *workflowExecutionDualWriteClient) ListConcreteExecutions(
+	ctx context.Context,
+	request *ListConcreteExecutionsRequest,
+) (*ListConcreteExecutionsResponse, error) {
+	return p.primary.ListConcreteExecutions(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) ListCurrentExecutions(
+	ctx context.Context,
+	request *ListCurrentExecutionsRequest,
+) (*ListCurrentExecutionsResponse, error) {
+	return p.primary.ListCurrentExecutions(ctx, request)
+}
+
+func (p *workflowExecutionDualWriteClient) Close() {
+	p.primary.Close()
+	p.secondary.Close()
+}
+
+func (p *taskDualWriteClient) GetName() string {
+	return p.primary.GetName()
+}
+
+func (p *taskDualWriteClient) LeaseTaskList(
+	ctx context.Context,
+	request *LeaseTaskListRequest,
+) (*LeaseTaskListResponse, error) {
+	resp, err := p.primary.LeaseTaskList(ctx, request)
+	p.mirror(metrics.PersistenceLeaseTaskListScope, err, func() error {
+		_, err := p.secondary.LeaseTaskList(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *taskDualWriteClient) UpdateTaskList(
+	ctx context.Context,
+	request *UpdateTaskListRequest,
+) (*UpdateTaskListResponse, error) {
+	resp, err := p.primary.UpdateTaskList(ctx, request)
+	p.mirror(metrics.PersistenceUpdateTaskListScope, err, func() error {
+		_, err := p.secondary.UpdateTaskList(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *taskDualWriteClient) ListTaskList(
+	ctx context.Context,
+	request *ListTaskListRequest,
+) (*ListTaskListResponse, error) {
+	return p.primary.ListTaskList(ctx, request)
+}
+
+func (p *taskDualWriteClient) DeleteTaskList(
+	ctx context.Context,
+	request *DeleteTaskListRequest,
+) error {
+	err := p.primary.DeleteTaskList(ctx, request)
+	p.mirror(metrics.PersistenceDeleteTaskListScope, err, func() error {
+		return p.secondary.DeleteTaskList(ctx, request)
+	})
+	return err
+}
+
+func (p *taskDualWriteClient) CreateTasks(
+	ctx context.Context,
+	request *CreateTasksRequest,
+) (*CreateTasksResponse, error) {
+	resp, err := p.primary.CreateTasks(ctx, request)
+	p.mirror(metrics.PersistenceCreateTaskScope, err, func() error {
+		_, err := p.secondary.CreateTasks(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *taskDualWriteClient) GetTasks(
+	ctx context.Context,
+	request *GetTasksRequest,
+) (*GetTasksResponse, error) {
+	resp, err := p.primary.GetTasks(ctx, request)
+	p.shadow(metrics.PersistenceGetTasksScope, resp, err, func() (interface{}, error) {
+		return p.secondary.GetTasks(ctx, request)
+	})
+	return resp, err
+}
+
+func (p *taskDualWriteClient) CompleteTask(
+	ctx context.Context,
+	request *CompleteTaskRequest,
+) error {
+	err := p.primary.CompleteTask(ctx, request)
+	p.mirror(metrics.PersistenceCompleteTaskScope, err, func() error {
+		return p.secondary.CompleteTask(ctx, request)
+	})
+	return err
+}
+
+func (p *taskDualWriteClient) CompleteTasksLessThan(
+	ctx context.Context,
+	request *CompleteTasksLessThanRequest,
+) (*CompleteTasksLessThanResponse, error) {
+	resp, err := p.primary.CompleteTasksLessThan(ctx, request)
+	p.mirror(metrics.PersistenceCompleteTasksLessThanScope, err, func() error {
+		_, err := p.secondary.CompleteTasksLessThan(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *taskDualWriteClient) GetOrphanTasks(
+	ctx context.Context,
+	request *GetOrphanTasksRequest,
+) (*GetOrphanTasksResponse, error) {
+	return p.primary.GetOrphanTasks(ctx, request)
+}
+
+func (p *taskDualWriteClient) Close() {
+	p.primary.Close()
+	p.secondary.Close()
+}
+
+func (p *historyDualWriteClient) GetName() string {
+	return p.primary.GetName()
+}
+
+func (p *historyDualWriteClient) AppendHistoryNodes(
+	ctx context.Context,
+	request *AppendHistoryNodesRequest,
+) (*AppendHistoryNodesResponse, error) {
+	resp, err := p.primary.AppendHistoryNodes(ctx, request)
+	p.mirror(metrics.PersistenceAppendHistoryNodesScope, err, func() error {
+		_, err := p.secondary.AppendHistoryNodes(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *historyDualWriteClient) ReadHistoryBranch(
+	ctx context.Context,
+	request *ReadHistoryBranchRequest,
+) (*ReadHistoryBranchResponse, error) {
+	resp, err := p.primary.ReadHistoryBranch(ctx, request)
+	// page tokens are specific to each store, only the first page is shadowed
+	if err == nil && len(request.NextPageToken) == 0 {
+		p.shadow(metrics.PersistenceReadHistoryBranchScope, resp.HistoryEvents, err, func() (interface{}, error) {
+			secondaryResp, err := p.secondary.ReadHistoryBranch(ctx, request)
+			if err != nil {
+				return nil, err
+			}
+			return secondaryResp.HistoryEvents, nil
+		})
+	}
+	return resp, err
+}
+
+func (p *historyDualWriteClient) ReadHistoryBranchByBatch(
+	ctx context.Context,
+	request *ReadHistoryBranchRequest,
+) (*ReadHistoryBranchByBatchResponse, error) {
+	return p.primary.ReadHistoryBranchByBatch(ctx, request)
+}
+
+func (p *historyDualWriteClient) ReadRawHistoryBranch(
+	ctx context.Context,
+	request *ReadHistoryBranchRequest,
+) (*ReadRawHistoryBranchResponse, error) {
+	return p.primary.ReadRawHistoryBranch(ctx, request)
+}
+
+func (p *historyDualWriteClient) ForkHistoryBranch(
+	ctx context.Context,
+	request *ForkHistoryBranchRequest,
+) (*ForkHistoryBranchResponse, error) {
+	if request.NewBranchID == "" && p.dualWriteEnabled() {
+		// both stores must agree on the ID of the new branch
+		forkRequest := *request
+		forkRequest.NewBranchID = uuid.New()
+		request = &forkRequest
+	}
+	resp, err := p.primary.ForkHistoryBranch(ctx, request)
+	p.mirror(metrics.PersistenceForkHistoryBranchScope, err, func() error {
+		_, err := p.secondary.ForkHistoryBranch(ctx, request)
+		return err
+	})
+	return resp, err
+}
+
+func (p *historyDualWriteClient) DeleteHistoryBranch(
+	ctx context.Context,
+	request *DeleteHistoryBranchRequest,
+) error {
+	err := p.primary.DeleteHistoryBranch(ctx, request)
+	p.mirror(metrics.PersistenceDeleteHistoryBranchScope, err, func() error {
+		return p.secondary.DeleteHistoryBranch(ctx, request)
+	})
+	return err
+}
+
+func (p *historyDualWriteClient) GetHistoryTree(
+	ctx context.Context,
+	request *GetHistoryTreeRequest,
+) (*GetHistoryTreeResponse, error) {
+	return p.primary.GetHistoryTree(ctx, request)
+}
+
+func (p *historyDualWriteClient) GetAllHistoryTreeBranches(
+	ctx context.Context,
+	request *GetAllHistoryTreeBranchesRequest,
+) (*GetAllHistoryTreeBranchesResponse, error) {
+	return p.primary.GetAllHistoryTreeBranches(ctx, request)
+}
+
+func (p *historyDualWriteClient) Close() {
+	p.primary.Close()
+	p.secondary.Close()
+}
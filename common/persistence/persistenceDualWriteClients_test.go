@@ -0,0 +1,183 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package persistence
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/suite"
+	"github.com/uber-go/tally"
+
+	"github.com/uber/cadence/common/dynamicconfig"
+	"github.com/uber/cadence/common/log/loggerimpl"
+	"github.com/uber/cadence/common/metrics"
+)
+
+type (
+	persistenceDualWriteClientsSuite struct {
+		suite.Suite
+		controller *gomock.Controller
+
+		scope     tally.TestScope
+		primary   *MockShardManager
+		secondary *MockShardManager
+		client    ShardManager
+	}
+)
+
+func TestPersistenceDualWriteClientsSuite(t *testing.T) {
+	s := new(persistenceDualWriteClientsSuite)
+	suite.Run(t, s)
+}
+
+func (s *persistenceDualWriteClientsSuite) SetupTest() {
+	s.controller = gomock.NewController(s.T())
+	s.scope = tally.NewTestScope("test", nil)
+	s.primary = NewMockShardManager(s.controller)
+	s.secondary = NewMockShardManager(s.controller)
+	s.client = NewShardPersistenceDualWriteClient(
+		s.primary,
+		s.secondary,
+		dynamicconfig.GetBoolPropertyFn(true),
+		dynamicconfig.GetBoolPropertyFn(true),
+		metrics.NewClient(s.scope, metrics.History),
+		loggerimpl.NewNopLogger(),
+	)
+}
+
+func (s *persistenceDualWriteClientsSuite) TearDownTest() {
+	s.controller.Finish()
+}
+
+func (s *persistenceDualWriteClientsSuite) TestWrite_Mirrored() {
+	request := &UpdateShardRequest{PreviousRangeID: 1}
+	s.primary.EXPECT().UpdateShard(gomock.Any(), request).Return(nil).Times(1)
+	s.secondary.EXPECT().UpdateShard(gomock.Any(), request).Return(nil).Times(1)
+
+	s.NoError(s.client.UpdateShard(context.Background(), request))
+	s.Equal(int64(0), s.counter("persistence_dual_write_errors"))
+}
+
+func (s *persistenceDualWriteClientsSuite) TestWrite_PrimaryFailed() {
+	request := &UpdateShardRequest{PreviousRangeID: 1}
+	primaryErr := &ShardOwnershipLostError{ShardID: 1}
+	s.primary.EXPECT().UpdateShard(gomock.Any(), request).Return(primaryErr).Times(1)
+
+	s.Equal(primaryErr, s.client.UpdateShard(context.Background(), request))
+}
+
+func (s *persistenceDualWriteClientsSuite) TestWrite_SecondaryFailed() {
+	request := &UpdateShardRequest{PreviousRangeID: 1}
+	s.primary.EXPECT().UpdateShard(gomock.Any(), request).Return(nil).Times(1)
+	s.secondary.EXPECT().UpdateShard(gomock.Any(), request).Return(errors.New("some random error")).Times(1)
+
+	s.NoError(s.client.UpdateShard(context.Background(), request))
+	s.Equal(int64(1), s.counter("persistence_dual_write_errors"))
+}
+
+func (s *persistenceDualWriteClientsSuite) TestWrite_DualWriteDisabled() {
+	client := NewShardPersistenceDualWriteClient(
+		s.primary,
+		s.secondary,
+		dynamicconfig.GetBoolPropertyFn(false),
+		dynamicconfig.GetBoolPropertyFn(false),
+		metrics.NewClient(s.scope, metrics.History),
+		loggerimpl.NewNopLogger(),
+	)
+	request := &UpdateShardRequest{PreviousRangeID: 1}
+	s.primary.EXPECT().UpdateShard(gomock.Any(), request).Return(nil).Times(1)
+
+	s.NoError(client.UpdateShard(context.Background(), request))
+}
+
+func (s *persistenceDualWriteClientsSuite) TestShadowRead_Match() {
+	request := &GetShardRequest{ShardID: 1}
+	s.primary.EXPECT().GetShard(gomock.Any(), request).Return(&GetShardResponse{ShardInfo: &ShardInfo{ShardID: 1, RangeID: 2}}, nil).Times(1)
+	s.secondary.EXPECT().GetShard(gomock.Any(), request).Return(&GetShardResponse{ShardInfo: &ShardInfo{ShardID: 1, RangeID: 2}}, nil).Times(1)
+
+	resp, err := s.client.GetShard(context.Background(), request)
+	s.NoError(err)
+	s.Equal(int64(2), resp.ShardInfo.RangeID)
+	s.Equal(int64(0), s.counter("persistence_shadow_read_mismatches"))
+	s.Equal(int64(0), s.counter("persistence_shadow_read_errors"))
+}
+
+func (s *persistenceDualWriteClientsSuite) TestShadowRead_Mismatch() {
+	request := &GetShardRequest{ShardID: 1}
+	s.primary.EXPECT().GetShard(gomock.Any(), request).Return(&GetShardResponse{ShardInfo: &ShardInfo{ShardID: 1, RangeID: 2}}, nil).Times(1)
+	s.secondary.EXPECT().GetShard(gomock.Any(), request).Return(&GetShardResponse{ShardInfo: &ShardInfo{ShardID: 1, RangeID: 1}}, nil).Times(1)
+
+	resp, err := s.client.GetShard(context.Background(), request)
+	s.NoError(err)
+	s.Equal(int64(2), resp.ShardInfo.RangeID)
+	s.Equal(int64(1), s.counter("persistence_shadow_read_mismatches"))
+}
+
+func (s *persistenceDualWriteClientsSuite) TestShadowRead_SecondaryFailed() {
+	request := &GetShardRequest{ShardID: 1}
+	s.primary.EXPECT().GetShard(gomock.Any(), request).Return(&GetShardResponse{ShardInfo: &ShardInfo{ShardID: 1}}, nil).Times(1)
+	s.secondary.EXPECT().GetShard(gomock.Any(), request).Return(nil, errors.New("some random error")).Times(1)
+
+	_, err := s.client.GetShard(context.Background(), request)
+	s.NoError(err)
+	s.Equal(int64(1), s.counter("persistence_shadow_read_errors"))
+}
+
+func (s *persistenceDualWriteClientsSuite) TestForkHistoryBranch_SameBranchID() {
+	primary := NewMockHistoryManager(s.controller)
+	secondary := NewMockHistoryManager(s.controller)
+	client := NewHistoryPersistenceDualWriteClient(
+		primary,
+		secondary,
+		dynamicconfig.GetBoolPropertyFn(true),
+		dynamicconfig.GetBoolPropertyFn(false),
+		metrics.NewClient(s.scope, metrics.History),
+		loggerimpl.NewNopLogger(),
+	)
+
+	var branchIDs []string
+	recordBranchID := func(_ context.Context, request *ForkHistoryBranchRequest) (*ForkHistoryBranchResponse, error) {
+		branchIDs = append(branchIDs, request.NewBranchID)
+		return &ForkHistoryBranchResponse{}, nil
+	}
+	primary.EXPECT().ForkHistoryBranch(gomock.Any(), gomock.Any()).DoAndReturn(recordBranchID).Times(1)
+	secondary.EXPECT().ForkHistoryBranch(gomock.Any(), gomock.Any()).DoAndReturn(recordBranchID).Times(1)
+
+	_, err := client.ForkHistoryBranch(context.Background(), &ForkHistoryBranchRequest{ForkNodeID: 2})
+	s.NoError(err)
+	s.Len(branchIDs, 2)
+	s.NotEmpty(branchIDs[0])
+	s.Equal(branchIDs[0], branchIDs[1])
+}
+
+func (s *persistenceDualWriteClientsSuite) counter(name string) int64 {
+	var count int64
+	for key, counter := range s.scope.Snapshot().Counters() {
+		if strings.HasPrefix(key, "test."+name+"+") {
+			count += counter.Value()
+		}
+	}
+	return count
+}
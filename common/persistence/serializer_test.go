@@ -22,6 +22,7 @@ package persistence
 
 import (
 	"encoding/json"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -528,3 +529,52 @@ func (s *cadenceSerializerSuite) TestSerializer() {
 	succ := common.AwaitWaitGroup(&doneWG, 10*time.Second)
 	s.True(succ, "test timed out")
 }
+
+func (s *cadenceSerializerSuite) TestSerializer_Compression() {
+	serializer := NewPayloadSerializer()
+
+	event := &types.HistoryEvent{
+		EventID:   999,
+		Timestamp: common.Int64Ptr(time.Now().UnixNano()),
+		EventType: types.EventTypeActivityTaskCompleted.Ptr(),
+		ActivityTaskCompletedEventAttributes: &types.ActivityTaskCompletedEventAttributes{
+			Result:           []byte(strings.Repeat("result-1-event-1", 100)),
+			ScheduledEventID: 4,
+			StartedEventID:   5,
+			Identity:         "event-1",
+		},
+	}
+	events := []*types.HistoryEvent{event, event}
+
+	thriftBlob, err := serializer.SerializeBatchEvents(events, common.EncodingTypeThriftRW)
+	s.NoError(err)
+
+	for _, encoding := range []common.EncodingType{common.EncodingTypeThriftRWSnappy, common.EncodingTypeThriftRWZstd} {
+		blob, err := serializer.SerializeBatchEvents(events, encoding)
+		s.NoError(err)
+		s.Equal(encoding, blob.GetEncoding())
+		s.True(len(blob.Data) < len(thriftBlob.Data))
+
+		dEvents, err := serializer.DeserializeBatchEvents(blob)
+		s.NoError(err)
+		s.Equal(events, dEvents)
+
+		decompressed, err := DecompressDataBlob(blob)
+		s.NoError(err)
+		s.Equal(thriftBlob, decompressed)
+
+		resetPoints := &types.ResetPoints{Points: []*types.ResetPointInfo{{BinaryChecksum: "bad-binary-cs", RunID: "test-run-id"}}}
+		resetPointsBlob, err := serializer.SerializeResetPoints(resetPoints, encoding)
+		s.NoError(err)
+		dResetPoints, err := serializer.DeserializeResetPoints(resetPointsBlob)
+		s.NoError(err)
+		s.Equal(resetPoints, dResetPoints)
+	}
+
+	uncompressed, err := DecompressDataBlob(thriftBlob)
+	s.NoError(err)
+	s.Equal(thriftBlob, uncompressed)
+
+	_, err = serializer.DeserializeBatchEvents(NewDataBlob([]byte("not compressed"), common.EncodingTypeThriftRWZstd))
+	s.Error(err)
+}
@@ -121,6 +121,7 @@ func convertCommonErrors(
 		*persistence.WorkflowExecutionAlreadyStartedError,
 		*persistence.ShardOwnershipLostError,
 		*persistence.TimeoutError,
+		*types.BadRequestError,
 		*types.DomainAlreadyExistsError,
 		*types.EntityNotExistsError,
 		*types.ServiceBusyError,
@@ -0,0 +1,149 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/backoff"
+	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	expiredRowsSweepInterval  = 5 * time.Minute
+	expiredRowsSweepBatchSize = 1000
+	expiredRowsSweepTimeout   = 30 * time.Second
+)
+
+type (
+	// expiredRowsSweeper periodically deletes the tasks and task_lists rows whose
+	// ttl has elapsed, giving SQL stores the same retention Cassandra gets from native ttl.
+	// Rows stay in the tables until they are swept, readers must keep treating expired
+	// rows as gone.
+	expiredRowsSweeper struct {
+		status     int32
+		db         sqlplugin.DB
+		logger     log.Logger
+		shutdownCh chan struct{}
+		shutdownWG sync.WaitGroup
+	}
+)
+
+func newExpiredRowsSweeper(
+	db sqlplugin.DB,
+	logger log.Logger,
+) *expiredRowsSweeper {
+	return &expiredRowsSweeper{
+		status:     common.DaemonStatusInitialized,
+		db:         db,
+		logger:     logger,
+		shutdownCh: make(chan struct{}),
+	}
+}
+
+func (s *expiredRowsSweeper) Start() {
+	if !atomic.CompareAndSwapInt32(&s.status, common.DaemonStatusInitialized, common.DaemonStatusStarted) {
+		return
+	}
+	s.shutdownWG.Add(1)
+	go s.sweepLoop()
+}
+
+func (s *expiredRowsSweeper) Stop() {
+	if !atomic.CompareAndSwapInt32(&s.status, common.DaemonStatusStarted, common.DaemonStatusStopped) {
+		return
+	}
+	close(s.shutdownCh)
+	if success := common.AwaitWaitGroup(&s.shutdownWG, time.Minute); !success {
+		s.logger.Warn("Expired task rows sweeper timed out on shutdown.")
+	}
+}
+
+func (s *expiredRowsSweeper) sweepLoop() {
+	defer s.shutdownWG.Done()
+
+	// every host owning a task store runs a sweeper, jitter them so they don't all hit the database together
+	timer := time.NewTimer(backoff.JitDuration(expiredRowsSweepInterval, 0.5))
+	defer timer.Stop()
+	for {
+		select {
+		case <-s.shutdownCh:
+			return
+		case <-timer.C:
+			s.sweep()
+			timer.Reset(backoff.JitDuration(expiredRowsSweepInterval, 0.2))
+		}
+	}
+}
+
+func (s *expiredRowsSweeper) sweep() {
+	now := time.Now()
+	for dbShardID := 0; dbShardID < s.db.GetTotalNumDBShards(); dbShardID++ {
+		filter := &sqlplugin.ExpiredRowsFilter{
+			ShardID:       dbShardID,
+			ExpiredBefore: now,
+			Limit:         expiredRowsSweepBatchSize,
+		}
+		if !s.deleteInBatches("tasks", filter, s.db.DeleteExpiredTasks) ||
+			!s.deleteInBatches("task_lists", filter, s.db.DeleteExpiredTaskLists) {
+			return
+		}
+	}
+}
+
+// deleteInBatches deletes expired rows batch by batch until a batch comes back short,
+// it returns false when the sweeper is shutting down
+func (s *expiredRowsSweeper) deleteInBatches(
+	table string,
+	filter *sqlplugin.ExpiredRowsFilter,
+	deleteFn func(context.Context, *sqlplugin.ExpiredRowsFilter) (sql.Result, error),
+) bool {
+	for {
+		select {
+		case <-s.shutdownCh:
+			return false
+		default:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), expiredRowsSweepTimeout)
+		result, err := deleteFn(ctx, filter)
+		cancel()
+		if err != nil {
+			s.logger.Error("Failed to delete expired rows.", tag.Value(table), tag.ShardID(filter.ShardID), tag.Error(err))
+			return true
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			s.logger.Error("Failed to get rows affected of expired rows deletion.", tag.Value(table), tag.ShardID(filter.ShardID), tag.Error(err))
+			return true
+		}
+		if rowsAffected < int64(filter.Limit) {
+			return true
+		}
+	}
+}
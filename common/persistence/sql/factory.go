@@ -31,6 +31,7 @@ import (
 	"github.com/uber/cadence/common/log"
 	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+	"github.com/uber/cadence/common/service"
 )
 
 type (
@@ -117,9 +118,9 @@ func (f *Factory) NewExecutionStore(shardID int) (p.ExecutionStore, error) {
 }
 
 // NewVisibilityStore returns a visibility store
-// TODO sortByCloseTime will be removed and implemented for https://github.com/uber/cadence/issues/3621
-func (f *Factory) NewVisibilityStore(sortByCloseTime bool) (p.VisibilityStore, error) {
-	return NewSQLVisibilityStore(f.cfg, f.logger)
+// TODO sorting by close time will be implemented for https://github.com/uber/cadence/issues/3621
+func (f *Factory) NewVisibilityStore(cfg *service.Config) (p.VisibilityStore, error) {
+	return NewSQLVisibilityStore(f.cfg, cfg.ValidSearchAttributes, f.logger)
 }
 
 // NewQueue returns a new queue backed by sql
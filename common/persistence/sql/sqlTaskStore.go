@@ -39,6 +39,7 @@ import (
 type sqlTaskStore struct {
 	sqlStore
 	nShards int
+	sweeper *expiredRowsSweeper
 }
 
 var (
@@ -52,14 +53,26 @@ func newTaskPersistence(
 	log log.Logger,
 	parser serialization.Parser,
 ) (persistence.TaskStore, error) {
-	return &sqlTaskStore{
+	store := &sqlTaskStore{
 		sqlStore: sqlStore{
 			db:     db,
 			logger: log,
 			parser: parser,
 		},
 		nShards: nShards,
-	}, nil
+	}
+	if db.SupportsTTL() {
+		store.sweeper = newExpiredRowsSweeper(db, log)
+		store.sweeper.Start()
+	}
+	return store, nil
+}
+
+func (m *sqlTaskStore) Close() {
+	if m.sweeper != nil {
+		m.sweeper.Stop()
+	}
+	m.sqlStore.Close()
 }
 
 func (m *sqlTaskStore) LeaseTaskList(
@@ -21,6 +21,7 @@
 package sql
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
@@ -30,7 +31,10 @@ import (
 	workflow "github.com/uber/cadence/.gen/go/shared"
 	"github.com/uber/cadence/common"
 	"github.com/uber/cadence/common/config"
+	"github.com/uber/cadence/common/definition"
+	"github.com/uber/cadence/common/dynamicconfig"
 	"github.com/uber/cadence/common/log"
+	"github.com/uber/cadence/common/log/tag"
 	p "github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 	"github.com/uber/cadence/common/types"
@@ -40,16 +44,25 @@ import (
 type (
 	sqlVisibilityStore struct {
 		sqlStore
+		validSearchAttributes dynamicconfig.MapPropertyFn
 	}
 
 	visibilityPageToken struct {
 		Time  time.Time
 		RunID string
+		// Offset is only used by queries sorted by a custom order
+		Offset int
 	}
 )
 
-// NewSQLVisibilityStore creates an instance of ExecutionStore
-func NewSQLVisibilityStore(cfg config.SQL, logger log.Logger) (p.VisibilityStore, error) {
+const defaultVisibilityQueryPageSize = 1000
+
+// NewSQLVisibilityStore creates an instance of VisibilityStore
+func NewSQLVisibilityStore(
+	cfg config.SQL,
+	validSearchAttributes dynamicconfig.MapPropertyFn,
+	logger log.Logger,
+) (p.VisibilityStore, error) {
 	db, err := NewSQLDB(&cfg)
 	if err != nil {
 		return nil, err
@@ -59,6 +72,7 @@ func NewSQLVisibilityStore(cfg config.SQL, logger log.Logger) (p.VisibilityStore
 			db:     db,
 			logger: logger,
 		},
+		validSearchAttributes: validSearchAttributes,
 	}, nil
 }
 
@@ -66,7 +80,11 @@ func (s *sqlVisibilityStore) RecordWorkflowExecutionStarted(
 	ctx context.Context,
 	request *p.InternalRecordWorkflowExecutionStartedRequest,
 ) error {
-	_, err := s.db.InsertIntoVisibility(ctx, &sqlplugin.VisibilityRow{
+	searchAttributes, err := s.serializeSearchAttributes(request.SearchAttributes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.InsertIntoVisibility(ctx, &sqlplugin.VisibilityRow{
 		DomainID:         request.DomainUUID,
 		WorkflowID:       request.WorkflowID,
 		RunID:            request.RunID,
@@ -75,8 +93,10 @@ func (s *sqlVisibilityStore) RecordWorkflowExecutionStarted(
 		WorkflowTypeName: request.WorkflowTypeName,
 		Memo:             request.Memo.Data,
 		Encoding:         string(request.Memo.GetEncoding()),
+		TaskList:         request.TaskList,
 		IsCron:           request.IsCron,
 		NumClusters:      request.NumClusters,
+		SearchAttributes: searchAttributes,
 	})
 
 	if err != nil {
@@ -89,6 +109,10 @@ func (s *sqlVisibilityStore) RecordWorkflowExecutionClosed(
 	ctx context.Context,
 	request *p.InternalRecordWorkflowExecutionClosedRequest,
 ) error {
+	searchAttributes, err := s.serializeSearchAttributes(request.SearchAttributes)
+	if err != nil {
+		return err
+	}
 	closeTime := request.CloseTimestamp
 	result, err := s.db.ReplaceIntoVisibility(ctx, &sqlplugin.VisibilityRow{
 		DomainID:         request.DomainUUID,
@@ -102,8 +126,10 @@ func (s *sqlVisibilityStore) RecordWorkflowExecutionClosed(
 		HistoryLength:    &request.HistoryLength,
 		Memo:             request.Memo.Data,
 		Encoding:         string(request.Memo.GetEncoding()),
+		TaskList:         request.TaskList,
 		IsCron:           request.IsCron,
 		NumClusters:      request.NumClusters,
+		SearchAttributes: searchAttributes,
 	})
 	if err != nil {
 		return convertCommonErrors(s.db, "RecordWorkflowExecutionClosed", "", err)
@@ -126,10 +152,28 @@ func (s *sqlVisibilityStore) UpsertWorkflowExecution(
 	ctx context.Context,
 	request *p.InternalUpsertWorkflowExecutionRequest,
 ) error {
-	if p.IsNopUpsertWorkflowRequest(request) {
-		return nil
+	searchAttributes, err := s.serializeSearchAttributes(request.SearchAttributes)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.UpsertIntoVisibility(ctx, &sqlplugin.VisibilityRow{
+		DomainID:         request.DomainUUID,
+		WorkflowID:       request.WorkflowID,
+		RunID:            request.RunID,
+		StartTime:        request.StartTimestamp,
+		ExecutionTime:    request.ExecutionTimestamp,
+		WorkflowTypeName: request.WorkflowTypeName,
+		Memo:             request.Memo.Data,
+		Encoding:         string(request.Memo.GetEncoding()),
+		TaskList:         request.TaskList,
+		IsCron:           request.IsCron,
+		NumClusters:      request.NumClusters,
+		SearchAttributes: searchAttributes,
+	})
+	if err != nil {
+		return convertCommonErrors(s.db, "UpsertWorkflowExecution", "", err)
 	}
-	return p.NewOperationNotSupportErrorForVis()
+	return nil
 }
 
 func (s *sqlVisibilityStore) ListOpenWorkflowExecutions(
@@ -296,21 +340,83 @@ func (s *sqlVisibilityStore) ListWorkflowExecutions(
 	ctx context.Context,
 	request *p.ListWorkflowExecutionsByQueryRequest,
 ) (*p.InternalListWorkflowExecutionsResponse, error) {
-	return nil, p.NewOperationNotSupportErrorForVis()
+	return s.listWorkflowExecutionsByQuery(ctx, "ListWorkflowExecutions", request, false)
 }
 
 func (s *sqlVisibilityStore) ScanWorkflowExecutions(
 	ctx context.Context,
 	request *p.ListWorkflowExecutionsByQueryRequest,
 ) (*p.InternalListWorkflowExecutionsResponse, error) {
-	return nil, p.NewOperationNotSupportErrorForVis()
+	// scan doesn't guarantee any order, so always use the default one which allows seeking to the next page
+	return s.listWorkflowExecutionsByQuery(ctx, "ScanWorkflowExecutions", request, true)
 }
 
 func (s *sqlVisibilityStore) CountWorkflowExecutions(
 	ctx context.Context,
 	request *p.CountWorkflowExecutionsRequest,
 ) (*p.CountWorkflowExecutionsResponse, error) {
-	return nil, p.NewOperationNotSupportErrorForVis()
+	count, err := s.db.CountFromVisibilityByQuery(ctx, &sqlplugin.VisibilityQueryFilter{
+		DomainID:             request.DomainUUID,
+		Query:                request.Query,
+		SearchAttributeTypes: s.getSearchAttributeTypes(),
+	})
+	if err != nil {
+		return nil, convertCommonErrors(s.db, "CountWorkflowExecutions", "", err)
+	}
+	return &p.CountWorkflowExecutionsResponse{Count: count}, nil
+}
+
+func (s *sqlVisibilityStore) listWorkflowExecutionsByQuery(
+	ctx context.Context,
+	opName string,
+	request *p.ListWorkflowExecutionsByQueryRequest,
+	ignoreOrderBy bool,
+) (*p.InternalListWorkflowExecutionsResponse, error) {
+	pageSize := request.PageSize
+	if pageSize == 0 {
+		pageSize = defaultVisibilityQueryPageSize
+	}
+	filter := &sqlplugin.VisibilityQueryFilter{
+		DomainID:             request.DomainUUID,
+		Query:                request.Query,
+		SearchAttributeTypes: s.getSearchAttributeTypes(),
+		IgnoreOrderBy:        ignoreOrderBy,
+		PageSize:             pageSize,
+	}
+	if len(request.NextPageToken) > 0 {
+		token, err := s.deserializePageToken(request.NextPageToken)
+		if err != nil {
+			return nil, err
+		}
+		filter.LastStartTime = &token.Time
+		filter.LastRunID = &token.RunID
+		filter.Offset = token.Offset
+	}
+	rows, err := s.db.SelectFromVisibilityByQuery(ctx, filter)
+	if err != nil {
+		return nil, convertCommonErrors(s.db, opName, "", err)
+	}
+
+	infos := make([]*p.InternalVisibilityWorkflowExecutionInfo, len(rows))
+	for i := range rows {
+		infos[i] = s.rowToInfo(&rows[i])
+	}
+	var nextPageToken []byte
+	if len(rows) == pageSize {
+		lastRow := rows[len(rows)-1]
+		nextPageToken, err = s.serializePageToken(&visibilityPageToken{
+			Time:   lastRow.StartTime,
+			RunID:  lastRow.RunID,
+			Offset: filter.Offset + len(rows),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &p.InternalListWorkflowExecutionsResponse{
+		Executions:    infos,
+		NextPageToken: nextPageToken,
+	}, nil
 }
 
 func (s *sqlVisibilityStore) rowToInfo(row *sqlplugin.VisibilityRow) *p.InternalVisibilityWorkflowExecutionInfo {
@@ -318,14 +424,16 @@ func (s *sqlVisibilityStore) rowToInfo(row *sqlplugin.VisibilityRow) *p.Internal
 		row.ExecutionTime = row.StartTime
 	}
 	info := &p.InternalVisibilityWorkflowExecutionInfo{
-		WorkflowID:    row.WorkflowID,
-		RunID:         row.RunID,
-		TypeName:      row.WorkflowTypeName,
-		StartTime:     row.StartTime,
-		ExecutionTime: row.ExecutionTime,
-		IsCron:        row.IsCron,
-		NumClusters:   row.NumClusters,
-		Memo:          p.NewDataBlob(row.Memo, common.EncodingType(row.Encoding)),
+		WorkflowID:       row.WorkflowID,
+		RunID:            row.RunID,
+		TypeName:         row.WorkflowTypeName,
+		StartTime:        row.StartTime,
+		ExecutionTime:    row.ExecutionTime,
+		TaskList:         row.TaskList,
+		IsCron:           row.IsCron,
+		NumClusters:      row.NumClusters,
+		Memo:             p.NewDataBlob(row.Memo, common.EncodingType(row.Encoding)),
+		SearchAttributes: s.deserializeSearchAttributes(row.SearchAttributes),
 	}
 	if row.CloseStatus != nil {
 		status := workflow.WorkflowExecutionCloseStatus(*row.CloseStatus)
@@ -387,3 +495,104 @@ func (s *sqlVisibilityStore) serializePageToken(token *visibilityPageToken) ([]b
 	data, err := json.Marshal(token)
 	return data, err
 }
+
+func (s *sqlVisibilityStore) getSearchAttributeTypes() map[string]types.IndexedValueType {
+	validAttributes := definition.GetDefaultIndexedKeys()
+	if s.validSearchAttributes != nil {
+		validAttributes = s.validSearchAttributes()
+	}
+	attributeTypes := make(map[string]types.IndexedValueType, len(validAttributes))
+	for key, valueType := range validAttributes {
+		attributeTypes[key] = thrift.ToIndexedValueType(common.ConvertIndexedValueTypeToThriftType(valueType, s.logger))
+	}
+	return attributeTypes
+}
+
+// serializeSearchAttributes merges the json encoded search attributes into one document. Datetime attributes are
+// stored as unix nanos so that they can be compared as numbers regardless of their original format
+func (s *sqlVisibilityStore) serializeSearchAttributes(attributes map[string][]byte) ([]byte, error) {
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	attributeTypes := s.getSearchAttributeTypes()
+	values := make(map[string]interface{}, len(attributes))
+	for key, data := range attributes {
+		if attributeTypes[key] != types.IndexedValueTypeDatetime {
+			values[key] = json.RawMessage(data)
+			continue
+		}
+		value, err := toUnixNano(data)
+		if err != nil {
+			return nil, &types.BadRequestError{
+				Message: fmt.Sprintf("invalid value of datetime search attribute %v: %v", key, err),
+			}
+		}
+		values[key] = value
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return nil, &types.BadRequestError{
+			Message: fmt.Sprintf("invalid search attributes: %v", err),
+		}
+	}
+	return data, nil
+}
+
+func (s *sqlVisibilityStore) deserializeSearchAttributes(data []byte) map[string]interface{} {
+	if len(data) == 0 {
+		return nil
+	}
+	var values map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		s.logger.Error("failed to deserialize search attributes", tag.Error(err))
+		return nil
+	}
+	attributeTypes := s.getSearchAttributeTypes()
+	for key, value := range values {
+		if attributeTypes[key] == types.IndexedValueTypeDatetime {
+			values[key] = fromUnixNano(value)
+		}
+	}
+	return values
+}
+
+func toUnixNano(data []byte) (interface{}, error) {
+	var timestamp int64
+	if err := json.Unmarshal(data, &timestamp); err == nil {
+		return timestamp, nil
+	}
+	value, err := common.DeserializeSearchAttributeValue(data, workflow.IndexedValueTypeDatetime)
+	if err != nil {
+		return nil, err
+	}
+	switch value := value.(type) {
+	case time.Time:
+		return value.UnixNano(), nil
+	case []time.Time:
+		timestamps := make([]int64, len(value))
+		for i, t := range value {
+			timestamps[i] = t.UnixNano()
+		}
+		return timestamps, nil
+	default:
+		return nil, fmt.Errorf("unexpected datetime value %v", value)
+	}
+}
+
+func fromUnixNano(value interface{}) interface{} {
+	switch value := value.(type) {
+	case json.Number:
+		if timestamp, err := value.Int64(); err == nil {
+			return time.Unix(0, timestamp).UTC()
+		}
+	case []interface{}:
+		times := make([]interface{}, len(value))
+		for i, v := range value {
+			times[i] = fromUnixNano(v)
+		}
+		return times
+	}
+	return value
+}
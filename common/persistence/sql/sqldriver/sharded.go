@@ -24,9 +24,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"reflect"
 
 	"github.com/jmoiron/sqlx"
 	"go.uber.org/multierr"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 )
@@ -100,9 +102,15 @@ func (s *sharded) GetContext(ctx context.Context, dbShardID int, dest interface{
 }
 
 func (s *sharded) SelectContext(ctx context.Context, dbShardID int, dest interface{}, query string, args ...interface{}) error {
-	if dbShardID == sqlplugin.DbShardUndefined || dbShardID == sqlplugin.DbAllShards {
+	if dbShardID == sqlplugin.DbShardUndefined {
 		return fmt.Errorf("invalid dbShardID %v shouldn't be used to SelectContext, there must be a bug", dbShardID)
 	}
+	if dbShardID == sqlplugin.DbAllShards {
+		if s.useTx {
+			return fmt.Errorf("dbShardID %v shouldn't be used in a transaction, there must be a bug", dbShardID)
+		}
+		return s.selectFromAllShards(ctx, dest, query, args...)
+	}
 	if s.useTx {
 		if s.currTxShardID != dbShardID {
 			return getUnmatchedTxnError(dbShardID, s.currTxShardID)
@@ -113,6 +121,44 @@ func (s *sharded) SelectContext(ctx context.Context, dbShardID int, dest interfa
 
 }
 
+// selectFromAllShards executes the query in all the shards concurrently, and appends the rows to dest in the order
+// of the shards. dest must be a pointer to a slice.
+// Each shard applies the ORDER BY and LIMIT of the query separately, so the caller must re-sort and re-limit the merged
+// rows. A paginated query can be answered the same way by querying the rows after the last key of the previous page
+// in every shard, and taking the first page of the merged rows.
+func (s *sharded) selectFromAllShards(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	destValue := reflect.ValueOf(dest)
+	if destValue.Kind() != reflect.Ptr || destValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("dest must be a pointer to a slice to query all dbShards, got %T", dest)
+	}
+	sliceType := destValue.Elem().Type()
+
+	results := make([]reflect.Value, len(s.dbs))
+	g, ctx := errgroup.WithContext(ctx)
+	for i := range s.dbs {
+		dbShardID := i
+		g.Go(func() error {
+			shardDest := reflect.New(sliceType)
+			// the error isn't wrapped so that the plugin can still check its type
+			if err := s.dbs[dbShardID].SelectContext(ctx, shardDest.Interface(), query, args...); err != nil {
+				return err
+			}
+			results[dbShardID] = shardDest.Elem()
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	merged := destValue.Elem()
+	for _, rows := range results {
+		merged = reflect.AppendSlice(merged, rows)
+	}
+	destValue.Elem().Set(merged)
+	return nil
+}
+
 // below are non-transactional methods only
 
 func (s *sharded) ExecDDL(ctx context.Context, dbShardID int, query string, args ...interface{}) (sql.Result, error) {
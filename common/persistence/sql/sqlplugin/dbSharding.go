@@ -32,7 +32,9 @@ const (
 	DbDefaultShard = 0
 	// this is should never being used in sharded SQL driver. It is used in admin/schema operation in singleton driver, which ignores all the shardID parameter
 	DbShardUndefined = -1
-	// this means the query needs to execute in all dbShards in sharded SQL driver (currently not supported)
+	// this means the query needs to execute in all dbShards in sharded SQL driver.
+	// It's only supported by SelectContext outside of a transaction, the rows from all dbShards are merged into the result,
+	// so the caller needs to re-apply the ORDER BY and LIMIT of the query to the merged rows.
 	DbAllShards = -2
 )
 
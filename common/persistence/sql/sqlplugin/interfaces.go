@@ -29,6 +29,7 @@ import (
 	"github.com/uber/cadence/common/config"
 	"github.com/uber/cadence/common/persistence"
 	"github.com/uber/cadence/common/persistence/serialization"
+	"github.com/uber/cadence/common/types"
 )
 
 var (
@@ -232,6 +233,14 @@ type (
 		PageSize             *int
 	}
 
+	// ExpiredRowsFilter contains the parameters controlling deletion of
+	// rows whose TTL has elapsed from the tasks and task_lists tables
+	ExpiredRowsFilter struct {
+		ShardID       int // this is DBShardID, not historyShardID
+		ExpiredBefore time.Time
+		Limit         int
+	}
+
 	// OrphanTasksFilter contains the parameters controlling orphan deletion
 	OrphanTasksFilter struct {
 		Limit *int
@@ -536,8 +545,10 @@ type (
 		HistoryLength    *int64
 		Memo             []byte
 		Encoding         string
+		TaskList         string
 		IsCron           bool
 		NumClusters      int16
+		SearchAttributes []byte
 	}
 
 	// VisibilityFilter contains the column names within executions_visibility table that
@@ -554,6 +565,22 @@ type (
 		PageSize         *int
 	}
 
+	// VisibilityQueryFilter contains the parameters of a query-based visibility request. Query uses the
+	// same SQL-like grammar as advanced visibility on Elasticsearch and is translated into a WHERE clause
+	VisibilityQueryFilter struct {
+		DomainID             string
+		Query                string
+		SearchAttributeTypes map[string]types.IndexedValueType
+		// IgnoreOrderBy drops the ORDER BY clause of Query and sorts by the default order instead
+		IgnoreOrderBy bool
+		// LastStartTime and LastRunID continue a page sorted by the default order
+		LastStartTime *time.Time
+		LastRunID     *string
+		// Offset continues a page sorted by the ORDER BY clause of Query
+		Offset   int
+		PageSize int
+	}
+
 	// QueueRow represents a row in queue table
 	QueueRow struct {
 		QueueType      persistence.QueueType
@@ -602,6 +629,9 @@ type (
 		//    - this will delete up to limit number of tasks less than or equal to the given task id
 		DeleteFromTasks(ctx context.Context, filter *TasksFilter) (sql.Result, error)
 		GetOrphanTasks(ctx context.Context, filter *OrphanTasksFilter) ([]TaskKeyRow, error)
+		// DeleteExpiredTasks deletes up to limit rows from tasks table whose TTL has elapsed
+		// Required filter params - {shardID, expiredBefore, limit}
+		DeleteExpiredTasks(ctx context.Context, filter *ExpiredRowsFilter) (sql.Result, error)
 
 		InsertIntoTaskLists(ctx context.Context, row *TaskListsRow) (sql.Result, error)
 		InsertIntoTaskListsWithTTL(ctx context.Context, row *TaskListsRowWithTTL) (sql.Result, error)
@@ -614,6 +644,9 @@ type (
 		SelectFromTaskLists(ctx context.Context, filter *TaskListsFilter) ([]TaskListsRow, error)
 		DeleteFromTaskLists(ctx context.Context, filter *TaskListsFilter) (sql.Result, error)
 		LockTaskLists(ctx context.Context, filter *TaskListsFilter) (int64, error)
+		// DeleteExpiredTaskLists deletes up to limit rows from task_lists table whose TTL has elapsed
+		// Required filter params - {shardID, expiredBefore, limit}
+		DeleteExpiredTaskLists(ctx context.Context, filter *ExpiredRowsFilter) (sql.Result, error)
 
 		// eventsV2
 		InsertIntoHistoryNode(ctx context.Context, row *HistoryNodeRow) (sql.Result, error)
@@ -785,6 +818,14 @@ type (
 		//     - workflowID, workflowTypeName, closeStatus (along with closed=true)
 		SelectFromVisibility(ctx context.Context, filter *VisibilityFilter) ([]VisibilityRow, error)
 		DeleteFromVisibility(ctx context.Context, filter *VisibilityFilter) (sql.Result, error)
+		// UpsertIntoVisibility inserts a row into visibility table, or updates the search attributes, memo
+		// and other fields of an open workflow if the row already exists. Close fields are never changed
+		UpsertIntoVisibility(ctx context.Context, row *VisibilityRow) (sql.Result, error)
+		// SelectFromVisibilityByQuery returns one page of rows matching a query-based filter
+		// Required filter params - {domainID, searchAttributeTypes, pageSize}
+		SelectFromVisibilityByQuery(ctx context.Context, filter *VisibilityQueryFilter) ([]VisibilityRow, error)
+		// CountFromVisibilityByQuery returns the number of rows matching a query-based filter
+		CountFromVisibilityByQuery(ctx context.Context, filter *VisibilityQueryFilter) (int64, error)
 
 		InsertIntoQueue(ctx context.Context, row *QueueRow) (sql.Result, error)
 		GetLastEnqueuedMessageIDForUpdate(ctx context.Context, queueType persistence.QueueType) (int64, error)
@@ -50,6 +50,9 @@ var _ sqlplugin.AdminDB = (*db)(nil)
 var _ sqlplugin.DB = (*db)(nil)
 var _ sqlplugin.Tx = (*db)(nil)
 
+// maxAllowedTTL caps the ttl of tasks and task lists rows, the same cap Cassandra puts on its ttl
+const maxAllowedTTL = time.Hour * 24 * 365 * 20
+
 func (mdb *db) IsDupEntryError(err error) bool {
 	sqlErr, ok := err.(*mysql.MySQLError)
 	// ErrDupEntry MySQL Error 1062 indicates a duplicate primary key i.e. the row already exists,
@@ -146,10 +149,11 @@ func (mdb *db) PluginName() string {
 
 // SupportsTTL returns weather MySQL supports TTL
 func (mdb *db) SupportsTTL() bool {
-	return false
+	return true
 }
 
 // MaxAllowedTTL returns the max allowed ttl MySQL supports
 func (mdb *db) MaxAllowedTTL() (*time.Duration, error) {
-	return nil, sqlplugin.ErrTTLNotSupported
+	ttl := maxAllowedTTL
+	return &ttl, nil
 }
@@ -48,6 +48,9 @@ func (pdb *db) GetTotalNumDBShards() int {
 var _ sqlplugin.DB = (*db)(nil)
 var _ sqlplugin.Tx = (*db)(nil)
 
+// maxAllowedTTL caps the ttl of tasks and task lists rows, the same cap Cassandra puts on its ttl
+const maxAllowedTTL = time.Hour * 24 * 365 * 20
+
 // ErrDupEntry indicates a duplicate primary key i.e. the row already exists,
 // check http://www.postgresql.org/docs/9.3/static/errcodes-appendix.html
 const ErrDupEntry = "23505"
@@ -134,10 +137,11 @@ func (pdb *db) PluginName() string {
 
 // SupportsTTL returns weather Postgres supports TTL
 func (pdb *db) SupportsTTL() bool {
-	return false
+	return true
 }
 
 // MaxAllowedTTL returns the max allowed ttl Postgres supports
 func (pdb *db) MaxAllowedTTL() (*time.Duration, error) {
-	return nil, sqlplugin.ErrTTLNotSupported
+	ttl := maxAllowedTTL
+	return &ttl, nil
 }
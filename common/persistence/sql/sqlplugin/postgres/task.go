@@ -24,10 +24,25 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"time"
 
 	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
 )
 
+type (
+	// tasksRowWithExpiry is the named query argument of tasks rows written with a ttl
+	tasksRowWithExpiry struct {
+		sqlplugin.TasksRow
+		ExpiryTs *time.Time
+	}
+
+	// taskListsRowWithExpiry is the named query argument of task_lists rows written with a ttl
+	taskListsRowWithExpiry struct {
+		sqlplugin.TaskListsRow
+		ExpiryTs time.Time
+	}
+)
+
 const (
 	taskListCreatePart = `INTO task_lists(shard_id, domain_id, name, task_type, range_id, data, data_encoding) ` +
 		`VALUES (:shard_id, :domain_id, :name, :task_type, :range_id, :data, :data_encoding)`
@@ -35,6 +50,9 @@ const (
 	// (default range ID: initialRangeID == 1)
 	createTaskListQry = `INSERT ` + taskListCreatePart
 
+	createTaskListWithTTLQry = `INSERT INTO task_lists(shard_id, domain_id, name, task_type, range_id, data, data_encoding, expiry_ts) ` +
+		`VALUES (:shard_id, :domain_id, :name, :task_type, :range_id, :data, :data_encoding, :expiry_ts)`
+
 	updateTaskListQry = `UPDATE task_lists SET
 range_id = :range_id,
 data = :data,
@@ -44,6 +62,18 @@ shard_id = :shard_id AND
 domain_id = :domain_id AND
 name = :name AND
 task_type = :task_type
+`
+
+	updateTaskListWithTTLQry = `UPDATE task_lists SET
+range_id = :range_id,
+data = :data,
+data_encoding = :data_encoding,
+expiry_ts = :expiry_ts
+WHERE
+shard_id = :shard_id AND
+domain_id = :domain_id AND
+name = :name AND
+task_type = :task_type
 `
 
 	// This query uses pagination that is best understood by analogy to simple numbers.
@@ -86,6 +116,10 @@ task_type = :task_type
 		`tasks(domain_id, task_list_name, task_type, task_id, data, data_encoding) ` +
 		`VALUES(:domain_id, :task_list_name, :task_type, :task_id, :data, :data_encoding)`
 
+	createTaskWithTTLQry = `INSERT INTO ` +
+		`tasks(domain_id, task_list_name, task_type, task_id, data, data_encoding, expiry_ts) ` +
+		`VALUES(:domain_id, :task_list_name, :task_type, :task_id, :data, :data_encoding, :expiry_ts)`
+
 	deleteTaskQry = `DELETE FROM tasks ` +
 		`WHERE domain_id = $1 AND task_list_name = $2 AND task_type = $3 AND task_id = $4`
 
@@ -99,6 +133,14 @@ task_type = :task_type
 		`	SELECT domain_id, name, task_type FROM task_lists AS tl ` +
 		`	WHERE t.domain_id=tl.domain_id and t.task_list_name=tl.name and t.task_type=tl.task_type ` +
 		`) LIMIT $1;`
+
+	deleteExpiredTasksQry = `DELETE FROM tasks ` +
+		`WHERE (domain_id, task_list_name, task_type, task_id) IN (SELECT domain_id, task_list_name, task_type, task_id FROM ` +
+		`tasks WHERE expiry_ts < $1 LIMIT $2)`
+
+	deleteExpiredTaskListsQry = `DELETE FROM task_lists ` +
+		`WHERE shard_id = $1 AND (domain_id, name, task_type) IN (SELECT domain_id, name, task_type FROM ` +
+		`task_lists WHERE shard_id = $1 AND expiry_ts < $2 LIMIT $3)`
 )
 
 // InsertIntoTasks inserts one or more rows into tasks table
@@ -148,6 +190,10 @@ func (pdb *db) GetOrphanTasks(ctx context.Context, filter *sqlplugin.OrphanTasks
 	if err != nil {
 		return nil, err
 	}
+	// the limit is applied in every dbShard
+	if len(rows) > *filter.Limit {
+		rows = rows[:*filter.Limit]
+	}
 	return rows, nil
 }
 
@@ -209,17 +255,46 @@ func (pdb *db) LockTaskLists(ctx context.Context, filter *sqlplugin.TaskListsFil
 	return rangeID, err
 }
 
-// InsertIntoTasksWithTTL is not supported in Postgres
-func (pdb *db) InsertIntoTasksWithTTL(_ context.Context, _ []sqlplugin.TasksRowWithTTL) (sql.Result, error) {
-	return nil, sqlplugin.ErrTTLNotSupported
+// InsertIntoTasksWithTTL inserts one or more rows into tasks table, rows without a ttl never expire
+func (pdb *db) InsertIntoTasksWithTTL(ctx context.Context, rows []sqlplugin.TasksRowWithTTL) (sql.Result, error) {
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	expiryRows := make([]tasksRowWithExpiry, len(rows))
+	for i, row := range rows {
+		expiryRows[i].TasksRow = row.TasksRow
+		if row.TTL != nil {
+			expiryTs := pdb.converter.ToPostgresDateTime(now.Add(*row.TTL))
+			expiryRows[i].ExpiryTs = &expiryTs
+		}
+	}
+	return pdb.driver.NamedExecContext(ctx, rows[0].TasksRow.ShardID, createTaskWithTTLQry, expiryRows)
+}
+
+// InsertIntoTaskListsWithTTL inserts a row into task_lists table that expires after the given ttl
+func (pdb *db) InsertIntoTaskListsWithTTL(ctx context.Context, row *sqlplugin.TaskListsRowWithTTL) (sql.Result, error) {
+	return pdb.driver.NamedExecContext(ctx, row.TaskListsRow.ShardID, createTaskListWithTTLQry, pdb.taskListsRowWithExpiry(row))
+}
+
+// UpdateTaskListsWithTTL updates a row in task_lists table and pushes its expiry out by the given ttl
+func (pdb *db) UpdateTaskListsWithTTL(ctx context.Context, row *sqlplugin.TaskListsRowWithTTL) (sql.Result, error) {
+	return pdb.driver.NamedExecContext(ctx, row.TaskListsRow.ShardID, updateTaskListWithTTLQry, pdb.taskListsRowWithExpiry(row))
+}
+
+func (pdb *db) taskListsRowWithExpiry(row *sqlplugin.TaskListsRowWithTTL) *taskListsRowWithExpiry {
+	return &taskListsRowWithExpiry{
+		TaskListsRow: row.TaskListsRow,
+		ExpiryTs:     pdb.converter.ToPostgresDateTime(time.Now().Add(row.TTL)),
+	}
 }
 
-// InsertIntoTaskListsWithTTL is not supported in Postgres
-func (pdb *db) InsertIntoTaskListsWithTTL(_ context.Context, _ *sqlplugin.TaskListsRowWithTTL) (sql.Result, error) {
-	return nil, sqlplugin.ErrTTLNotSupported
+// DeleteExpiredTasks deletes up to limit rows from tasks table whose ttl has elapsed
+func (pdb *db) DeleteExpiredTasks(ctx context.Context, filter *sqlplugin.ExpiredRowsFilter) (sql.Result, error) {
+	return pdb.driver.ExecContext(ctx, filter.ShardID, deleteExpiredTasksQry, pdb.converter.ToPostgresDateTime(filter.ExpiredBefore), filter.Limit)
 }
 
-// UpdateTaskListsWithTTL is not supported in Postgres
-func (pdb *db) UpdateTaskListsWithTTL(_ context.Context, _ *sqlplugin.TaskListsRowWithTTL) (sql.Result, error) {
-	return nil, sqlplugin.ErrTTLNotSupported
+// DeleteExpiredTaskLists deletes up to limit rows from task_lists table whose ttl has elapsed
+func (pdb *db) DeleteExpiredTaskLists(ctx context.Context, filter *sqlplugin.ExpiredRowsFilter) (sql.Result, error) {
+	return pdb.driver.ExecContext(ctx, filter.ShardID, deleteExpiredTaskListsQry, filter.ShardID, pdb.converter.ToPostgresDateTime(filter.ExpiredBefore), filter.Limit)
 }
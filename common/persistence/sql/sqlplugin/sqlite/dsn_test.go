@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common/config"
+)
+
+type StoreTestSuite struct {
+	suite.Suite
+}
+
+func TestStoreTestSuite(t *testing.T) {
+	suite.Run(t, new(StoreTestSuite))
+}
+
+func (s *StoreTestSuite) TestBuildDSN() {
+	testCases := []struct {
+		in  config.SQL
+		out string
+	}{
+		{
+			in: config.SQL{
+				DatabaseName: "/tmp/cadence.db",
+			},
+			out: "/tmp/cadence.db?_busy_timeout=10000&_journal_mode=WAL&_txlock=immediate",
+		},
+		{
+			in:  config.SQL{},
+			out: ":memory:?_busy_timeout=10000&_journal_mode=WAL&_txlock=immediate",
+		},
+		{
+			in: config.SQL{
+				DatabaseName:      "cadence.db",
+				ConnectAttributes: map[string]string{"_busy_timeout": "500", "_foreign_keys": "1"},
+			},
+			out: "cadence.db?_busy_timeout=500&_foreign_keys=1&_journal_mode=WAL&_txlock=immediate",
+		},
+		{
+			in: config.SQL{
+				DatabaseName:      "cadence.db",
+				ConnectAttributes: map[string]string{"_txlock": "deferred"},
+			},
+			out: "cadence.db?_busy_timeout=10000&_journal_mode=WAL&_txlock=immediate",
+		},
+	}
+
+	for _, tc := range testCases {
+		s.Equal(tc.out, buildDSN(&tc.in))
+	}
+}
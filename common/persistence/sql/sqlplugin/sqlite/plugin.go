@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlite
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/iancoleman/strcase"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3" // needed to load the sqlite3 driver
+
+	"github.com/uber/cadence/common/config"
+	pt "github.com/uber/cadence/common/persistence/persistence-tests"
+	"github.com/uber/cadence/common/persistence/sql"
+	"github.com/uber/cadence/common/persistence/sql/sqldriver"
+	"github.com/uber/cadence/common/persistence/sql/sqlplugin"
+)
+
+const (
+	// PluginName is the name of the plugin
+	PluginName = "sqlite3"
+	// memoryDatabaseName is the database opened when no database file is configured,
+	// it is only useful for the admin operations creating or dropping database files
+	memoryDatabaseName = ":memory:"
+	defaultBusyTimeout = "10000" // in milliseconds
+)
+
+var dsnAttrDefaults = map[string]string{
+	"_busy_timeout": defaultBusyTimeout,
+	"_journal_mode": "WAL",
+}
+
+var dsnAttrOverrides = map[string]string{
+	// transactions take the write lock of the database as soon as they begin, which gives them the
+	// same isolation as the SELECT ... FOR UPDATE row locks taken by the other plugins
+	"_txlock": "immediate",
+}
+
+type plugin struct{}
+
+var _ sqlplugin.Plugin = (*plugin)(nil)
+
+func init() {
+	sql.RegisterPlugin(PluginName, &plugin{})
+}
+
+// CreateDB initialize the db object
+func (p *plugin) CreateDB(cfg *config.SQL) (sqlplugin.DB, error) {
+	conns, err := sqldriver.CreateDBConnections(cfg, func(cfg *config.SQL) (*sqlx.DB, error) {
+		return p.createSingleDBConn(cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newDB(conns, nil, sqlplugin.DbShardUndefined, cfg.NumShards)
+}
+
+// CreateAdminDB initialize the adminDb object
+func (p *plugin) CreateAdminDB(cfg *config.SQL) (sqlplugin.AdminDB, error) {
+	conns, err := sqldriver.CreateDBConnections(cfg, func(cfg *config.SQL) (*sqlx.DB, error) {
+		return p.createSingleDBConn(cfg)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newDB(conns, nil, sqlplugin.DbShardUndefined, cfg.NumShards)
+}
+
+// createSingleDBConn opens the database file named by cfg.DatabaseName, the connect address,
+// user and password are not used by an embedded database
+func (p *plugin) createSingleDBConn(cfg *config.SQL) (*sqlx.DB, error) {
+	db, err := sqlx.Connect(PluginName, buildDSN(cfg))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.DatabaseName == "" {
+		// every connection to an in-memory database opens a new, empty database
+		db.SetMaxOpenConns(1)
+	} else if cfg.MaxConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.MaxConnLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.MaxConnLifetime)
+	}
+
+	// Maps struct names in CamelCase to snake without need for db struct tags.
+	db.MapperFunc(strcase.ToSnake)
+	return db, nil
+}
+
+func buildDSN(cfg *config.SQL) string {
+	dsn := cfg.DatabaseName
+	if dsn == "" {
+		dsn = memoryDatabaseName
+	}
+	attrs := buildDSNAttrs(cfg)
+	if attrs != "" {
+		dsn = dsn + "?" + attrs
+	}
+	return dsn
+}
+
+func buildDSNAttrs(cfg *config.SQL) string {
+	attrs := make(map[string]string, len(dsnAttrDefaults)+len(dsnAttrOverrides)+len(cfg.ConnectAttributes))
+	for k, v := range dsnAttrDefaults {
+		attrs[k] = v
+	}
+	for k, v := range cfg.ConnectAttributes {
+		attrs[k] = v
+	}
+	// these attrs are always overriden
+	for k, v := range dsnAttrOverrides {
+		attrs[k] = v
+	}
+
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteString("&")
+		}
+		buf.WriteString(url.QueryEscape(k))
+		buf.WriteString("=")
+		buf.WriteString(url.QueryEscape(attrs[k]))
+	}
+	return buf.String()
+}
+
+const (
+	testSchemaDir = "schema/sqlite"
+)
+
+// GetTestClusterOption return test options
+func GetTestClusterOption() *pt.TestBaseOptions {
+	return &pt.TestBaseOptions{
+		DBPluginName: PluginName,
+		DBName:       filepath.Join(os.TempDir(), fmt.Sprintf("cadence_test_%v.db", pt.GenerateRandomDBName(10))),
+		SchemaDir:    testSchemaDir,
+		StoreType:    config.StoreTypeSQL,
+	}
+}
@@ -0,0 +1,464 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xwb1989/sqlparser"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/definition"
+	"github.com/uber/cadence/common/types"
+)
+
+type (
+	// VisibilityQueryDialect renders the database specific parts of a query-based visibility request
+	VisibilityQueryDialect interface {
+		// Placeholder returns the bind variable of the n-th argument, starting from 1
+		Placeholder(n int) string
+		// SearchAttribute returns the expression reading a key of the search_attributes column as a JSON value
+		SearchAttribute(key string) string
+		// SearchAttributeText returns the expression reading a key of the search_attributes column as text
+		SearchAttributeText(key string) string
+		// JSON returns the expression casting a bind variable holding a JSON document to a JSON value
+		JSON(placeholder string) string
+		// JSONContains returns the expression testing if target equals candidate or, for arrays, contains it
+		JSONContains(target, candidate string) string
+		// DateTime converts a time into the value bound for a datetime column
+		DateTime(t time.Time) interface{}
+	}
+
+	visibilityQueryBuilder struct {
+		dialect        VisibilityQueryDialect
+		attributeTypes map[string]types.IndexedValueType
+		args           []interface{}
+	}
+
+	visibilityField struct {
+		name      string
+		column    string // set for system keys, which are stored in their own column
+		key       string // set for custom keys, which are stored in the search_attributes column
+		valueType types.IndexedValueType
+	}
+)
+
+const (
+	visibilityWhereTemplate   = "select * from dummy where %s"
+	visibilityOrderByTemplate = "select * from dummy %s"
+
+	visibilityQueryFields = `workflow_id, run_id, start_time, execution_time, workflow_type_name, close_time, close_status, history_length, ` +
+		`memo, encoding, task_list, is_cron, num_clusters, search_attributes`
+	visibilityDefaultOrderBy = ` ORDER BY start_time DESC, run_id`
+
+	// missingValue is used by queries like `CloseTime = missing` to filter on absent values
+	missingValue = "missing"
+)
+
+var (
+	visibilitySystemFields = map[string]visibilityField{
+		definition.DomainID:      {name: definition.DomainID, column: "domain_id", valueType: types.IndexedValueTypeKeyword},
+		definition.WorkflowID:    {name: definition.WorkflowID, column: "workflow_id", valueType: types.IndexedValueTypeKeyword},
+		definition.RunID:         {name: definition.RunID, column: "run_id", valueType: types.IndexedValueTypeKeyword},
+		definition.WorkflowType:  {name: definition.WorkflowType, column: "workflow_type_name", valueType: types.IndexedValueTypeKeyword},
+		definition.TaskList:      {name: definition.TaskList, column: "task_list", valueType: types.IndexedValueTypeKeyword},
+		definition.StartTime:     {name: definition.StartTime, column: "start_time", valueType: types.IndexedValueTypeDatetime},
+		definition.ExecutionTime: {name: definition.ExecutionTime, column: "execution_time", valueType: types.IndexedValueTypeDatetime},
+		definition.CloseTime:     {name: definition.CloseTime, column: "close_time", valueType: types.IndexedValueTypeDatetime},
+		definition.CloseStatus:   {name: definition.CloseStatus, column: "close_status", valueType: types.IndexedValueTypeInt},
+		definition.HistoryLength: {name: definition.HistoryLength, column: "history_length", valueType: types.IndexedValueTypeInt},
+		definition.IsCron:        {name: definition.IsCron, column: "is_cron", valueType: types.IndexedValueTypeBool},
+		definition.NumClusters:   {name: definition.NumClusters, column: "num_clusters", valueType: types.IndexedValueTypeInt},
+	}
+
+	// search attribute keys are embedded into the JSON path expressions, so only plain identifiers are allowed
+	searchAttributeKeyRegex = regexp.MustCompile(`^\w+$`)
+
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+// BuildVisibilitySelectQuery translates a query-based visibility filter into a SELECT statement on executions_visibility.
+// Results are sorted by the ORDER BY clause of the query, or by start time and run ID if there is none.
+func BuildVisibilitySelectQuery(filter *VisibilityQueryFilter, dialect VisibilityQueryDialect) (string, []interface{}, error) {
+	b := newVisibilityQueryBuilder(filter, dialect)
+	sel, err := parseVisibilityQuery(filter.Query)
+	if err != nil {
+		return "", nil, newInvalidVisibilityQueryError(err)
+	}
+	conditions, err := b.buildConditions(filter.DomainID, sel)
+	if err != nil {
+		return "", nil, newInvalidVisibilityQueryError(err)
+	}
+	var orderBy string
+	if sel != nil && len(sel.OrderBy) > 0 && !filter.IgnoreOrderBy {
+		orderBy, err = b.buildOrderBy(sel.OrderBy)
+		if err != nil {
+			return "", nil, newInvalidVisibilityQueryError(err)
+		}
+	}
+
+	query := `SELECT ` + visibilityQueryFields + ` FROM executions_visibility WHERE ` + conditions
+	if orderBy == "" {
+		// RunID condition is needed for correct pagination
+		if filter.LastStartTime != nil && filter.LastRunID != nil {
+			lastStartTime := dialect.DateTime(*filter.LastStartTime)
+			query += fmt.Sprintf(` AND (start_time < %s OR (start_time = %s AND run_id > %s))`,
+				b.bind(lastStartTime), b.bind(lastStartTime), b.bind(*filter.LastRunID))
+		}
+		query += visibilityDefaultOrderBy + ` LIMIT ` + b.bind(filter.PageSize)
+	} else {
+		query += ` ORDER BY ` + orderBy + `, run_id LIMIT ` + b.bind(filter.PageSize) + ` OFFSET ` + b.bind(filter.Offset)
+	}
+	return query, b.args, nil
+}
+
+// BuildVisibilityCountQuery translates a query-based visibility filter into a SELECT COUNT(*) statement on executions_visibility
+func BuildVisibilityCountQuery(filter *VisibilityQueryFilter, dialect VisibilityQueryDialect) (string, []interface{}, error) {
+	b := newVisibilityQueryBuilder(filter, dialect)
+	sel, err := parseVisibilityQuery(filter.Query)
+	if err != nil {
+		return "", nil, newInvalidVisibilityQueryError(err)
+	}
+	conditions, err := b.buildConditions(filter.DomainID, sel)
+	if err != nil {
+		return "", nil, newInvalidVisibilityQueryError(err)
+	}
+	return `SELECT COUNT(*) FROM executions_visibility WHERE ` + conditions, b.args, nil
+}
+
+func newVisibilityQueryBuilder(filter *VisibilityQueryFilter, dialect VisibilityQueryDialect) *visibilityQueryBuilder {
+	return &visibilityQueryBuilder{
+		dialect:        dialect,
+		attributeTypes: filter.SearchAttributeTypes,
+	}
+}
+
+func newInvalidVisibilityQueryError(err error) error {
+	return &types.BadRequestError{Message: fmt.Sprintf("Error when parse query: %v", err)}
+}
+
+// parseVisibilityQuery returns nil if the query is empty
+func parseVisibilityQuery(query string) (*sqlparser.Select, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+	template := visibilityWhereTemplate
+	if common.IsJustOrderByClause(query) {
+		template = visibilityOrderByTemplate
+	}
+	stmt, err := sqlparser.Parse(fmt.Sprintf(template, query))
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok {
+		return nil, fmt.Errorf("invalid select query")
+	}
+	return sel, nil
+}
+
+func (b *visibilityQueryBuilder) bind(value interface{}) string {
+	b.args = append(b.args, value)
+	return b.dialect.Placeholder(len(b.args))
+}
+
+func (b *visibilityQueryBuilder) buildConditions(domainID string, sel *sqlparser.Select) (string, error) {
+	conditions := `domain_id = ` + b.bind(domainID)
+	if sel == nil || sel.Where == nil {
+		return conditions, nil
+	}
+	where, err := b.buildExpr(sel.Where.Expr)
+	if err != nil {
+		return "", err
+	}
+	return conditions + ` AND (` + where + `)`, nil
+}
+
+func (b *visibilityQueryBuilder) buildExpr(expr sqlparser.Expr) (string, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		return b.buildAndOrExpr(expr.Left, expr.Right, "AND")
+	case *sqlparser.OrExpr:
+		return b.buildAndOrExpr(expr.Left, expr.Right, "OR")
+	case *sqlparser.ParenExpr:
+		return b.buildExpr(expr.Expr)
+	case *sqlparser.ComparisonExpr:
+		return b.buildComparisonExpr(expr)
+	case *sqlparser.RangeCond:
+		return b.buildRangeCond(expr)
+	default:
+		return "", fmt.Errorf("unsupported expression: %s", sqlparser.String(expr))
+	}
+}
+
+func (b *visibilityQueryBuilder) buildAndOrExpr(left, right sqlparser.Expr, operator string) (string, error) {
+	leftStr, err := b.buildExpr(left)
+	if err != nil {
+		return "", err
+	}
+	rightStr, err := b.buildExpr(right)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("(%s %s %s)", leftStr, operator, rightStr), nil
+}
+
+func (b *visibilityQueryBuilder) buildComparisonExpr(expr *sqlparser.ComparisonExpr) (string, error) {
+	field, err := b.resolveField(expr.Left)
+	if err != nil {
+		return "", err
+	}
+
+	if colName, ok := expr.Right.(*sqlparser.ColName); ok && colName.Name.EqualString(missingValue) {
+		switch expr.Operator {
+		case sqlparser.EqualStr:
+			return b.target(field) + ` IS NULL`, nil
+		case sqlparser.NotEqualStr:
+			return b.target(field) + ` IS NOT NULL`, nil
+		default:
+			return "", fmt.Errorf("operator %s is not supported for missing value", expr.Operator)
+		}
+	}
+
+	switch expr.Operator {
+	case sqlparser.EqualStr, sqlparser.NotEqualStr,
+		sqlparser.LessThanStr, sqlparser.LessEqualStr,
+		sqlparser.GreaterThanStr, sqlparser.GreaterEqualStr:
+		value, err := b.convertValue(field, expr.Right)
+		if err != nil {
+			return "", err
+		}
+		return b.compare(field, expr.Operator, value)
+	case sqlparser.InStr, sqlparser.NotInStr:
+		tuple, ok := expr.Right.(sqlparser.ValTuple)
+		if !ok {
+			return "", fmt.Errorf("invalid value list: %s", sqlparser.String(expr.Right))
+		}
+		conditions := make([]string, 0, len(tuple))
+		for _, valExpr := range tuple {
+			value, err := b.convertValue(field, valExpr)
+			if err != nil {
+				return "", err
+			}
+			condition, err := b.compare(field, sqlparser.EqualStr, value)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, condition)
+		}
+		condition := `(` + strings.Join(conditions, ` OR `) + `)`
+		if expr.Operator == sqlparser.NotInStr {
+			condition = `NOT ` + condition
+		}
+		return condition, nil
+	case sqlparser.LikeStr, sqlparser.NotLikeStr:
+		if field.valueType != types.IndexedValueTypeString && field.valueType != types.IndexedValueTypeKeyword {
+			return "", fmt.Errorf("operator %s is only supported for string fields", expr.Operator)
+		}
+		pattern, ok := expr.Right.(*sqlparser.SQLVal)
+		if !ok || pattern.Type != sqlparser.StrVal {
+			return "", fmt.Errorf("invalid pattern: %s", sqlparser.String(expr.Right))
+		}
+		return fmt.Sprintf("%s %s %s", b.textTarget(field), strings.ToUpper(expr.Operator), b.bind(string(pattern.Val))), nil
+	default:
+		return "", fmt.Errorf("operator %s is not supported", expr.Operator)
+	}
+}
+
+func (b *visibilityQueryBuilder) buildRangeCond(expr *sqlparser.RangeCond) (string, error) {
+	field, err := b.resolveField(expr.Left)
+	if err != nil {
+		return "", err
+	}
+	from, err := b.convertValue(field, expr.From)
+	if err != nil {
+		return "", err
+	}
+	to, err := b.convertValue(field, expr.To)
+	if err != nil {
+		return "", err
+	}
+	fromCondition, err := b.compare(field, sqlparser.GreaterEqualStr, from)
+	if err != nil {
+		return "", err
+	}
+	toCondition, err := b.compare(field, sqlparser.LessEqualStr, to)
+	if err != nil {
+		return "", err
+	}
+	condition := fmt.Sprintf("(%s AND %s)", fromCondition, toCondition)
+	switch expr.Operator {
+	case sqlparser.BetweenStr:
+		return condition, nil
+	case sqlparser.NotBetweenStr:
+		return `NOT ` + condition, nil
+	default:
+		return "", fmt.Errorf("operator %s is not supported", expr.Operator)
+	}
+}
+
+func (b *visibilityQueryBuilder) buildOrderBy(orderBy sqlparser.OrderBy) (string, error) {
+	fields := make([]string, 0, len(orderBy))
+	for _, order := range orderBy {
+		field, err := b.resolveField(order.Expr)
+		if err != nil {
+			return "", err
+		}
+		if field.valueType == types.IndexedValueTypeString {
+			return "", fmt.Errorf("not able to sort by IndexedValueTypeString field, use IndexedValueTypeKeyword field")
+		}
+		direction := "ASC"
+		if order.Direction == sqlparser.DescScr {
+			direction = "DESC"
+		}
+		fields = append(fields, b.target(field)+" "+direction)
+	}
+	return strings.Join(fields, ", "), nil
+}
+
+func (b *visibilityQueryBuilder) resolveField(expr sqlparser.Expr) (*visibilityField, error) {
+	colName, ok := expr.(*sqlparser.ColName)
+	if !ok {
+		return nil, fmt.Errorf("invalid field: %s", sqlparser.String(expr))
+	}
+	name := colName.Name.String()
+	if qualifier := colName.Qualifier.Name.String(); qualifier != "" {
+		name = qualifier + "." + name
+	}
+	if field, ok := visibilitySystemFields[name]; ok {
+		return &field, nil
+	}
+	key := strings.TrimPrefix(name, definition.Attr+".")
+	valueType, ok := b.attributeTypes[key]
+	if !ok || !searchAttributeKeyRegex.MatchString(key) {
+		return nil, fmt.Errorf("invalid search attribute: %s", key)
+	}
+	return &visibilityField{name: key, key: key, valueType: valueType}, nil
+}
+
+func (b *visibilityQueryBuilder) target(field *visibilityField) string {
+	if field.column != "" {
+		return field.column
+	}
+	return b.dialect.SearchAttribute(field.key)
+}
+
+func (b *visibilityQueryBuilder) textTarget(field *visibilityField) string {
+	if field.column != "" {
+		return field.column
+	}
+	return b.dialect.SearchAttributeText(field.key)
+}
+
+func (b *visibilityQueryBuilder) compare(field *visibilityField, operator string, value interface{}) (string, error) {
+	if field.column != "" {
+		return fmt.Sprintf("%s %s %s", field.column, operator, b.bind(value)), nil
+	}
+
+	// string attributes are analyzed text in Elasticsearch, so match them by substring instead of equality
+	if field.valueType == types.IndexedValueTypeString && (operator == sqlparser.EqualStr || operator == sqlparser.NotEqualStr) {
+		like := "LIKE"
+		if operator == sqlparser.NotEqualStr {
+			like = "NOT LIKE"
+		}
+		pattern := "%" + likeEscaper.Replace(value.(string)) + "%"
+		return fmt.Sprintf("%s %s %s", b.dialect.SearchAttributeText(field.key), like, b.bind(pattern)), nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	target := b.dialect.SearchAttribute(field.key)
+	candidate := b.dialect.JSON(b.bind(string(data)))
+	switch operator {
+	case sqlparser.EqualStr:
+		// array values, e.g. BinaryChecksums, match if any of their elements is equal
+		return b.dialect.JSONContains(target, candidate), nil
+	case sqlparser.NotEqualStr:
+		return `NOT ` + b.dialect.JSONContains(target, candidate), nil
+	default:
+		return fmt.Sprintf("%s %s %s", target, operator, candidate), nil
+	}
+}
+
+func (b *visibilityQueryBuilder) convertValue(field *visibilityField, expr sqlparser.Expr) (interface{}, error) {
+	if boolVal, ok := expr.(sqlparser.BoolVal); ok {
+		if field.valueType != types.IndexedValueTypeBool {
+			return nil, fmt.Errorf("invalid value for %s: %s", field.name, sqlparser.String(expr))
+		}
+		return bool(boolVal), nil
+	}
+	sqlVal, ok := expr.(*sqlparser.SQLVal)
+	if !ok || (sqlVal.Type != sqlparser.StrVal && sqlVal.Type != sqlparser.IntVal && sqlVal.Type != sqlparser.FloatVal) {
+		return nil, fmt.Errorf("invalid value for %s: %s", field.name, sqlparser.String(expr))
+	}
+	val := string(sqlVal.Val)
+
+	if field.name == definition.CloseStatus {
+		var status types.WorkflowExecutionCloseStatus
+		if err := status.UnmarshalText(sqlVal.Val); err != nil {
+			return nil, err
+		}
+		return int32(status), nil
+	}
+
+	switch field.valueType {
+	case types.IndexedValueTypeString, types.IndexedValueTypeKeyword:
+		return val, nil
+	case types.IndexedValueTypeInt:
+		return strconv.ParseInt(val, 10, 64)
+	case types.IndexedValueTypeDouble:
+		return strconv.ParseFloat(val, 64)
+	case types.IndexedValueTypeBool:
+		return strconv.ParseBool(val)
+	case types.IndexedValueTypeDatetime:
+		timestamp, err := parseVisibilityTimestamp(val)
+		if err != nil {
+			return nil, err
+		}
+		if field.column != "" {
+			return b.dialect.DateTime(time.Unix(0, timestamp).UTC()), nil
+		}
+		// custom datetime attributes are stored as unix nanos so they can be compared as numbers
+		return timestamp, nil
+	default:
+		return nil, fmt.Errorf("unknown type %v of %s", field.valueType, field.name)
+	}
+}
+
+// parseVisibilityTimestamp parses a time given either in unix nanos or in RFC3339 format into unix nanos
+func parseVisibilityTimestamp(value string) (int64, error) {
+	if timestamp, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return timestamp, nil
+	}
+	parsedTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return parsedTime.UnixNano(), nil
+}
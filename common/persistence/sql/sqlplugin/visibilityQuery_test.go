@@ -0,0 +1,177 @@
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package sqlplugin
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"github.com/uber/cadence/common/types"
+)
+
+type (
+	visibilityQuerySuite struct {
+		suite.Suite
+		filter *VisibilityQueryFilter
+	}
+
+	testVisibilityQueryDialect struct{}
+)
+
+func TestVisibilityQuerySuite(t *testing.T) {
+	suite.Run(t, new(visibilityQuerySuite))
+}
+
+func (s *visibilityQuerySuite) SetupTest() {
+	s.filter = &VisibilityQueryFilter{
+		DomainID: "domain",
+		SearchAttributeTypes: map[string]types.IndexedValueType{
+			"CustomStringField":   types.IndexedValueTypeString,
+			"CustomKeywordField":  types.IndexedValueTypeKeyword,
+			"CustomIntField":      types.IndexedValueTypeInt,
+			"CustomBoolField":     types.IndexedValueTypeBool,
+			"CustomDatetimeField": types.IndexedValueTypeDatetime,
+		},
+		PageSize: 10,
+	}
+}
+
+func (s *visibilityQuerySuite) TestBuildVisibilityCountQuery() {
+	startTime := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		query         string
+		expectedWhere string
+		expectedArgs  []interface{}
+	}{
+		{
+			query:         "",
+			expectedWhere: "domain_id = $1",
+			expectedArgs:  []interface{}{"domain"},
+		},
+		{
+			query:         "WorkflowID = 'wid' and CloseTime = missing",
+			expectedWhere: "domain_id = $1 AND ((workflow_id = $2 AND close_time IS NULL))",
+			expectedArgs:  []interface{}{"domain", "wid"},
+		},
+		{
+			query:         "(CloseStatus = 'completed' or CloseStatus = 3) and IsCron = true",
+			expectedWhere: "domain_id = $1 AND (((close_status = $2 OR close_status = $3) AND is_cron = $4))",
+			expectedArgs:  []interface{}{"domain", int32(0), int32(3), true},
+		},
+		{
+			query:         "StartTime between '2021-01-02T03:04:05Z' and " + fmt.Sprint(startTime.Add(time.Hour).UnixNano()),
+			expectedWhere: "domain_id = $1 AND ((start_time >= $2 AND start_time <= $3))",
+			expectedArgs:  []interface{}{"domain", startTime, startTime.Add(time.Hour)},
+		},
+		{
+			query:         "`Attr.CustomKeywordField` in ('a', 'b') and `Attr.CustomIntField` != 1",
+			expectedWhere: "domain_id = $1 AND (((SA[CustomKeywordField] CONTAINS JSON($2) OR SA[CustomKeywordField] CONTAINS JSON($3)) AND NOT SA[CustomIntField] CONTAINS JSON($4)))",
+			expectedArgs:  []interface{}{"domain", `"a"`, `"b"`, `1`},
+		},
+		{
+			query:         "`Attr.CustomStringField` = 'some_text%' and `Attr.CustomBoolField` = missing",
+			expectedWhere: "domain_id = $1 AND ((TEXT[CustomStringField] LIKE $2 AND SA[CustomBoolField] IS NULL))",
+			expectedArgs:  []interface{}{"domain", `%some\_text\%%`},
+		},
+		{
+			query:         "`Attr.CustomDatetimeField` > '2021-01-02T03:04:05Z' and WorkflowType like 'type%'",
+			expectedWhere: "domain_id = $1 AND ((SA[CustomDatetimeField] > JSON($2) AND workflow_type_name LIKE $3))",
+			expectedArgs:  []interface{}{"domain", fmt.Sprint(startTime.UnixNano()), "type%"},
+		},
+	}
+
+	for _, test := range tests {
+		s.Run(test.query, func() {
+			s.filter.Query = test.query
+			query, args, err := BuildVisibilityCountQuery(s.filter, &testVisibilityQueryDialect{})
+			s.NoError(err)
+			s.Equal("SELECT COUNT(*) FROM executions_visibility WHERE "+test.expectedWhere, query)
+			s.Equal(test.expectedArgs, args)
+		})
+	}
+}
+
+func (s *visibilityQuerySuite) TestBuildVisibilitySelectQuery() {
+	s.filter.Query = "`Attr.CustomIntField` > 1 order by `Attr.CustomKeywordField` desc, StartTime"
+	s.filter.Offset = 20
+	query, args, err := BuildVisibilitySelectQuery(s.filter, &testVisibilityQueryDialect{})
+	s.NoError(err)
+	s.Equal("SELECT "+visibilityQueryFields+" FROM executions_visibility WHERE domain_id = $1 AND (SA[CustomIntField] > JSON($2))"+
+		" ORDER BY SA[CustomKeywordField] DESC, start_time ASC, run_id LIMIT $3 OFFSET $4", query)
+	s.Equal([]interface{}{"domain", "1", 10, 20}, args)
+
+	lastStartTime := time.Now()
+	s.filter.IgnoreOrderBy = true
+	s.filter.LastStartTime = &lastStartTime
+	s.filter.LastRunID = &s.filter.DomainID
+	query, args, err = BuildVisibilitySelectQuery(s.filter, &testVisibilityQueryDialect{})
+	s.NoError(err)
+	s.Equal("SELECT "+visibilityQueryFields+" FROM executions_visibility WHERE domain_id = $1 AND (SA[CustomIntField] > JSON($2))"+
+		" AND (start_time < $3 OR (start_time = $4 AND run_id > $5)) ORDER BY start_time DESC, run_id LIMIT $6", query)
+	s.Equal([]interface{}{"domain", "1", lastStartTime, lastStartTime, "domain", 10}, args)
+}
+
+func (s *visibilityQuerySuite) TestInvalidQuery() {
+	for _, query := range []string{
+		"WorkflowID = ",
+		"UnknownField = 'a'",
+		"`Attr.CustomIntField` = 'a'",
+		"`Attr.CustomBoolField` = 'yes'",
+		"CloseStatus = 'unknown'",
+		"CloseTime > missing",
+		"WorkflowID = 'a' order by `Attr.CustomStringField`",
+		"`Attr.CustomIntField` like '1%'",
+		"WorkflowID regexp 'a'",
+	} {
+		s.Run(query, func() {
+			s.filter.Query = query
+			_, _, err := BuildVisibilitySelectQuery(s.filter, &testVisibilityQueryDialect{})
+			s.IsType(&types.BadRequestError{}, err)
+		})
+	}
+}
+
+func (d *testVisibilityQueryDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (d *testVisibilityQueryDialect) SearchAttribute(key string) string {
+	return fmt.Sprintf("SA[%s]", key)
+}
+
+func (d *testVisibilityQueryDialect) SearchAttributeText(key string) string {
+	return fmt.Sprintf("TEXT[%s]", key)
+}
+
+func (d *testVisibilityQueryDialect) JSON(placeholder string) string {
+	return fmt.Sprintf("JSON(%s)", placeholder)
+}
+
+func (d *testVisibilityQueryDialect) JSONContains(target, candidate string) string {
+	return fmt.Sprintf("%s CONTAINS %s", target, candidate)
+}
+
+func (d *testVisibilityQueryDialect) DateTime(t time.Time) interface{} {
+	return t
+}
@@ -97,6 +97,9 @@ const (
 	FailureReasonTransactionSizeExceedsLimit = "TRANSACTION_SIZE_EXCEEDS_LIMIT"
 	// FailureReasonDecisionAttemptsExceedsLimit is reason to fail workflow when decision attempts fail too many times
 	FailureReasonDecisionAttemptsExceedsLimit = "DECISION_ATTEMPTS_EXCEEDS_LIMIT"
+
+	// HistorySizeWarningMarkerName is the name of the marker recorded when the history event count crosses the warn limit
+	HistorySizeWarningMarkerName = "HistorySizeWarning"
 )
 
 var (
@@ -0,0 +1,55 @@
+// Copyright (c) 2021 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cadence
+
+// below are the names of all DynamoDB tables.
+// Each table is prefixed with the configured keyspace so that multiple clusters can share one AWS account/region.
+const (
+	ShardTableName              = "shard"
+	CurrentWorkflowTableName    = "current_workflow"
+	WorkflowExecutionTableName  = "workflow_execution"
+	ExecutionEntryTableName     = "workflow_execution_entry"
+	TransferTaskTableName       = "transfer_task"
+	ReplicationTaskTableName    = "replication_task"
+	TimerTaskTableName          = "timer_task"
+	CrossClusterTaskTableName   = "cross_cluster_task"
+	ReplicationDLQTaskTableName = "replication_dlq_task"
+	HistoryTreeTableName        = "history_tree"
+	HistoryNodeTableName        = "history_node"
+	TaskListTableName           = "task_list"
+	TaskTableName               = "task"
+	QueueMessageTableName       = "queue_message"
+	QueueMetadataTableName      = "queue_metadata"
+	DomainTableName             = "domain"
+	DomainMetadataTableName     = "domain_metadata"
+	ClusterConfigTableName      = "cluster_config"
+	VisibilityTableName         = "visibility"
+)
+
+// below are the names of the secondary indexes
+const (
+	DomainIDIndexName            = "domain_id_index"
+	VisibilityStartTimeIndexName = "start_time_index"
+	VisibilityCloseTimeIndexName = "close_time_index"
+)
+
+// TTLAttributeName is the attribute that DynamoDB uses to expire items on tables that enable TimeToLive
+const TTLAttributeName = "expiry"
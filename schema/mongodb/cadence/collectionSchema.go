@@ -20,9 +20,28 @@
 
 package cadence
 
+import "time"
+
 // below are the names of all mongoDB collections
 const (
-	ClusterConfigCollectionName = "cluster_config"
+	ClusterConfigCollectionName      = "cluster_config"
+	DomainCollectionName             = "domain"
+	DomainMetadataCollectionName     = "domain_metadata"
+	ShardCollectionName              = "shard"
+	CurrentWorkflowCollectionName    = "current_workflow"
+	WorkflowExecutionCollectionName  = "workflow_execution"
+	TransferTaskCollectionName       = "transfer_task"
+	CrossClusterTaskCollectionName   = "cross_cluster_task"
+	ReplicationTaskCollectionName    = "replication_task"
+	TimerTaskCollectionName          = "timer_task"
+	ReplicationDLQTaskCollectionName = "replication_dlq_task"
+	HistoryTreeCollectionName        = "history_tree"
+	HistoryNodeCollectionName        = "history_node"
+	QueueMessageCollectionName       = "queue_message"
+	QueueMetadataCollectionName      = "queue_metadata"
+	TaskListCollectionName           = "task_list"
+	TaskCollectionName               = "task"
+	VisibilityCollectionName         = "visibility"
 )
 
 // NOTE1: MongoDB collection is schemaless -- there is no schema file for collection. We use Go lang structs to define the collection fields.
@@ -39,3 +58,155 @@ type ClusterConfigCollectionEntry struct {
 	DataEncoding         string `json:"dataencoding"`
 	UnixTimestampSeconds int64  `json:"unixtimestampseconds"`
 }
+
+// NOTE3: only the fields that are used in the filters, the sorts or the conditions of the queries are stored as fields,
+// the rest of the data is stored as a JSON blob in the data field, so that adding a new field doesn't require schema changes.
+
+// DomainCollectionEntry is the schema of domain
+type DomainCollectionEntry struct {
+	Name     string `json:"name"`
+	DomainID string `json:"domainid"`
+	Data     []byte `json:"data"`
+}
+
+// DomainMetadataCollectionEntry is the schema of domain_metadata. There is only one entry, with partition of zero.
+type DomainMetadataCollectionEntry struct {
+	Partition           int   `json:"partition"`
+	NotificationVersion int64 `json:"notificationversion"`
+}
+
+// ShardCollectionEntry is the schema of shard
+// WriteCount is increased by every workflow transaction of the shard, so that a transaction conflicts with any
+// concurrent change of the RangeID.
+type ShardCollectionEntry struct {
+	ShardID    int    `json:"shardid"`
+	RangeID    int64  `json:"rangeid"`
+	WriteCount int64  `json:"writecount"`
+	Data       []byte `json:"data"`
+}
+
+// CurrentWorkflowCollectionEntry is the schema of current_workflow
+type CurrentWorkflowCollectionEntry struct {
+	ShardID          int    `json:"shardid"`
+	DomainID         string `json:"domainid"`
+	WorkflowID       string `json:"workflowid"`
+	CurrentRunID     string `json:"currentrunid"`
+	LastWriteVersion int64  `json:"lastwriteversion"`
+	State            int    `json:"state"`
+	Data             []byte `json:"data"`
+}
+
+// WorkflowExecutionCollectionEntry is the schema of workflow_execution
+// The maps are keyed by the string form of the map keys(hex encoded for the string keys, because a field name can't
+// contain '.' or start with '$'), and each value is a JSON blob, so that an entry can be upserted or deleted without
+// reading the whole document. SignalRequestedIDs has no value, so it's a map to true.
+type WorkflowExecutionCollectionEntry struct {
+	ShardID             int               `json:"shardid"`
+	DomainID            string            `json:"domainid"`
+	WorkflowID          string            `json:"workflowid"`
+	RunID               string            `json:"runid"`
+	NextEventID         int64             `json:"nexteventid"`
+	Data                []byte            `json:"data"`
+	ActivityInfos       map[string][]byte `json:"activityinfos"`
+	TimerInfos          map[string][]byte `json:"timerinfos"`
+	ChildExecutionInfos map[string][]byte `json:"childexecutioninfos"`
+	RequestCancelInfos  map[string][]byte `json:"requestcancelinfos"`
+	SignalInfos         map[string][]byte `json:"signalinfos"`
+	SignalRequestedIDs  map[string]bool   `json:"signalrequestedids"`
+	BufferedEvents      [][]byte          `json:"bufferedevents"`
+}
+
+// ShardTaskCollectionEntry is the schema of transfer_task and replication_task
+type ShardTaskCollectionEntry struct {
+	ShardID int    `json:"shardid"`
+	TaskID  int64  `json:"taskid"`
+	Data    []byte `json:"data"`
+}
+
+// ClusterTaskCollectionEntry is the schema of cross_cluster_task and replication_dlq_task
+type ClusterTaskCollectionEntry struct {
+	ShardID int    `json:"shardid"`
+	Cluster string `json:"cluster"`
+	TaskID  int64  `json:"taskid"`
+	Data    []byte `json:"data"`
+}
+
+// TimerTaskCollectionEntry is the schema of timer_task, VisibilityTimestamp is in unix nanoseconds
+type TimerTaskCollectionEntry struct {
+	ShardID             int    `json:"shardid"`
+	VisibilityTimestamp int64  `json:"visibilitytimestamp"`
+	TaskID              int64  `json:"taskid"`
+	Data                []byte `json:"data"`
+}
+
+// HistoryTreeCollectionEntry is the schema of history_tree
+type HistoryTreeCollectionEntry struct {
+	ShardID  int    `json:"shardid"`
+	TreeID   string `json:"treeid"`
+	BranchID string `json:"branchid"`
+	Data     []byte `json:"data"`
+}
+
+// HistoryNodeCollectionEntry is the schema of history_node
+type HistoryNodeCollectionEntry struct {
+	ShardID      int    `json:"shardid"`
+	TreeID       string `json:"treeid"`
+	BranchID     string `json:"branchid"`
+	NodeID       int64  `json:"nodeid"`
+	TxnID        int64  `json:"txnid"`
+	Data         []byte `json:"data"`
+	DataEncoding string `json:"dataencoding"`
+}
+
+// QueueMessageCollectionEntry is the schema of queue_message
+type QueueMessageCollectionEntry struct {
+	QueueType int    `json:"queuetype"`
+	MessageID int64  `json:"messageid"`
+	Payload   []byte `json:"payload"`
+}
+
+// QueueMetadataCollectionEntry is the schema of queue_metadata
+type QueueMetadataCollectionEntry struct {
+	QueueType        int              `json:"queuetype"`
+	Version          int64            `json:"version"`
+	ClusterAckLevels map[string]int64 `json:"clusteracklevels"`
+}
+
+// TaskListCollectionEntry is the schema of task_list
+// WriteCount is increased by every batch of task inserts, so that the inserts conflict with any concurrent change
+// of the RangeID. ExpireAt is indexed by a TTL index, the entry is deleted by MongoDB after it
+type TaskListCollectionEntry struct {
+	DomainID     string     `json:"domainid"`
+	Name         string     `json:"name"`
+	TaskListType int        `json:"tasklisttype"`
+	RangeID      int64      `json:"rangeid"`
+	WriteCount   int64      `json:"writecount"`
+	Data         []byte     `json:"data"`
+	ExpireAt     *time.Time `json:"expireat"`
+}
+
+// TaskCollectionEntry is the schema of task
+// ExpireAt is indexed by a TTL index, the entry is deleted by MongoDB after it
+type TaskCollectionEntry struct {
+	DomainID     string     `json:"domainid"`
+	TaskListName string     `json:"tasklistname"`
+	TaskListType int        `json:"tasklisttype"`
+	TaskID       int64      `json:"taskid"`
+	Data         []byte     `json:"data"`
+	ExpireAt     *time.Time `json:"expireat"`
+}
+
+// VisibilityCollectionEntry is the schema of visibility, StartTime and CloseTime are in unix nanoseconds
+// CloseTime and CloseStatus are nil for open workflows.
+// ExpireAt is indexed by a TTL index, the entry is deleted by MongoDB after it
+type VisibilityCollectionEntry struct {
+	DomainID     string     `json:"domainid"`
+	WorkflowID   string     `json:"workflowid"`
+	RunID        string     `json:"runid"`
+	WorkflowType string     `json:"workflowtype"`
+	StartTime    int64      `json:"starttime"`
+	CloseTime    *int64     `json:"closetime"`
+	CloseStatus  *int32     `json:"closestatus"`
+	Data         []byte     `json:"data"`
+	ExpireAt     *time.Time `json:"expireat"`
+}
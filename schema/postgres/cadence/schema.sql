@@ -92,9 +92,12 @@ CREATE TABLE tasks (
   --
   data BYTEA NOT NULL,
   data_encoding VARCHAR(16) NOT NULL,
+  expiry_ts TIMESTAMP,
   PRIMARY KEY (domain_id, task_list_name, task_type, task_id)
 );
 
+CREATE INDEX tasks_by_expiry_ts ON tasks(expiry_ts);
+
 CREATE TABLE task_lists (
   shard_id INTEGER NOT NULL,
   domain_id BYTEA NOT NULL,
@@ -104,9 +107,12 @@ CREATE TABLE task_lists (
   range_id BIGINT NOT NULL,
   data BYTEA NOT NULL,
   data_encoding VARCHAR(16) NOT NULL,
+  expiry_ts TIMESTAMP,
   PRIMARY KEY (shard_id, domain_id, name, task_type)
 );
 
+CREATE INDEX task_lists_by_expiry_ts ON task_lists(shard_id, expiry_ts);
+
 CREATE TABLE replication_tasks (
   shard_id INTEGER NOT NULL,
   task_id BIGINT NOT NULL,
@@ -0,0 +1,5 @@
+ALTER TABLE tasks ADD COLUMN expiry_ts TIMESTAMP;
+CREATE INDEX tasks_by_expiry_ts ON tasks(expiry_ts);
+
+ALTER TABLE task_lists ADD COLUMN expiry_ts TIMESTAMP;
+CREATE INDEX task_lists_by_expiry_ts ON task_lists(shard_id, expiry_ts);
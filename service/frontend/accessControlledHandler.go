@@ -580,6 +580,25 @@ func (a *AccessControlledWorkflowHandler) RespondDecisionTaskCompleted(
 	ctx context.Context,
 	request *types.RespondDecisionTaskCompletedRequest,
 ) (*types.RespondDecisionTaskCompletedResponse, error) {
+
+	// decisions that act on another domain need write permission on that domain
+	for _, domainName := range getDecisionTargetDomains(request.GetDecisions()) {
+		scope := a.getMetricsScopeWithDomainName(metrics.FrontendRespondDecisionTaskCompletedScope, domainName)
+
+		attr := &authorization.Attributes{
+			APIName:    "RespondDecisionTaskCompleted",
+			DomainName: domainName,
+			Permission: authorization.PermissionWrite,
+		}
+		isAuthorized, err := a.isAuthorized(ctx, attr, scope)
+		if err != nil {
+			return nil, err
+		}
+		if !isAuthorized {
+			return nil, errUnauthorized
+		}
+	}
+
 	return a.frontendHandler.RespondDecisionTaskCompleted(ctx, request)
 }
 
@@ -813,6 +832,31 @@ func (a *AccessControlledWorkflowHandler) isAuthorized(
 	return isAuth, nil
 }
 
+// getDecisionTargetDomains returns the distinct domains that decisions schedule activities, start child workflows,
+// signal or cancel workflows in, when set
+func getDecisionTargetDomains(decisions []*types.Decision) []string {
+	var domainNames []string
+	seen := make(map[string]bool)
+	for _, decision := range decisions {
+		var domainName string
+		switch decision.GetDecisionType() {
+		case types.DecisionTypeScheduleActivityTask:
+			domainName = decision.ScheduleActivityTaskDecisionAttributes.GetDomain()
+		case types.DecisionTypeStartChildWorkflowExecution:
+			domainName = decision.StartChildWorkflowExecutionDecisionAttributes.GetDomain()
+		case types.DecisionTypeSignalExternalWorkflowExecution:
+			domainName = decision.SignalExternalWorkflowExecutionDecisionAttributes.GetDomain()
+		case types.DecisionTypeRequestCancelExternalWorkflowExecution:
+			domainName = decision.RequestCancelExternalWorkflowExecutionDecisionAttributes.GetDomain()
+		}
+		if domainName != "" && !seen[domainName] {
+			seen[domainName] = true
+			domainNames = append(domainNames, domainName)
+		}
+	}
+	return domainNames
+}
+
 // getMetricsScopeWithDomain return metrics scope with domain tag
 func (a *AccessControlledWorkflowHandler) getMetricsScopeWithDomain(
 	scope int,
@@ -34,6 +34,7 @@ import (
 	"github.com/uber/cadence/common/metrics"
 	"github.com/uber/cadence/common/metrics/mocks"
 	"github.com/uber/cadence/common/resource"
+	"github.com/uber/cadence/common/types"
 )
 
 type (
@@ -116,3 +117,44 @@ func (s *accessControlledHandlerSuite) TestIsAuthorized_Unauthorized() {
 	s.False(res)
 	s.NoError(err)
 }
+
+func (s *accessControlledHandlerSuite) TestRespondDecisionTaskCompleted_CrossDomain() {
+	ctx := context.Background()
+	request := &types.RespondDecisionTaskCompletedRequest{
+		Decisions: []*types.Decision{
+			{
+				DecisionType:                           types.DecisionTypeScheduleActivityTask.Ptr(),
+				ScheduleActivityTaskDecisionAttributes: &types.ScheduleActivityTaskDecisionAttributes{},
+			},
+			{
+				DecisionType: types.DecisionTypeStartChildWorkflowExecution.Ptr(),
+				StartChildWorkflowExecutionDecisionAttributes: &types.StartChildWorkflowExecutionDecisionAttributes{
+					Domain: "target-domain",
+				},
+			},
+			{
+				DecisionType: types.DecisionTypeSignalExternalWorkflowExecution.Ptr(),
+				SignalExternalWorkflowExecutionDecisionAttributes: &types.SignalExternalWorkflowExecutionDecisionAttributes{
+					Domain: "target-domain",
+				},
+			},
+		},
+	}
+	attr := &authorization.Attributes{
+		APIName:    "RespondDecisionTaskCompleted",
+		DomainName: "target-domain",
+		Permission: authorization.PermissionWrite,
+	}
+
+	s.mockAuthorizer.EXPECT().Authorize(ctx, attr).
+		Return(authorization.Result{Decision: authorization.DecisionAllow}, nil).Times(1)
+	s.mockFrontendHandler.EXPECT().RespondDecisionTaskCompleted(ctx, request).
+		Return(&types.RespondDecisionTaskCompletedResponse{}, nil).Times(1)
+	_, err := s.handler.RespondDecisionTaskCompleted(ctx, request)
+	s.NoError(err)
+
+	s.mockAuthorizer.EXPECT().Authorize(ctx, attr).
+		Return(authorization.Result{Decision: authorization.DecisionDeny}, nil).Times(1)
+	_, err = s.handler.RespondDecisionTaskCompleted(ctx, request)
+	s.Equal(errUnauthorized, err)
+}
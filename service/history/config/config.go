@@ -237,10 +237,12 @@ type Config struct {
 	// Size limit related settings
 	BlobSizeLimitError     dynamicconfig.IntPropertyFnWithDomainFilter
 	BlobSizeLimitWarn      dynamicconfig.IntPropertyFnWithDomainFilter
-	HistorySizeLimitError  dynamicconfig.IntPropertyFnWithDomainFilter
-	HistorySizeLimitWarn   dynamicconfig.IntPropertyFnWithDomainFilter
-	HistoryCountLimitError dynamicconfig.IntPropertyFnWithDomainFilter
-	HistoryCountLimitWarn  dynamicconfig.IntPropertyFnWithDomainFilter
+	HistorySizeLimitError  dynamicconfig.IntPropertyFnWithWorkflowTypeFilter
+	HistorySizeLimitWarn   dynamicconfig.IntPropertyFnWithWorkflowTypeFilter
+	HistoryCountLimitError dynamicconfig.IntPropertyFnWithWorkflowTypeFilter
+	HistoryCountLimitWarn  dynamicconfig.IntPropertyFnWithWorkflowTypeFilter
+	// EnableHistorySizeWarningMarker records a marker event when the history crosses a warn limit
+	EnableHistorySizeWarningMarker dynamicconfig.BoolPropertyFnWithDomainFilter
 
 	// ValidSearchAttributes is legal indexed keys that can be used in list APIs
 	ValidSearchAttributes             dynamicconfig.MapPropertyFn
@@ -290,6 +292,7 @@ type Config struct {
 	MaxBufferedQueryCount         dynamicconfig.IntPropertyFn
 
 	EnableCrossClusterOperations dynamicconfig.BoolPropertyFnWithDomainFilter
+	EnforceCrossDomainAllowlist  dynamicconfig.BoolPropertyFnWithDomainFilter
 
 	// Data integrity check related config knobs
 	MutableStateChecksumGenProbability    dynamicconfig.IntPropertyFnWithDomainFilter
@@ -507,12 +510,13 @@ func New(dc *dynamicconfig.Collection, numberOfShards int, storeType string, isA
 		ArchiveRequestRPS:               dc.GetIntProperty(dynamicconfig.ArchiveRequestRPS, 300), // should be much smaller than frontend RPS
 		AllowArchivingIncompleteHistory: dc.GetBoolProperty(dynamicconfig.AllowArchivingIncompleteHistory, false),
 
-		BlobSizeLimitError:     dc.GetIntPropertyFilteredByDomain(dynamicconfig.BlobSizeLimitError, 2*1024*1024),
-		BlobSizeLimitWarn:      dc.GetIntPropertyFilteredByDomain(dynamicconfig.BlobSizeLimitWarn, 512*1024),
-		HistorySizeLimitError:  dc.GetIntPropertyFilteredByDomain(dynamicconfig.HistorySizeLimitError, 200*1024*1024),
-		HistorySizeLimitWarn:   dc.GetIntPropertyFilteredByDomain(dynamicconfig.HistorySizeLimitWarn, 50*1024*1024),
-		HistoryCountLimitError: dc.GetIntPropertyFilteredByDomain(dynamicconfig.HistoryCountLimitError, 200*1024),
-		HistoryCountLimitWarn:  dc.GetIntPropertyFilteredByDomain(dynamicconfig.HistoryCountLimitWarn, 50*1024),
+		BlobSizeLimitError:             dc.GetIntPropertyFilteredByDomain(dynamicconfig.BlobSizeLimitError, 2*1024*1024),
+		BlobSizeLimitWarn:              dc.GetIntPropertyFilteredByDomain(dynamicconfig.BlobSizeLimitWarn, 512*1024),
+		HistorySizeLimitError:          dc.GetIntPropertyFilteredByWorkflowType(dynamicconfig.HistorySizeLimitError, 200*1024*1024),
+		HistorySizeLimitWarn:           dc.GetIntPropertyFilteredByWorkflowType(dynamicconfig.HistorySizeLimitWarn, 50*1024*1024),
+		HistoryCountLimitError:         dc.GetIntPropertyFilteredByWorkflowType(dynamicconfig.HistoryCountLimitError, 200*1024),
+		HistoryCountLimitWarn:          dc.GetIntPropertyFilteredByWorkflowType(dynamicconfig.HistoryCountLimitWarn, 50*1024),
+		EnableHistorySizeWarningMarker: dc.GetBoolPropertyFilteredByDomain(dynamicconfig.EnableHistorySizeWarningMarker, false),
 
 		ThrottledLogRPS:   dc.GetIntProperty(dynamicconfig.HistoryThrottledLogRPS, 4),
 		EnableStickyQuery: dc.GetBoolPropertyFilteredByDomain(dynamicconfig.EnableStickyQuery, true),
@@ -552,6 +556,7 @@ func New(dc *dynamicconfig.Collection, numberOfShards int, storeType string, isA
 		EnableConsistentQuery:                 dc.GetBoolProperty(dynamicconfig.EnableConsistentQuery, true),
 		EnableConsistentQueryByDomain:         dc.GetBoolPropertyFilteredByDomain(dynamicconfig.EnableConsistentQueryByDomain, false),
 		EnableCrossClusterOperations:          dc.GetBoolPropertyFilteredByDomain(dynamicconfig.EnableCrossClusterOperations, false),
+		EnforceCrossDomainAllowlist:           dc.GetBoolPropertyFilteredByDomain(dynamicconfig.EnforceCrossDomainAllowlist, false),
 		MaxBufferedQueryCount:                 dc.GetIntProperty(dynamicconfig.MaxBufferedQueryCount, 1),
 		MutableStateChecksumGenProbability:    dc.GetIntPropertyFilteredByDomain(dynamicconfig.MutableStateChecksumGenProbability, 0),
 		MutableStateChecksumVerifyProbability: dc.GetIntPropertyFilteredByDomain(dynamicconfig.MutableStateChecksumVerifyProbability, 0),
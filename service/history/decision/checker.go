@@ -21,6 +21,7 @@
 package decision
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
@@ -58,12 +59,23 @@ type (
 		historyCountLimitWarn  int
 		historyCountLimitError int
 
+		warningMarkerEnabled bool
+		lastProcessedEventID int64
+
 		completedID    int64
 		mutableState   execution.MutableState
 		executionStats *persistence.ExecutionStats
 		metricsScope   metrics.Scope
 		logger         log.Logger
 	}
+
+	// historySizeWarning is the details of the HistorySizeWarning marker
+	historySizeWarning struct {
+		HistorySize       int `json:"historySize"`
+		HistoryCount      int `json:"historyCount"`
+		HistorySizeLimit  int `json:"historySizeLimit"`
+		HistoryCountLimit int `json:"historyCountLimit"`
+	}
 )
 
 func newAttrValidator(
@@ -94,6 +106,8 @@ func newWorkflowSizeChecker(
 	historySizeLimitError int,
 	historyCountLimitWarn int,
 	historyCountLimitError int,
+	warningMarkerEnabled bool,
+	lastProcessedEventID int64,
 	completedID int64,
 	mutableState execution.MutableState,
 	executionStats *persistence.ExecutionStats,
@@ -107,6 +121,8 @@ func newWorkflowSizeChecker(
 		historySizeLimitError:  historySizeLimitError,
 		historyCountLimitWarn:  historyCountLimitWarn,
 		historyCountLimitError: historyCountLimitError,
+		warningMarkerEnabled:   warningMarkerEnabled,
+		lastProcessedEventID:   lastProcessedEventID,
 		completedID:            completedID,
 		mutableState:           mutableState,
 		executionStats:         executionStats,
@@ -154,6 +170,12 @@ func (c *workflowSizeChecker) failWorkflowSizeExceedsLimit() (bool, error) {
 	historySize := int(c.executionStats.HistorySize)
 
 	if historySize > c.historySizeLimitError || historyCount > c.historyCountLimitError {
+		if historySize > c.historySizeLimitError {
+			c.metricsScope.IncCounter(metrics.HistorySizeErrorLimitExceededCounter)
+		}
+		if historyCount > c.historyCountLimitError {
+			c.metricsScope.IncCounter(metrics.HistoryCountErrorLimitExceededCounter)
+		}
 		executionInfo := c.mutableState.GetExecutionInfo()
 		c.logger.Error("history size exceeds error limit.",
 			tag.WorkflowDomainID(executionInfo.DomainID),
@@ -174,6 +196,12 @@ func (c *workflowSizeChecker) failWorkflowSizeExceedsLimit() (bool, error) {
 	}
 
 	if historySize > c.historySizeLimitWarn || historyCount > c.historyCountLimitWarn {
+		if historySize > c.historySizeLimitWarn {
+			c.metricsScope.IncCounter(metrics.HistorySizeWarnLimitExceededCounter)
+		}
+		if historyCount > c.historyCountLimitWarn {
+			c.metricsScope.IncCounter(metrics.HistoryCountWarnLimitExceededCounter)
+		}
 		executionInfo := c.mutableState.GetExecutionInfo()
 		c.logger.Warn("history size exceeds warn limit.",
 			tag.WorkflowDomainID(executionInfo.DomainID),
@@ -181,12 +209,43 @@ func (c *workflowSizeChecker) failWorkflowSizeExceedsLimit() (bool, error) {
 			tag.WorkflowRunID(executionInfo.RunID),
 			tag.WorkflowHistorySize(historySize),
 			tag.WorkflowEventCount(historyCount))
+		if c.warningMarkerEnabled && historyCount > c.historyCountLimitWarn && c.previousHistoryCount() <= c.historyCountLimitWarn {
+			if err := c.recordWarningMarker(historySize, historyCount); err != nil {
+				return false, err
+			}
+		}
 		return false, nil
 	}
 
 	return false, nil
 }
 
+// previousHistoryCount is the history event count at the previous completed decision, when the limits were last checked
+func (c *workflowSizeChecker) previousHistoryCount() int {
+	if c.lastProcessedEventID == common.EmptyEventID {
+		return 0
+	}
+	return int(c.lastProcessedEventID) + 1
+}
+
+func (c *workflowSizeChecker) recordWarningMarker(historySize int, historyCount int) error {
+	details, err := json.Marshal(historySizeWarning{
+		HistorySize:       historySize,
+		HistoryCount:      historyCount,
+		HistorySizeLimit:  c.historySizeLimitError,
+		HistoryCountLimit: c.historyCountLimitError,
+	})
+	if err != nil {
+		return err
+	}
+	attributes := &types.RecordMarkerDecisionAttributes{
+		MarkerName: common.HistorySizeWarningMarkerName,
+		Details:    details,
+	}
+	_, err = c.mutableState.AddRecordMarkerEvent(c.completedID, attributes)
+	return err
+}
+
 func (v *attrValidator) validateActivityScheduleAttributes(
 	domainID string,
 	targetDomainID string,
@@ -851,7 +910,7 @@ func (v *attrValidator) validateCrossDomainCall(
 	// case 1 can be actually be combined with this case
 	if len(sourceClusters) == 1 && len(targetClusters) == 1 {
 		if sourceClusters[0].ClusterName == targetClusters[0].ClusterName {
-			return nil
+			return v.validateCrossDomainAllowlist(sourceDomainEntry, targetDomainEntry)
 		}
 		return v.createCrossDomainCallError(sourceDomainEntry, targetDomainEntry)
 	}
@@ -873,12 +932,39 @@ func (v *attrValidator) validateCrossDomainCall(
 				return v.createCrossDomainCallError(sourceDomainEntry, targetDomainEntry)
 			}
 		}
-		return nil
+		return v.validateCrossDomainAllowlist(sourceDomainEntry, targetDomainEntry)
 	}
 
 	return v.createCrossDomainCallError(sourceDomainEntry, targetDomainEntry)
 }
 
+// validateCrossDomainAllowlist checks that the source domain is listed in the cross domain callers of the target domain,
+// when the target domain has the list or is required to have one
+func (v *attrValidator) validateCrossDomainAllowlist(
+	sourceDomainEntry *cache.DomainCacheEntry,
+	targetDomainEntry *cache.DomainCacheEntry,
+) error {
+
+	targetDomainName := targetDomainEntry.GetInfo().Name
+	callers, ok := targetDomainEntry.GetInfo().Data[common.DomainDataKeyForCrossDomainCallers]
+	if !ok && !v.config.EnforceCrossDomainAllowlist(targetDomainName) {
+		return nil
+	}
+
+	sourceDomainName := sourceDomainEntry.GetInfo().Name
+	for _, caller := range strings.Fields(callers) {
+		if caller == sourceDomainName {
+			return nil
+		}
+	}
+	v.metricsClient.IncCounter(metrics.HistoryRespondDecisionTaskCompletedScope, metrics.CrossDomainCallDeniedCounter)
+	return &types.BadRequestError{Message: fmt.Sprintf(
+		"domain %v is not allowed to make cross domain calls to %v",
+		sourceDomainName,
+		targetDomainName,
+	)}
+}
+
 func (v *attrValidator) createCrossDomainCallError(
 	domainEntry *cache.DomainCacheEntry,
 	targetDomainEntry *cache.DomainCacheEntry,
@@ -39,6 +39,7 @@ import (
 	"github.com/uber/cadence/common/types"
 	"github.com/uber/cadence/service/history/config"
 	"github.com/uber/cadence/service/history/constants"
+	"github.com/uber/cadence/service/history/execution"
 )
 
 type (
@@ -98,6 +99,7 @@ func (s *attrValidatorSuite) SetupTest() {
 			time.Duration(s.testActivityMaxScheduleToStartTimeoutForRetryInSeconds) * time.Second,
 		),
 		EnableCrossClusterOperations: dynamicconfig.GetBoolPropertyFnFilteredByDomain(false),
+		EnforceCrossDomainAllowlist:  dynamicconfig.GetBoolPropertyFnFilteredByDomain(false),
 	}
 	s.validator = newAttrValidator(
 		s.mockDomainCache,
@@ -197,6 +199,65 @@ func (s *attrValidatorSuite) TestValidateCrossDomainCall_LocalToLocal() {
 	s.Nil(err)
 }
 
+func (s *attrValidatorSuite) TestValidateCrossDomainCall_LocalToLocal_Allowlist() {
+	domainEntry := cache.NewLocalDomainCacheEntryForTest(
+		&persistence.DomainInfo{Name: s.testDomainID},
+		nil,
+		cluster.TestCurrentClusterName,
+		nil,
+	)
+	targetDomainEntry := cache.NewLocalDomainCacheEntryForTest(
+		&persistence.DomainInfo{
+			Name: s.testTargetDomainID,
+			Data: map[string]string{common.DomainDataKeyForCrossDomainCallers: "other-domain " + s.testDomainID},
+		},
+		nil,
+		cluster.TestCurrentClusterName,
+		nil,
+	)
+	deniedTargetDomainEntry := cache.NewLocalDomainCacheEntryForTest(
+		&persistence.DomainInfo{
+			Name: s.testTargetDomainID,
+			Data: map[string]string{common.DomainDataKeyForCrossDomainCallers: "other-domain"},
+		},
+		nil,
+		cluster.TestCurrentClusterName,
+		nil,
+	)
+
+	s.mockDomainCache.EXPECT().GetDomainByID(s.testDomainID).Return(domainEntry, nil).Times(2)
+	s.mockDomainCache.EXPECT().GetDomainByID(s.testTargetDomainID).Return(targetDomainEntry, nil).Times(1)
+	s.mockDomainCache.EXPECT().GetDomainByID(s.testTargetDomainID).Return(deniedTargetDomainEntry, nil).Times(1)
+
+	err := s.validator.validateCrossDomainCall(s.testDomainID, s.testTargetDomainID)
+	s.Nil(err)
+
+	err = s.validator.validateCrossDomainCall(s.testDomainID, s.testTargetDomainID)
+	s.IsType(&types.BadRequestError{}, err)
+}
+
+func (s *attrValidatorSuite) TestValidateCrossDomainCall_LocalToLocal_AllowlistEnforced() {
+	domainEntry := cache.NewLocalDomainCacheEntryForTest(
+		&persistence.DomainInfo{Name: s.testDomainID},
+		nil,
+		cluster.TestCurrentClusterName,
+		nil,
+	)
+	targetDomainEntry := cache.NewLocalDomainCacheEntryForTest(
+		&persistence.DomainInfo{Name: s.testTargetDomainID},
+		nil,
+		cluster.TestCurrentClusterName,
+		nil,
+	)
+
+	s.mockDomainCache.EXPECT().GetDomainByID(s.testDomainID).Return(domainEntry, nil).Times(1)
+	s.mockDomainCache.EXPECT().GetDomainByID(s.testTargetDomainID).Return(targetDomainEntry, nil).Times(1)
+
+	s.validator.config.EnforceCrossDomainAllowlist = dynamicconfig.GetBoolPropertyFnFilteredByDomain(true)
+	err := s.validator.validateCrossDomainCall(s.testDomainID, s.testTargetDomainID)
+	s.IsType(&types.BadRequestError{}, err)
+}
+
 func (s *attrValidatorSuite) TestValidateCrossDomainCall_LocalToEffectiveLocal_SameCluster() {
 	domainEntry := cache.NewLocalDomainCacheEntryForTest(
 		&persistence.DomainInfo{Name: s.testDomainID},
@@ -789,3 +850,70 @@ func (s *attrValidatorSuite) TestValidateActivityScheduleAttributes_WithRetryPol
 	s.Nil(err)
 	s.Equal(expectedAttributesAfterValidation, attributes)
 }
+
+func TestWorkflowSizeChecker_WarningMarker(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		warningMarkerEnabled bool
+		lastProcessedEventID int64
+		expectMarker         bool
+	}{
+		{
+			name:                 "crossed warn limit",
+			warningMarkerEnabled: true,
+			lastProcessedEventID: 8,
+			expectMarker:         true,
+		},
+		{
+			name:                 "crossed warn limit before",
+			warningMarkerEnabled: true,
+			lastProcessedEventID: 10,
+			expectMarker:         false,
+		},
+		{
+			name:                 "marker disabled",
+			warningMarkerEnabled: false,
+			lastProcessedEventID: 8,
+			expectMarker:         false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			controller := gomock.NewController(t)
+			defer controller.Finish()
+
+			completedID := int64(11)
+			mutableState := execution.NewMockMutableState(controller)
+			mutableState.EXPECT().GetNextEventID().Return(completedID + 1).AnyTimes()
+			mutableState.EXPECT().GetExecutionInfo().Return(&persistence.WorkflowExecutionInfo{}).AnyTimes()
+			if tc.expectMarker {
+				mutableState.EXPECT().AddRecordMarkerEvent(completedID, gomock.Any()).DoAndReturn(
+					func(_ int64, attributes *types.RecordMarkerDecisionAttributes) (*types.HistoryEvent, error) {
+						require.Equal(t, common.HistorySizeWarningMarkerName, attributes.MarkerName)
+						return &types.HistoryEvent{}, nil
+					},
+				).Times(1)
+			}
+
+			checker := newWorkflowSizeChecker(
+				1024,
+				2048,
+				1024*1024,
+				2048*1024,
+				10,
+				20,
+				tc.warningMarkerEnabled,
+				tc.lastProcessedEventID,
+				completedID,
+				mutableState,
+				&persistence.ExecutionStats{HistorySize: 1024},
+				metrics.NewNoopMetricsClient().Scope(metrics.HistoryRespondDecisionTaskCompletedScope),
+				log.NewNoop(),
+			)
+			failWorkflow, err := checker.failWorkflowSizeExceedsLimit()
+			require.NoError(t, err)
+			require.False(t, failWorkflow)
+		})
+	}
+}
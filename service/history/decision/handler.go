@@ -361,6 +361,8 @@ Update_History_Loop:
 			handler.metricsClient.IncCounter(metrics.HistoryRespondDecisionTaskCompletedScope, metrics.AutoResetPointsLimitExceededCounter)
 		}
 
+		// the size limits were last checked when the previous decision completed
+		lastProcessedEventID := executionInfo.LastProcessedEvent
 		decisionHeartbeating := request.GetForceCreateNewDecisionTask() && len(request.Decisions) == 0
 		var decisionHeartbeatTimeout bool
 		var completedEvent *types.HistoryEvent
@@ -421,13 +423,16 @@ Update_History_Loop:
 		} else {
 
 			domainName := domainEntry.GetInfo().Name
+			workflowType := executionInfo.WorkflowTypeName
 			workflowSizeChecker := newWorkflowSizeChecker(
 				handler.config.BlobSizeLimitWarn(domainName),
 				handler.config.BlobSizeLimitError(domainName),
-				handler.config.HistorySizeLimitWarn(domainName),
-				handler.config.HistorySizeLimitError(domainName),
-				handler.config.HistoryCountLimitWarn(domainName),
-				handler.config.HistoryCountLimitError(domainName),
+				handler.config.HistorySizeLimitWarn(domainName, workflowType),
+				handler.config.HistorySizeLimitError(domainName, workflowType),
+				handler.config.HistoryCountLimitWarn(domainName, workflowType),
+				handler.config.HistoryCountLimitError(domainName, workflowType),
+				handler.config.EnableHistorySizeWarningMarker(domainName) && !decisionHeartbeatTimeout,
+				lastProcessedEventID,
 				completedEvent.GetEventID(),
 				msBuilder,
 				executionStats,
@@ -980,6 +980,7 @@ func (s *contextImpl) AppendHistoryV2Events(
 	request.Encoding = s.getDefaultEncoding(domainName)
 	request.ShardID = common.IntPtr(s.shardID)
 	request.TransactionID = transactionID
+	request.DomainID = domainID
 
 	size := 0
 	defer func() {
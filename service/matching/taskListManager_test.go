@@ -76,7 +76,8 @@ func TestDeliverBufferTasks_NoPollers(t *testing.T) {
 	defer controller.Finish()
 
 	tlm := createTestTaskListManager(controller)
-	tlm.taskReader.taskBuffer <- &persistence.TaskInfo{}
+	require.Equal(t, time.Duration(0), tlm.taskReader.backlogHeadAge())
+	tlm.taskReader.taskBuffer <- &persistence.TaskInfo{CreatedTime: time.Now().Add(-time.Hour)}
 	var wg sync.WaitGroup
 	wg.Add(1)
 	go func() {
@@ -84,6 +85,7 @@ func TestDeliverBufferTasks_NoPollers(t *testing.T) {
 		wg.Done()
 	}()
 	time.Sleep(100 * time.Millisecond) // let go routine run first and block on tasksForPoll
+	require.True(t, tlm.taskReader.backlogHeadAge() >= time.Hour)
 	tlm.taskReader.cancelFunc()
 	wg.Wait()
 }
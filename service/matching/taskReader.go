@@ -23,6 +23,7 @@ package matching
 import (
 	"context"
 	"runtime"
+	"sync/atomic"
 	"time"
 
 	"github.com/uber/cadence/common/log"
@@ -49,6 +50,9 @@ type (
 		// separate shutdownC needed for dispatchTasks go routine to allow
 		// getTasksPump to be stopped without stopping dispatchTasks in unit tests
 		dispatcherShutdownC chan struct{}
+		// creation time (unix nanos) of the backlog task currently being dispatched,
+		// zero when there is no buffered task waiting for a poller
+		backlogHeadCreatedTime int64
 	}
 )
 
@@ -93,10 +97,14 @@ dispatchLoop:
 			if !ok { // Task list getTasks pump is shutdown
 				break dispatchLoop
 			}
+			tr.setBacklogHead(taskInfo.CreatedTime)
 			task := newInternalTask(taskInfo, tr.tlMgr.completeTask, types.TaskSourceDbBacklog, "", false)
 			for {
 				err := tr.tlMgr.DispatchTask(tr.cancelCtx, task)
 				if err == nil {
+					if len(tr.taskBuffer) == 0 {
+						tr.setBacklogHead(time.Time{})
+					}
 					break
 				}
 				if err == context.Canceled {
@@ -285,10 +293,29 @@ func (tr *taskReader) persistAckLevel() error {
 	// note: this metrics is only an estimation for the lag. taskID in DB may not be continuous,
 	// especially when task list ownership changes.
 	scope.UpdateGauge(metrics.TaskLagPerTaskListGauge, float64(maxReadLevel-ackLevel))
+	scope.UpdateGauge(metrics.BacklogHeadAgePerTaskListGauge, tr.backlogHeadAge().Seconds())
 
 	return tr.tlMgr.db.UpdateState(ackLevel)
 }
 
+func (tr *taskReader) setBacklogHead(createdTime time.Time) {
+	var nanos int64
+	if !createdTime.IsZero() {
+		nanos = createdTime.UnixNano()
+	}
+	atomic.StoreInt64(&tr.backlogHeadCreatedTime, nanos)
+}
+
+// backlogHeadAge returns how long the oldest buffered task has been waiting
+// since it was created, or zero when the buffer is drained
+func (tr *taskReader) backlogHeadAge() time.Duration {
+	nanos := atomic.LoadInt64(&tr.backlogHeadCreatedTime)
+	if nanos == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, nanos))
+}
+
 func (tr *taskReader) isTaskAddedRecently(lastAddTime time.Time) bool {
 	return time.Now().Sub(lastAddTime) <= tr.tlMgr.config.MaxTasklistIdleTime()
 }
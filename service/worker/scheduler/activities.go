@@ -0,0 +1,105 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scheduler
+
+import (
+	"context"
+
+	"github.com/uber/cadence/client/frontend"
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/types"
+)
+
+const identity = "cadence-scheduler"
+
+type (
+	startWorkflowActivityParams struct {
+		Action     Action
+		WorkflowID string
+		RequestID  string
+	}
+
+	runActivityParams struct {
+		Domain     string
+		WorkflowID string
+		RunID      string
+	}
+)
+
+// startWorkflowActivity starts a scheduled workflow and returns its run ID
+func startWorkflowActivity(ctx context.Context, params *startWorkflowActivityParams) (string, error) {
+	action := params.Action
+	resp, err := getFrontendClient(ctx).StartWorkflowExecution(ctx, &types.StartWorkflowExecutionRequest{
+		Domain:                              action.Domain,
+		WorkflowID:                          params.WorkflowID,
+		WorkflowType:                        &types.WorkflowType{Name: action.WorkflowType},
+		TaskList:                            &types.TaskList{Name: action.TaskList},
+		Input:                               action.Input,
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(action.ExecutionStartToCloseTimeoutSeconds),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(action.TaskStartToCloseTimeoutSeconds),
+		Identity:                            identity,
+		RequestID:                           params.RequestID,
+		WorkflowIDReusePolicy:               types.WorkflowIDReusePolicyAllowDuplicate.Ptr(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.GetRunID(), nil
+}
+
+// describeRunActivity returns whether a scheduled workflow is still open
+func describeRunActivity(ctx context.Context, params *runActivityParams) (bool, error) {
+	resp, err := getFrontendClient(ctx).DescribeWorkflowExecution(ctx, &types.DescribeWorkflowExecutionRequest{
+		Domain: params.Domain,
+		Execution: &types.WorkflowExecution{
+			WorkflowID: params.WorkflowID,
+			RunID:      params.RunID,
+		},
+	})
+	if err != nil {
+		if _, ok := err.(*types.EntityNotExistsError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return resp.GetWorkflowExecutionInfo().CloseStatus == nil, nil
+}
+
+// cancelRunActivity requests cancellation of a scheduled workflow
+func cancelRunActivity(ctx context.Context, params *runActivityParams) error {
+	err := getFrontendClient(ctx).RequestCancelWorkflowExecution(ctx, &types.RequestCancelWorkflowExecutionRequest{
+		Domain: params.Domain,
+		WorkflowExecution: &types.WorkflowExecution{
+			WorkflowID: params.WorkflowID,
+			RunID:      params.RunID,
+		},
+		Identity: identity,
+	})
+	switch err.(type) {
+	case *types.EntityNotExistsError, *types.WorkflowExecutionAlreadyCompletedError:
+		return nil
+	}
+	return err
+}
+
+func getFrontendClient(ctx context.Context) frontend.Client {
+	return ctx.Value(schedulerContextKey).(*Scheduler).frontendClient
+}
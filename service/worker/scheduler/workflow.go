@@ -0,0 +1,519 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/robfig/cron"
+	"go.uber.org/cadence"
+	"go.uber.org/cadence/workflow"
+	"go.uber.org/zap"
+)
+
+type (
+	contextKey string
+
+	// OverlapPolicy decides what happens when a run is due while the previous run is still open
+	OverlapPolicy string
+)
+
+const (
+	schedulerContextKey contextKey = "schedulerContext"
+	// TaskListName tasklist
+	TaskListName = "cadence-sys-scheduler-tasklist"
+	// WorkflowTypeName workflow type name
+	WorkflowTypeName = "cadence-sys-scheduler-workflow"
+	// WorkflowIDPrefix is the prefix of the IDs of schedule workflows
+	WorkflowIDPrefix = "cadence-sys-schedule"
+
+	startWorkflowActivityName = "cadence-sys-scheduler-startWorkflow-activity"
+	describeRunActivityName   = "cadence-sys-scheduler-describeRun-activity"
+	cancelRunActivityName     = "cadence-sys-scheduler-cancelRun-activity"
+
+	// QueryType for schedule workflow
+	QueryType = "describe"
+	// UpdateSignal signal name for replacing the schedule
+	UpdateSignal = "update"
+	// PauseSignal signal name for pause
+	PauseSignal = "pause"
+	// UnpauseSignal signal name for unpause
+	UnpauseSignal = "unpause"
+	// BackfillSignal signal name for backfill
+	BackfillSignal = "backfill"
+
+	// OverlapPolicySkip does not start the run
+	OverlapPolicySkip OverlapPolicy = "skip"
+	// OverlapPolicyBufferOne starts the run once the previous run closes, only the latest run due is kept
+	OverlapPolicyBufferOne OverlapPolicy = "buffer_one"
+	// OverlapPolicyCancelPrevious requests cancellation of the previous run and starts the run
+	OverlapPolicyCancelPrevious OverlapPolicy = "cancel_previous"
+	// OverlapPolicyAllowAll starts the run
+	OverlapPolicyAllowAll OverlapPolicy = "allow_all"
+
+	defaultCatchupWindow                  = time.Minute
+	defaultTaskStartToCloseTimeoutSeconds = 10
+	bufferCheckInterval                   = time.Minute
+	backfillBatchSize                     = 100
+	numUpcomingRuns                       = 5
+	iterationsBeforeContinueAsNew         = 500
+)
+
+type (
+	// Spec describes when a schedule starts workflows
+	Spec struct {
+		// CronSchedule is the standard cron expression of the scheduled times, in UTC
+		CronSchedule string
+		// Jitter is the maximum delay added to each scheduled time
+		Jitter time.Duration
+		// CatchupWindow is how late a run can still start after its scheduled time, e.g. when the schedule
+		// was paused or the worker service was down. Runs missed by more are skipped. Default is one minute.
+		CatchupWindow time.Duration
+		// OverlapPolicy decides what happens when a run is due while the previous run is still open.
+		// Default is skip.
+		OverlapPolicy OverlapPolicy
+	}
+
+	// Action describes the workflow started by a schedule
+	Action struct {
+		Domain string
+		// WorkflowID is the prefix of the started workflow IDs, the scheduled time is appended to it
+		WorkflowID                          string
+		WorkflowType                        string
+		TaskList                            string
+		Input                               []byte
+		ExecutionStartToCloseTimeoutSeconds int32
+		TaskStartToCloseTimeoutSeconds      int32
+	}
+
+	// Schedule is the definition of a schedule
+	Schedule struct {
+		Spec   Spec
+		Action Action
+		// Paused schedules do not start workflows
+		Paused bool
+	}
+
+	// BackfillRequest is the arg of the backfill signal, it starts the runs scheduled between
+	// StartTime and EndTime, both inclusive, regardless of the catch up window
+	BackfillRequest struct {
+		StartTime time.Time
+		EndTime   time.Time
+		// OverlapPolicy overrides the overlap policy of the schedule for the backfilled runs, optional
+		OverlapPolicy OverlapPolicy
+	}
+
+	// Run is a workflow started by a schedule
+	Run struct {
+		WorkflowID    string
+		RunID         string
+		ScheduledTime time.Time
+	}
+
+	// State is the progress of a schedule, it is carried over continue as new
+	State struct {
+		// LastScheduledTime is the latest scheduled time that was processed
+		LastScheduledTime time.Time
+		LastRun           *Run
+		// BufferedTime is the scheduled time of the run waiting for the previous run to close
+		BufferedTime *time.Time
+		Backfills    []BackfillRequest
+		TotalRuns    int64
+		SkippedRuns  int64
+		FailedRuns   int64
+	}
+
+	// Params is the arg for ScheduleWorkflow
+	Params struct {
+		Domain     string
+		ScheduleID string
+		Schedule   Schedule
+		State      State
+	}
+
+	// Description is the result of the describe query
+	Description struct {
+		Domain     string
+		ScheduleID string
+		Schedule   Schedule
+		State      State
+		// UpcomingRuns are the next scheduled times, jitter not included
+		UpcomingRuns []time.Time
+	}
+
+	scheduleWorkflow struct {
+		ctx    workflow.Context
+		params *Params
+		logger *zap.Logger
+	}
+)
+
+// WorkflowID returns the ID of the workflow running a schedule
+func WorkflowID(domain string, scheduleID string) string {
+	return fmt.Sprintf("%v:%v:%v", WorkflowIDPrefix, domain, scheduleID)
+}
+
+// Validate validates a schedule
+func Validate(schedule *Schedule) error {
+	if schedule == nil {
+		return errors.New("schedule is nil")
+	}
+	if _, err := cron.ParseStandard(schedule.Spec.CronSchedule); err != nil {
+		return fmt.Errorf("invalid cron schedule %q: %v", schedule.Spec.CronSchedule, err)
+	}
+	if schedule.Spec.Jitter < 0 || schedule.Spec.CatchupWindow < 0 {
+		return errors.New("jitter and catch up window cannot be negative")
+	}
+	switch schedule.Spec.OverlapPolicy {
+	case "", OverlapPolicySkip, OverlapPolicyBufferOne, OverlapPolicyCancelPrevious, OverlapPolicyAllowAll:
+	default:
+		return fmt.Errorf("unknown overlap policy %q", schedule.Spec.OverlapPolicy)
+	}
+	action := schedule.Action
+	if action.Domain == "" || action.WorkflowID == "" || action.WorkflowType == "" || action.TaskList == "" {
+		return errors.New("domain, workflowID, workflowType and taskList of the action are required")
+	}
+	if action.ExecutionStartToCloseTimeoutSeconds <= 0 {
+		return errors.New("execution start to close timeout of the action must be positive")
+	}
+	return nil
+}
+
+// ScheduleWorkflow is the workflow that starts the workflows of a schedule at its scheduled times
+func ScheduleWorkflow(ctx workflow.Context, params *Params) error {
+	if params == nil {
+		return errors.New("params is nil")
+	}
+	if err := Validate(&params.Schedule); err != nil {
+		return err
+	}
+	if params.State.LastScheduledTime.IsZero() {
+		params.State.LastScheduledTime = workflow.Now(ctx)
+	}
+
+	w := &scheduleWorkflow{
+		ctx:    ctx,
+		params: params,
+		logger: workflow.GetLogger(ctx),
+	}
+	err := workflow.SetQueryHandler(ctx, QueryType, func() (*Description, error) {
+		return w.describe(), nil
+	})
+	if err != nil {
+		return err
+	}
+	return w.run()
+}
+
+func (w *scheduleWorkflow) run() error {
+	ctx := w.ctx
+	state := &w.params.State
+	updateCh := workflow.GetSignalChannel(ctx, UpdateSignal)
+	pauseCh := workflow.GetSignalChannel(ctx, PauseSignal)
+	unpauseCh := workflow.GetSignalChannel(ctx, UnpauseSignal)
+	backfillCh := workflow.GetSignalChannel(ctx, BackfillSignal)
+	drainSignals := func() {
+		for w.receiveUpdate(updateCh, false) {
+		}
+		for w.receivePause(pauseCh, false) {
+		}
+		for w.receiveUnpause(unpauseCh, false) {
+		}
+		for w.receiveBackfill(backfillCh, false) {
+		}
+	}
+
+	for i := 0; i < iterationsBeforeContinueAsNew; i++ {
+		drainSignals()
+		schedule := w.params.Schedule
+		paused := schedule.Paused
+
+		if !paused && len(state.Backfills) > 0 {
+			w.backfill()
+			continue
+		}
+		if !paused && state.BufferedTime != nil && !w.isLastRunOpen() {
+			scheduledTime := *state.BufferedTime
+			state.BufferedTime = nil
+			w.start(scheduledTime)
+		}
+
+		now := workflow.Now(ctx)
+		nextTime, fireTime := w.nextTime(state.LastScheduledTime)
+		if !paused && !fireTime.After(now) {
+			// skip all the runs missed by more than the catch up window
+			for now.Sub(fireTime) > w.catchupWindow() {
+				state.LastScheduledTime = nextTime
+				state.SkippedRuns++
+				nextTime, fireTime = w.nextTime(nextTime)
+			}
+			if !fireTime.After(now) {
+				state.LastScheduledTime = nextTime
+				w.takeAction(nextTime, schedule.Spec.OverlapPolicy)
+			}
+			continue
+		}
+
+		timerCtx, cancelTimer := workflow.WithCancel(ctx)
+		selector := workflow.NewSelector(ctx)
+		if !paused {
+			wait := fireTime.Sub(now)
+			if state.BufferedTime != nil && wait > bufferCheckInterval {
+				wait = bufferCheckInterval
+			}
+			selector.AddFuture(workflow.NewTimer(timerCtx, wait), func(workflow.Future) {})
+		}
+		selector.AddReceive(updateCh, func(c workflow.Channel, more bool) { w.receiveUpdate(c, true) })
+		selector.AddReceive(pauseCh, func(c workflow.Channel, more bool) { w.receivePause(c, true) })
+		selector.AddReceive(unpauseCh, func(c workflow.Channel, more bool) { w.receiveUnpause(c, true) })
+		selector.AddReceive(backfillCh, func(c workflow.Channel, more bool) { w.receiveBackfill(c, true) })
+		selector.AddReceive(ctx.Done(), func(c workflow.Channel, more bool) {})
+		selector.Select(ctx)
+		cancelTimer()
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	drainSignals()
+	return workflow.NewContinueAsNewError(ctx, WorkflowTypeName, w.params)
+}
+
+func (w *scheduleWorkflow) receiveUpdate(c workflow.Channel, blocking bool) bool {
+	var schedule Schedule
+	if !w.receive(c, &schedule, blocking) {
+		return false
+	}
+	if err := Validate(&schedule); err != nil {
+		w.logger.Warn("Ignoring invalid schedule update.", zap.Error(err))
+		return true
+	}
+	if schedule.Spec.CronSchedule != w.params.Schedule.Spec.CronSchedule {
+		// the new cron schedule only applies to the times after the update
+		w.params.State.LastScheduledTime = workflow.Now(w.ctx)
+	}
+	w.params.Schedule = schedule
+	return true
+}
+
+func (w *scheduleWorkflow) receivePause(c workflow.Channel, blocking bool) bool {
+	if !w.receive(c, nil, blocking) {
+		return false
+	}
+	w.params.Schedule.Paused = true
+	return true
+}
+
+func (w *scheduleWorkflow) receiveUnpause(c workflow.Channel, blocking bool) bool {
+	if !w.receive(c, nil, blocking) {
+		return false
+	}
+	w.params.Schedule.Paused = false
+	return true
+}
+
+func (w *scheduleWorkflow) receiveBackfill(c workflow.Channel, blocking bool) bool {
+	var request BackfillRequest
+	if !w.receive(c, &request, blocking) {
+		return false
+	}
+	if request.EndTime.Before(request.StartTime) {
+		w.logger.Warn("Ignoring backfill ending before it starts.")
+		return true
+	}
+	w.params.State.Backfills = append(w.params.State.Backfills, request)
+	return true
+}
+
+func (w *scheduleWorkflow) receive(c workflow.Channel, valuePtr interface{}, blocking bool) bool {
+	if blocking {
+		c.Receive(w.ctx, valuePtr)
+		return true
+	}
+	return c.ReceiveAsync(valuePtr)
+}
+
+// backfill starts a batch of the runs of the first pending backfill
+func (w *scheduleWorkflow) backfill() {
+	state := &w.params.State
+	request := &state.Backfills[0]
+	policy := request.OverlapPolicy
+	if policy == "" {
+		policy = w.params.Schedule.Spec.OverlapPolicy
+	}
+	schedule, _ := cron.ParseStandard(w.params.Schedule.Spec.CronSchedule)
+	// Next is strictly after the given time, so step back to include the start time
+	scheduledTime := schedule.Next(request.StartTime.UTC().Add(-time.Nanosecond))
+	for i := 0; i < backfillBatchSize && !scheduledTime.After(request.EndTime); i++ {
+		w.takeAction(scheduledTime, policy)
+		scheduledTime = schedule.Next(scheduledTime)
+	}
+	if scheduledTime.After(request.EndTime) {
+		state.Backfills = state.Backfills[1:]
+	} else {
+		request.StartTime = scheduledTime
+	}
+}
+
+// takeAction starts the run scheduled at the given time, applying the overlap policy
+func (w *scheduleWorkflow) takeAction(scheduledTime time.Time, policy OverlapPolicy) {
+	state := &w.params.State
+	if policy != OverlapPolicyAllowAll && w.isLastRunOpen() {
+		switch policy {
+		case OverlapPolicyBufferOne:
+			state.BufferedTime = &scheduledTime
+			return
+		case OverlapPolicyCancelPrevious:
+			w.cancelLastRun()
+		default:
+			state.SkippedRuns++
+			return
+		}
+	}
+	w.start(scheduledTime)
+}
+
+func (w *scheduleWorkflow) start(scheduledTime time.Time) {
+	state := &w.params.State
+	action := w.params.Schedule.Action
+	if action.TaskStartToCloseTimeoutSeconds <= 0 {
+		action.TaskStartToCloseTimeoutSeconds = defaultTaskStartToCloseTimeoutSeconds
+	}
+	var requestID string
+	// the request ID dedups the start when the activity is retried
+	err := workflow.SideEffect(w.ctx, func(ctx workflow.Context) interface{} {
+		return uuid.New()
+	}).Get(&requestID)
+	if err != nil {
+		state.FailedRuns++
+		return
+	}
+	params := &startWorkflowActivityParams{
+		Action:     action,
+		WorkflowID: fmt.Sprintf("%v-%v", action.WorkflowID, scheduledTime.UTC().Format(time.RFC3339)),
+		RequestID:  requestID,
+	}
+	var runID string
+	ao := workflow.WithActivityOptions(w.ctx, getActivityOptions())
+	if err := workflow.ExecuteActivity(ao, startWorkflowActivityName, params).Get(w.ctx, &runID); err != nil {
+		w.logger.Warn("Failed to start scheduled workflow.", zap.String("WorkflowID", params.WorkflowID), zap.Error(err))
+		state.FailedRuns++
+		return
+	}
+	state.LastRun = &Run{
+		WorkflowID:    params.WorkflowID,
+		RunID:         runID,
+		ScheduledTime: scheduledTime,
+	}
+	state.TotalRuns++
+}
+
+func (w *scheduleWorkflow) isLastRunOpen() bool {
+	lastRun := w.params.State.LastRun
+	if lastRun == nil {
+		return false
+	}
+	var open bool
+	ao := workflow.WithActivityOptions(w.ctx, getActivityOptions())
+	err := workflow.ExecuteActivity(ao, describeRunActivityName, w.runActivityParams(lastRun)).Get(w.ctx, &open)
+	if err != nil {
+		// assume the run is open, so that the overlap policy is not bypassed
+		w.logger.Warn("Failed to describe last scheduled workflow.", zap.String("WorkflowID", lastRun.WorkflowID), zap.Error(err))
+		return true
+	}
+	return open
+}
+
+func (w *scheduleWorkflow) cancelLastRun() {
+	lastRun := w.params.State.LastRun
+	ao := workflow.WithActivityOptions(w.ctx, getActivityOptions())
+	err := workflow.ExecuteActivity(ao, cancelRunActivityName, w.runActivityParams(lastRun)).Get(w.ctx, nil)
+	if err != nil {
+		w.logger.Warn("Failed to cancel last scheduled workflow.", zap.String("WorkflowID", lastRun.WorkflowID), zap.Error(err))
+	}
+}
+
+func (w *scheduleWorkflow) runActivityParams(run *Run) *runActivityParams {
+	return &runActivityParams{
+		Domain:     w.params.Schedule.Action.Domain,
+		WorkflowID: run.WorkflowID,
+		RunID:      run.RunID,
+	}
+}
+
+// nextTime returns the scheduled time following the given one, and the time it fires at once the jitter is added
+func (w *scheduleWorkflow) nextTime(after time.Time) (time.Time, time.Time) {
+	schedule, _ := cron.ParseStandard(w.params.Schedule.Spec.CronSchedule)
+	nextTime := schedule.Next(after.UTC())
+	return nextTime, nextTime.Add(w.jitter(nextTime))
+}
+
+// jitter is derived from the schedule and the scheduled time, so that it stays the same on replay
+func (w *scheduleWorkflow) jitter(scheduledTime time.Time) time.Duration {
+	maxJitter := w.params.Schedule.Spec.Jitter
+	if maxJitter <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(fmt.Sprintf("%v:%v:%v", w.params.Domain, w.params.ScheduleID, scheduledTime.UnixNano())))
+	return time.Duration(h.Sum64() % uint64(maxJitter))
+}
+
+func (w *scheduleWorkflow) catchupWindow() time.Duration {
+	if catchupWindow := w.params.Schedule.Spec.CatchupWindow; catchupWindow > 0 {
+		return catchupWindow
+	}
+	return defaultCatchupWindow
+}
+
+func (w *scheduleWorkflow) describe() *Description {
+	var upcomingRuns []time.Time
+	scheduledTime := w.params.State.LastScheduledTime
+	schedule, _ := cron.ParseStandard(w.params.Schedule.Spec.CronSchedule)
+	for i := 0; i < numUpcomingRuns; i++ {
+		scheduledTime = schedule.Next(scheduledTime.UTC())
+		upcomingRuns = append(upcomingRuns, scheduledTime)
+	}
+	return &Description{
+		Domain:       w.params.Domain,
+		ScheduleID:   w.params.ScheduleID,
+		Schedule:     w.params.Schedule,
+		State:        w.params.State,
+		UpcomingRuns: upcomingRuns,
+	}
+}
+
+func getActivityOptions() workflow.ActivityOptions {
+	return workflow.ActivityOptions{
+		ScheduleToStartTimeout: 10 * time.Second,
+		StartToCloseTimeout:    10 * time.Second,
+		RetryPolicy: &cadence.RetryPolicy{
+			InitialInterval:    time.Second,
+			BackoffCoefficient: 2,
+			MaximumInterval:    10 * time.Second,
+			ExpirationInterval: time.Minute,
+		},
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package scheduler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"go.uber.org/cadence/activity"
+	"go.uber.org/cadence/testsuite"
+	"go.uber.org/cadence/workflow"
+)
+
+type scheduleWorkflowTestSuite struct {
+	suite.Suite
+	testsuite.WorkflowTestSuite
+	workflowEnv *testsuite.TestWorkflowEnvironment
+}
+
+func TestScheduleWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(scheduleWorkflowTestSuite))
+}
+
+func (s *scheduleWorkflowTestSuite) SetupTest() {
+	s.workflowEnv = s.NewTestWorkflowEnvironment()
+	s.workflowEnv.RegisterWorkflowWithOptions(ScheduleWorkflow, workflow.RegisterOptions{Name: WorkflowTypeName})
+	s.workflowEnv.RegisterActivityWithOptions(startWorkflowActivity, activity.RegisterOptions{Name: startWorkflowActivityName})
+	s.workflowEnv.RegisterActivityWithOptions(describeRunActivity, activity.RegisterOptions{Name: describeRunActivityName})
+	s.workflowEnv.RegisterActivityWithOptions(cancelRunActivity, activity.RegisterOptions{Name: cancelRunActivityName})
+}
+
+func (s *scheduleWorkflowTestSuite) TearDownTest() {
+	s.workflowEnv.AssertExpectations(s.T())
+}
+
+func (s *scheduleWorkflowTestSuite) TestValidate() {
+	s.Error(Validate(nil))
+	schedule := s.newSchedule(OverlapPolicySkip)
+	s.NoError(Validate(schedule))
+	schedule.Spec.CronSchedule = "not a cron"
+	s.Error(Validate(schedule))
+	schedule = s.newSchedule("unknown")
+	s.Error(Validate(schedule))
+	schedule = s.newSchedule(OverlapPolicySkip)
+	schedule.Action.Domain = ""
+	s.Error(Validate(schedule))
+	schedule = s.newSchedule(OverlapPolicySkip)
+	schedule.Action.ExecutionStartToCloseTimeoutSeconds = 0
+	s.Error(Validate(schedule))
+}
+
+func (s *scheduleWorkflowTestSuite) TestWorkflowID() {
+	s.Equal("cadence-sys-schedule:test-domain:nightly", WorkflowID("test-domain", "nightly"))
+}
+
+func (s *scheduleWorkflowTestSuite) TestStartsEveryScheduledTime() {
+	var starts []string
+	s.workflowEnv.OnActivity(startWorkflowActivityName, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, params *startWorkflowActivityParams) (string, error) {
+			starts = append(starts, params.WorkflowID)
+			return "run-id", nil
+		})
+	s.workflowEnv.OnActivity(describeRunActivityName, mock.Anything, mock.Anything).Return(false, nil)
+
+	s.workflowEnv.ExecuteWorkflow(WorkflowTypeName, s.newParams(OverlapPolicySkip))
+	s.True(s.workflowEnv.IsWorkflowCompleted())
+	_, ok := s.workflowEnv.GetWorkflowError().(*workflow.ContinueAsNewError)
+	s.True(ok)
+
+	// every scheduled time takes one iteration to wait and one to start
+	s.Len(starts, iterationsBeforeContinueAsNew/2)
+	s.NotEqual(starts[0], starts[1])
+	description := s.describe()
+	s.Equal(int64(len(starts)), description.State.TotalRuns)
+	s.Equal(int64(0), description.State.SkippedRuns)
+	s.Len(description.UpcomingRuns, numUpcomingRuns)
+	s.Equal(description.State.LastScheduledTime.Add(time.Minute), description.UpcomingRuns[0])
+}
+
+func (s *scheduleWorkflowTestSuite) TestOverlapPolicySkip() {
+	s.workflowEnv.OnActivity(startWorkflowActivityName, mock.Anything, mock.Anything).Return("run-id", nil).Once()
+	s.workflowEnv.OnActivity(describeRunActivityName, mock.Anything, mock.Anything).Return(true, nil)
+
+	s.workflowEnv.ExecuteWorkflow(WorkflowTypeName, s.newParams(OverlapPolicySkip))
+	s.True(s.workflowEnv.IsWorkflowCompleted())
+
+	description := s.describe()
+	s.Equal(int64(1), description.State.TotalRuns)
+	s.Equal(int64(iterationsBeforeContinueAsNew/2-1), description.State.SkippedRuns)
+	s.Nil(description.State.BufferedTime)
+}
+
+func (s *scheduleWorkflowTestSuite) TestOverlapPolicyBufferOne() {
+	s.workflowEnv.OnActivity(startWorkflowActivityName, mock.Anything, mock.Anything).Return("run-id", nil).Once()
+	s.workflowEnv.OnActivity(describeRunActivityName, mock.Anything, mock.Anything).Return(true, nil)
+
+	s.workflowEnv.ExecuteWorkflow(WorkflowTypeName, s.newParams(OverlapPolicyBufferOne))
+	s.True(s.workflowEnv.IsWorkflowCompleted())
+
+	description := s.describe()
+	s.Equal(int64(1), description.State.TotalRuns)
+	s.Equal(int64(0), description.State.SkippedRuns)
+	s.NotNil(description.State.BufferedTime)
+}
+
+func (s *scheduleWorkflowTestSuite) TestOverlapPolicyCancelPrevious() {
+	s.workflowEnv.OnActivity(startWorkflowActivityName, mock.Anything, mock.Anything).Return("run-id", nil)
+	s.workflowEnv.OnActivity(describeRunActivityName, mock.Anything, mock.Anything).Return(true, nil)
+	s.workflowEnv.OnActivity(cancelRunActivityName, mock.Anything, mock.Anything).Return(nil).Times(iterationsBeforeContinueAsNew/2 - 1)
+
+	s.workflowEnv.ExecuteWorkflow(WorkflowTypeName, s.newParams(OverlapPolicyCancelPrevious))
+	s.True(s.workflowEnv.IsWorkflowCompleted())
+
+	description := s.describe()
+	s.Equal(int64(iterationsBeforeContinueAsNew/2), description.State.TotalRuns)
+}
+
+func (s *scheduleWorkflowTestSuite) TestPause() {
+	s.workflowEnv.OnActivity(startWorkflowActivityName, mock.Anything, mock.Anything).Return("run-id", nil)
+	s.workflowEnv.SetStartTime(time.Date(2021, 1, 1, 12, 0, 30, 0, time.UTC))
+	s.workflowEnv.RegisterDelayedCallback(func() {
+		s.workflowEnv.SignalWorkflow(PauseSignal, nil)
+	}, time.Minute)
+	s.workflowEnv.RegisterDelayedCallback(func() {
+		s.workflowEnv.CancelWorkflow()
+	}, time.Hour)
+
+	s.workflowEnv.ExecuteWorkflow(WorkflowTypeName, s.newParams(OverlapPolicySkip))
+	s.True(s.workflowEnv.IsWorkflowCompleted())
+
+	description := s.describe()
+	s.True(description.Schedule.Paused)
+	s.Equal(int64(1), description.State.TotalRuns)
+}
+
+func (s *scheduleWorkflowTestSuite) TestBackfill() {
+	var scheduledTimes []string
+	s.workflowEnv.OnActivity(startWorkflowActivityName, mock.Anything, mock.Anything).Return(
+		func(ctx context.Context, params *startWorkflowActivityParams) (string, error) {
+			scheduledTimes = append(scheduledTimes, params.WorkflowID)
+			return "run-id", nil
+		})
+	startTime := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.workflowEnv.RegisterDelayedCallback(func() {
+		s.workflowEnv.SignalWorkflow(BackfillSignal, &BackfillRequest{
+			StartTime:     startTime,
+			EndTime:       startTime.Add(3 * time.Hour),
+			OverlapPolicy: OverlapPolicyAllowAll,
+		})
+	}, time.Second)
+	s.workflowEnv.RegisterDelayedCallback(func() {
+		s.workflowEnv.CancelWorkflow()
+	}, 30*time.Second)
+
+	s.workflowEnv.ExecuteWorkflow(WorkflowTypeName, s.newParams(OverlapPolicySkip))
+	s.True(s.workflowEnv.IsWorkflowCompleted())
+
+	// both ends of the backfill are included
+	s.Len(scheduledTimes, 3*60+1)
+	s.Equal("test-workflow-id-2021-01-01T00:00:00Z", scheduledTimes[0])
+	s.Equal("test-workflow-id-2021-01-01T03:00:00Z", scheduledTimes[len(scheduledTimes)-1])
+	s.Empty(s.describe().State.Backfills)
+}
+
+func (s *scheduleWorkflowTestSuite) TestCatchupWindow() {
+	s.workflowEnv.OnActivity(startWorkflowActivityName, mock.Anything, mock.Anything).Return("run-id", nil)
+	s.workflowEnv.OnActivity(describeRunActivityName, mock.Anything, mock.Anything).Return(false, nil)
+	s.workflowEnv.RegisterDelayedCallback(func() {
+		s.workflowEnv.CancelWorkflow()
+	}, 10*time.Second)
+
+	now := time.Date(2021, 1, 1, 12, 0, 30, 0, time.UTC)
+	s.workflowEnv.SetStartTime(now)
+	params := s.newParams(OverlapPolicySkip)
+	params.State.LastScheduledTime = now.Add(-10 * time.Minute)
+	params.Schedule.Spec.CatchupWindow = 3 * time.Minute
+	s.workflowEnv.ExecuteWorkflow(WorkflowTypeName, params)
+	s.True(s.workflowEnv.IsWorkflowCompleted())
+
+	// the runs of the last 3 minutes are still started
+	description := s.describe()
+	s.Equal(int64(3), description.State.TotalRuns)
+	s.Equal(int64(7), description.State.SkippedRuns)
+}
+
+func (s *scheduleWorkflowTestSuite) describe() *Description {
+	result, err := s.workflowEnv.QueryWorkflow(QueryType)
+	s.NoError(err)
+	var description Description
+	s.NoError(result.Get(&description))
+	return &description
+}
+
+func (s *scheduleWorkflowTestSuite) newParams(policy OverlapPolicy) *Params {
+	return &Params{
+		Domain:     "test-domain",
+		ScheduleID: "test-schedule",
+		Schedule:   *s.newSchedule(policy),
+	}
+}
+
+func (s *scheduleWorkflowTestSuite) newSchedule(policy OverlapPolicy) *Schedule {
+	return &Schedule{
+		Spec: Spec{
+			CronSchedule:  "* * * * *",
+			OverlapPolicy: policy,
+		},
+		Action: Action{
+			Domain:                              "test-domain",
+			WorkflowID:                          "test-workflow-id",
+			WorkflowType:                        "test-workflow-type",
+			TaskList:                            "test-task-list",
+			ExecutionStartToCloseTimeoutSeconds: 60,
+		},
+	}
+}
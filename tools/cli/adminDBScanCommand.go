@@ -309,6 +309,7 @@ func initializeHistoryManager(c *cli.Context) persistence.HistoryManager {
 		historyV2Mgr,
 		logger,
 		dynamicconfig.GetIntPropertyFn(common.DefaultTransactionSizeLimit),
+		nil,
 	)
 	return historyStore
 }
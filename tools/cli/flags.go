@@ -287,6 +287,13 @@ const (
 	FlagDynamicConfigValue                = "dynamic_config_value"
 	FlagTransport                         = "transport"
 	FlagTransportWithAlias                = FlagTransport + ", t"
+	FlagScheduleID                        = "schedule_id"
+	FlagScheduleIDWithAlias               = FlagScheduleID + ", sid"
+	FlagJitter                            = "jitter_seconds"
+	FlagCatchupWindow                     = "catchup_window_seconds"
+	FlagOverlapPolicy                     = "overlap_policy"
+	FlagOverlapPolicyWithAlias            = FlagOverlapPolicy + ", op"
+	FlagPaused                            = "paused"
 )
 
 var flagsForExecution = []cli.Flag{
@@ -729,6 +736,13 @@ func getFlagsForDescribeID() []cli.Flag {
 	}
 }
 
+func getFlagsForAnalyze() []cli.Flag {
+	return append(flagsForExecution, cli.BoolFlag{
+		Name:  FlagPrintJSONWithAlias,
+		Usage: "Print the timeline in JSON format",
+	})
+}
+
 func getFlagsForObserve() []cli.Flag {
 	return append(flagsForExecution, getFlagsForObserveID()...)
 }
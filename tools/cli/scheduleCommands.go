@@ -0,0 +1,266 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/urfave/cli"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/types"
+	"github.com/uber/cadence/service/worker/scheduler"
+)
+
+const (
+	defaultScheduleDeleteReason             = "Schedule deleted through CLI"
+	defaultScheduleWorkflowTimeoutInSeconds = 10 * 365 * 24 * 3600
+)
+
+// CreateSchedule creates a schedule by starting its schedule workflow
+func CreateSchedule(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	scheduleID := getRequiredOption(c, FlagScheduleID)
+	params := &scheduler.Params{
+		Domain:     domain,
+		ScheduleID: scheduleID,
+		Schedule:   *buildSchedule(c, domain, scheduleID),
+	}
+	input, err := json.Marshal(params)
+	if err != nil {
+		ErrorAndExit("Failed to serialize schedule", err)
+	}
+
+	client := getCadenceClient(c)
+	tcCtx, cancel := newContext(c)
+	defer cancel()
+	request := &types.StartWorkflowExecutionRequest{
+		Domain:                              common.SystemLocalDomainName,
+		RequestID:                           uuid.New(),
+		WorkflowID:                          scheduler.WorkflowID(domain, scheduleID),
+		WorkflowIDReusePolicy:               types.WorkflowIDReusePolicyAllowDuplicate.Ptr(),
+		TaskList:                            &types.TaskList{Name: scheduler.TaskListName},
+		ExecutionStartToCloseTimeoutSeconds: common.Int32Ptr(defaultScheduleWorkflowTimeoutInSeconds),
+		TaskStartToCloseTimeoutSeconds:      common.Int32Ptr(defaultDecisionTimeoutInSeconds),
+		WorkflowType:                        &types.WorkflowType{Name: scheduler.WorkflowTypeName},
+		Input:                               input,
+		Identity:                            getCliIdentity(),
+	}
+	if _, err := client.StartWorkflowExecution(tcCtx, request); err != nil {
+		ErrorAndExit("Failed to create schedule", err)
+	}
+	fmt.Printf("Schedule %v created in domain %v\n", scheduleID, domain)
+}
+
+// UpdateSchedule replaces the spec and action of a schedule
+func UpdateSchedule(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	scheduleID := getRequiredOption(c, FlagScheduleID)
+	signalSchedule(c, domain, scheduleID, scheduler.UpdateSignal, buildSchedule(c, domain, scheduleID))
+	fmt.Printf("Schedule %v updated\n", scheduleID)
+}
+
+// PauseSchedule pauses a schedule
+func PauseSchedule(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	scheduleID := getRequiredOption(c, FlagScheduleID)
+	signalSchedule(c, domain, scheduleID, scheduler.PauseSignal, nil)
+	fmt.Printf("Schedule %v paused\n", scheduleID)
+}
+
+// UnpauseSchedule unpauses a schedule
+func UnpauseSchedule(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	scheduleID := getRequiredOption(c, FlagScheduleID)
+	signalSchedule(c, domain, scheduleID, scheduler.UnpauseSignal, nil)
+	fmt.Printf("Schedule %v unpaused\n", scheduleID)
+}
+
+// BackfillSchedule starts the runs of a schedule in a time range
+func BackfillSchedule(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	scheduleID := getRequiredOption(c, FlagScheduleID)
+	request := &scheduler.BackfillRequest{
+		StartTime:     time.Unix(0, parseTime(getRequiredOption(c, FlagEarliestTime), 0)).UTC(),
+		EndTime:       time.Unix(0, parseTime(c.String(FlagLatestTime), time.Now().UnixNano())).UTC(),
+		OverlapPolicy: scheduler.OverlapPolicy(c.String(FlagOverlapPolicy)),
+	}
+	if request.EndTime.Before(request.StartTime) {
+		ErrorAndExit("Latest time cannot be before earliest time", nil)
+	}
+	signalSchedule(c, domain, scheduleID, scheduler.BackfillSignal, request)
+	fmt.Printf("Backfill requested on schedule %v\n", scheduleID)
+}
+
+// DescribeSchedule prints a schedule, its progress and upcoming runs
+func DescribeSchedule(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	scheduleID := getRequiredOption(c, FlagScheduleID)
+
+	client := getCadenceClient(c)
+	tcCtx, cancel := newContext(c)
+	defer cancel()
+	request := &types.QueryWorkflowRequest{
+		Domain: common.SystemLocalDomainName,
+		Execution: &types.WorkflowExecution{
+			WorkflowID: scheduler.WorkflowID(domain, scheduleID),
+		},
+		Query: &types.WorkflowQuery{
+			QueryType: scheduler.QueryType,
+		},
+	}
+	queryResp, err := client.QueryWorkflow(tcCtx, request)
+	if err != nil {
+		ErrorAndExit("Failed to describe schedule", err)
+	}
+	if queryResp.GetQueryResult() == nil {
+		ErrorAndExit("QueryResult has no value", nil)
+	}
+	var description scheduler.Description
+	if err := json.Unmarshal(queryResp.GetQueryResult(), &description); err != nil {
+		ErrorAndExit("Unable to deserialize QueryResult", err)
+	}
+	prettyPrintJSONObject(description)
+}
+
+// DeleteSchedule deletes a schedule by terminating its schedule workflow
+func DeleteSchedule(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	scheduleID := getRequiredOption(c, FlagScheduleID)
+	reason := c.String(FlagReason)
+	if len(reason) == 0 {
+		reason = defaultScheduleDeleteReason
+	}
+
+	client := getCadenceClient(c)
+	tcCtx, cancel := newContext(c)
+	defer cancel()
+	request := &types.TerminateWorkflowExecutionRequest{
+		Domain: common.SystemLocalDomainName,
+		WorkflowExecution: &types.WorkflowExecution{
+			WorkflowID: scheduler.WorkflowID(domain, scheduleID),
+		},
+		Reason:   reason,
+		Identity: getCliIdentity(),
+	}
+	if err := client.TerminateWorkflowExecution(tcCtx, request); err != nil {
+		ErrorAndExit("Failed to delete schedule", err)
+	}
+	fmt.Printf("Schedule %v deleted\n", scheduleID)
+}
+
+// ListSchedules lists the IDs of the schedules of a domain
+func ListSchedules(c *cli.Context) {
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	prefix := scheduler.WorkflowID(domain, "")
+
+	client := getCadenceClient(c)
+	var nextPageToken []byte
+	for {
+		request := &types.ListOpenWorkflowExecutionsRequest{
+			Domain:          common.SystemLocalDomainName,
+			MaximumPageSize: defaultPageSizeForList,
+			NextPageToken:   nextPageToken,
+			StartTimeFilter: &types.StartTimeFilter{
+				EarliestTime: common.Int64Ptr(0),
+				LatestTime:   common.Int64Ptr(time.Now().UnixNano()),
+			},
+			TypeFilter: &types.WorkflowTypeFilter{Name: scheduler.WorkflowTypeName},
+		}
+		tcCtx, cancel := newContextForLongPoll(c)
+		response, err := client.ListOpenWorkflowExecutions(tcCtx, request)
+		cancel()
+		if err != nil {
+			ErrorAndExit("Failed to list schedules", err)
+		}
+		for _, execution := range response.GetExecutions() {
+			workflowID := execution.GetExecution().GetWorkflowID()
+			if strings.HasPrefix(workflowID, prefix) {
+				fmt.Println(strings.TrimPrefix(workflowID, prefix))
+			}
+		}
+		nextPageToken = response.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return
+		}
+	}
+}
+
+func buildSchedule(c *cli.Context, domain string, scheduleID string) *scheduler.Schedule {
+	workflowID := c.String(FlagWorkflowID)
+	if len(workflowID) == 0 {
+		workflowID = scheduleID
+	}
+	schedule := &scheduler.Schedule{
+		Spec: scheduler.Spec{
+			CronSchedule:  getRequiredOption(c, FlagCronSchedule),
+			Jitter:        time.Duration(c.Int(FlagJitter)) * time.Second,
+			CatchupWindow: time.Duration(c.Int(FlagCatchupWindow)) * time.Second,
+			OverlapPolicy: scheduler.OverlapPolicy(c.String(FlagOverlapPolicy)),
+		},
+		Action: scheduler.Action{
+			Domain:                              domain,
+			WorkflowID:                          workflowID,
+			WorkflowType:                        getRequiredOption(c, FlagWorkflowType),
+			TaskList:                            getRequiredOption(c, FlagTaskList),
+			ExecutionStartToCloseTimeoutSeconds: int32(c.Int(FlagExecutionTimeout)),
+			TaskStartToCloseTimeoutSeconds:      int32(c.Int(FlagDecisionTimeout)),
+		},
+		Paused: c.Bool(FlagPaused),
+	}
+	if input := processJSONInput(c); len(input) > 0 {
+		schedule.Action.Input = []byte(input)
+	}
+	if err := scheduler.Validate(schedule); err != nil {
+		ErrorAndExit("Invalid schedule", err)
+	}
+	return schedule
+}
+
+func signalSchedule(c *cli.Context, domain string, scheduleID string, signalName string, arg interface{}) {
+	var input []byte
+	if arg != nil {
+		var err error
+		if input, err = json.Marshal(arg); err != nil {
+			ErrorAndExit("Failed to serialize signal input", err)
+		}
+	}
+
+	client := getCadenceClient(c)
+	tcCtx, cancel := newContext(c)
+	defer cancel()
+	request := &types.SignalWorkflowExecutionRequest{
+		Domain: common.SystemLocalDomainName,
+		WorkflowExecution: &types.WorkflowExecution{
+			WorkflowID: scheduler.WorkflowID(domain, scheduleID),
+		},
+		SignalName: signalName,
+		Input:      input,
+		Identity:   getCliIdentity(),
+	}
+	if err := client.SignalWorkflowExecution(tcCtx, request); err != nil {
+		ErrorAndExit(fmt.Sprintf("Failed to %v schedule", signalName), err)
+	}
+}
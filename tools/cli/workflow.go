@@ -187,6 +187,15 @@ func newWorkflowCommands() []cli.Command {
 				DescribeWorkflowWithID(c)
 			},
 		},
+		{
+			Name:    "analyze",
+			Aliases: []string{"an"},
+			Usage:   "show the time spent in each state of a workflow execution, as a timeline and its critical path",
+			Flags:   getFlagsForAnalyze(),
+			Action: func(c *cli.Context) {
+				AnalyzeWorkflow(c)
+			},
+		},
 		{
 			Name:    "observe",
 			Aliases: []string{"ob"},
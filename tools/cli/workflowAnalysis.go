@@ -0,0 +1,400 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+
+	"github.com/uber/cadence/common/types"
+)
+
+const (
+	timelineSpanDecision      = "Decision"
+	timelineSpanActivity      = "Activity"
+	timelineSpanTimer         = "Timer"
+	timelineSpanChildWorkflow = "ChildWorkflow"
+
+	timelineResultPending = "Pending"
+
+	timelineChartWidth = 40
+)
+
+type (
+	// timelineSpan is the time spent by one decision, activity, timer or child workflow of a workflow execution
+	timelineSpan struct {
+		Type             string
+		ID               string
+		Name             string `json:",omitempty"`
+		ScheduledEventID int64
+		ScheduledTime    time.Time
+		StartedTime      time.Time
+		ClosedTime       time.Time
+		// QueueTime is the time from scheduled to started, it includes the retry backoffs of activities
+		QueueTime time.Duration
+		// RunTime is the time from started to closed, or the wait time of timers
+		RunTime  time.Duration
+		Attempt  int32 `json:",omitempty"`
+		Result   string
+		Critical bool `json:",omitempty"`
+
+		// decisionTaskCompletedEventID is the decision that scheduled the span, not set for decisions
+		decisionTaskCompletedEventID int64
+		// cause is the span whose completion scheduled the decision, only set for decisions
+		cause *timelineSpan
+	}
+
+	// timelineSummary is the total time spent in each state
+	timelineSummary struct {
+		DecisionQueueTime time.Duration
+		DecisionRunTime   time.Duration
+		ActivityQueueTime time.Duration
+		ActivityRunTime   time.Duration
+		ActivityRetries   int32
+		TimerWaitTime     time.Duration
+		ChildWorkflowTime time.Duration
+	}
+
+	// workflowTimeline is the timeline and critical path of a workflow execution derived from its history
+	workflowTimeline struct {
+		StartTime time.Time
+		EndTime   time.Time
+		Closed    bool
+		Summary   timelineSummary
+		Spans     []*timelineSpan
+		// CriticalPath is the chain of spans, each one scheduled by the completion of the previous one,
+		// that ends with the last span of the workflow
+		CriticalPath []*timelineSpan
+	}
+)
+
+// analyzeHistory derives the timeline and critical path of a workflow execution from its history events
+func analyzeHistory(events []*types.HistoryEvent) *workflowTimeline {
+	timeline := &workflowTimeline{}
+	if len(events) == 0 {
+		return timeline
+	}
+	timeline.StartTime = eventTime(events[0])
+	timeline.EndTime = eventTime(events[len(events)-1])
+
+	spans := make(map[int64]*timelineSpan)  // by scheduled or initiated event ID
+	timers := make(map[int64]*timelineSpan) // by started event ID
+	decisionsByCompletedID := make(map[int64]*timelineSpan)
+	closedByEventID := make(map[int64]*timelineSpan) // by the event ID closing the span
+
+	for i, e := range events {
+		switch e.GetEventType() {
+		case types.EventTypeWorkflowExecutionCompleted,
+			types.EventTypeWorkflowExecutionFailed,
+			types.EventTypeWorkflowExecutionTimedOut,
+			types.EventTypeWorkflowExecutionCanceled,
+			types.EventTypeWorkflowExecutionTerminated,
+			types.EventTypeWorkflowExecutionContinuedAsNew:
+			timeline.Closed = true
+
+		case types.EventTypeDecisionTaskScheduled:
+			span := newTimelineSpan(timelineSpanDecision, strconv.FormatInt(e.GetEventID(), 10), "", e)
+			span.cause = findDecisionCause(events[:i], closedByEventID)
+			spans[e.GetEventID()] = span
+		case types.EventTypeDecisionTaskStarted:
+			startSpan(spans[e.DecisionTaskStartedEventAttributes.GetScheduledEventID()], e, 0)
+		case types.EventTypeDecisionTaskCompleted:
+			span := closeSpan(spans[e.DecisionTaskCompletedEventAttributes.GetScheduledEventID()], e, closedByEventID)
+			if span != nil {
+				decisionsByCompletedID[e.GetEventID()] = span
+			}
+		case types.EventTypeDecisionTaskFailed:
+			closeSpan(spans[e.DecisionTaskFailedEventAttributes.GetScheduledEventID()], e, closedByEventID)
+		case types.EventTypeDecisionTaskTimedOut:
+			closeSpan(spans[e.DecisionTaskTimedOutEventAttributes.GetScheduledEventID()], e, closedByEventID)
+
+		case types.EventTypeActivityTaskScheduled:
+			attributes := e.ActivityTaskScheduledEventAttributes
+			span := newTimelineSpan(timelineSpanActivity, attributes.GetActivityID(), attributes.GetActivityType().GetName(), e)
+			span.decisionTaskCompletedEventID = attributes.GetDecisionTaskCompletedEventID()
+			spans[e.GetEventID()] = span
+		case types.EventTypeActivityTaskStarted:
+			attributes := e.ActivityTaskStartedEventAttributes
+			startSpan(spans[attributes.GetScheduledEventID()], e, attributes.GetAttempt())
+		case types.EventTypeActivityTaskCompleted:
+			closeSpan(spans[e.ActivityTaskCompletedEventAttributes.GetScheduledEventID()], e, closedByEventID)
+		case types.EventTypeActivityTaskFailed:
+			closeSpan(spans[e.ActivityTaskFailedEventAttributes.GetScheduledEventID()], e, closedByEventID)
+		case types.EventTypeActivityTaskTimedOut:
+			closeSpan(spans[e.ActivityTaskTimedOutEventAttributes.GetScheduledEventID()], e, closedByEventID)
+		case types.EventTypeActivityTaskCanceled:
+			closeSpan(spans[e.ActivityTaskCanceledEventAttributes.GetScheduledEventID()], e, closedByEventID)
+
+		case types.EventTypeTimerStarted:
+			attributes := e.TimerStartedEventAttributes
+			span := newTimelineSpan(timelineSpanTimer, attributes.GetTimerID(), "", e)
+			span.decisionTaskCompletedEventID = attributes.GetDecisionTaskCompletedEventID()
+			// a timer runs from the moment it is started
+			startSpan(span, e, 0)
+			timers[e.GetEventID()] = span
+			spans[e.GetEventID()] = span
+		case types.EventTypeTimerFired:
+			closeSpan(timers[e.TimerFiredEventAttributes.GetStartedEventID()], e, closedByEventID)
+		case types.EventTypeTimerCanceled:
+			closeSpan(timers[e.TimerCanceledEventAttributes.GetStartedEventID()], e, closedByEventID)
+
+		case types.EventTypeStartChildWorkflowExecutionInitiated:
+			attributes := e.StartChildWorkflowExecutionInitiatedEventAttributes
+			span := newTimelineSpan(timelineSpanChildWorkflow, attributes.GetWorkflowID(), attributes.GetWorkflowType().GetName(), e)
+			span.decisionTaskCompletedEventID = attributes.GetDecisionTaskCompletedEventID()
+			spans[e.GetEventID()] = span
+		case types.EventTypeChildWorkflowExecutionStarted:
+			startSpan(spans[e.ChildWorkflowExecutionStartedEventAttributes.GetInitiatedEventID()], e, 0)
+		case types.EventTypeStartChildWorkflowExecutionFailed:
+			closeSpan(spans[e.StartChildWorkflowExecutionFailedEventAttributes.GetInitiatedEventID()], e, closedByEventID)
+		case types.EventTypeChildWorkflowExecutionCompleted:
+			closeSpan(spans[e.ChildWorkflowExecutionCompletedEventAttributes.GetInitiatedEventID()], e, closedByEventID)
+		case types.EventTypeChildWorkflowExecutionFailed:
+			closeSpan(spans[e.ChildWorkflowExecutionFailedEventAttributes.GetInitiatedEventID()], e, closedByEventID)
+		case types.EventTypeChildWorkflowExecutionCanceled:
+			closeSpan(spans[e.ChildWorkflowExecutionCanceledEventAttributes.GetInitiatedEventID()], e, closedByEventID)
+		case types.EventTypeChildWorkflowExecutionTimedOut:
+			closeSpan(spans[e.ChildWorkflowExecutionTimedOutEventAttributes.GetInitiatedEventID()], e, closedByEventID)
+		case types.EventTypeChildWorkflowExecutionTerminated:
+			closeSpan(spans[e.ChildWorkflowExecutionTerminatedEventAttributes.GetInitiatedEventID()], e, closedByEventID)
+		}
+	}
+
+	for _, span := range spans {
+		if span.Result == "" {
+			// still pending at the end of the history
+			span.Result = timelineResultPending
+			span.ClosedTime = timeline.EndTime
+			span.setDurations()
+		}
+		timeline.Spans = append(timeline.Spans, span)
+	}
+	sort.Slice(timeline.Spans, func(i, j int) bool {
+		return timeline.Spans[i].ScheduledEventID < timeline.Spans[j].ScheduledEventID
+	})
+
+	for _, span := range timeline.Spans {
+		timeline.Summary.add(span)
+	}
+	timeline.CriticalPath = findCriticalPath(timeline.Spans, decisionsByCompletedID)
+	return timeline
+}
+
+func newTimelineSpan(spanType string, id string, name string, e *types.HistoryEvent) *timelineSpan {
+	return &timelineSpan{
+		Type:             spanType,
+		ID:               id,
+		Name:             name,
+		ScheduledEventID: e.GetEventID(),
+		ScheduledTime:    eventTime(e),
+	}
+}
+
+func startSpan(span *timelineSpan, e *types.HistoryEvent, attempt int32) {
+	if span == nil {
+		return
+	}
+	span.StartedTime = eventTime(e)
+	span.Attempt = attempt
+}
+
+func closeSpan(span *timelineSpan, e *types.HistoryEvent, closedByEventID map[int64]*timelineSpan) *timelineSpan {
+	if span == nil {
+		return nil
+	}
+	span.ClosedTime = eventTime(e)
+	span.Result = e.GetEventType().String()
+	span.setDurations()
+	closedByEventID[e.GetEventID()] = span
+	return span
+}
+
+func (s *timelineSpan) setDurations() {
+	if s.StartedTime.IsZero() {
+		s.QueueTime = s.ClosedTime.Sub(s.ScheduledTime)
+		return
+	}
+	s.QueueTime = s.StartedTime.Sub(s.ScheduledTime)
+	s.RunTime = s.ClosedTime.Sub(s.StartedTime)
+}
+
+func (s *timelineSpan) duration() time.Duration {
+	return s.QueueTime + s.RunTime
+}
+
+func (s *timelineSummary) add(span *timelineSpan) {
+	switch span.Type {
+	case timelineSpanDecision:
+		s.DecisionQueueTime += span.QueueTime
+		s.DecisionRunTime += span.RunTime
+	case timelineSpanActivity:
+		s.ActivityQueueTime += span.QueueTime
+		s.ActivityRunTime += span.RunTime
+		s.ActivityRetries += span.Attempt
+	case timelineSpanTimer:
+		s.TimerWaitTime += span.RunTime
+	case timelineSpanChildWorkflow:
+		s.ChildWorkflowTime += span.duration()
+	}
+}
+
+// findDecisionCause returns the span whose completion scheduled a decision, i.e. the span closed by the
+// latest event before the decision was scheduled. It returns nil when the decision was scheduled by an
+// external event such as the workflow start or a signal.
+func findDecisionCause(previousEvents []*types.HistoryEvent, closedByEventID map[int64]*timelineSpan) *timelineSpan {
+	for i := len(previousEvents) - 1; i >= 0; i-- {
+		e := previousEvents[i]
+		if span, ok := closedByEventID[e.GetEventID()]; ok {
+			if span.Type == timelineSpanDecision && e.GetEventType() == types.EventTypeDecisionTaskCompleted {
+				return nil
+			}
+			return span
+		}
+		switch e.GetEventType() {
+		case types.EventTypeWorkflowExecutionStarted,
+			types.EventTypeWorkflowExecutionSignaled,
+			types.EventTypeWorkflowExecutionCancelRequested,
+			types.EventTypeChildWorkflowExecutionStarted:
+			return nil
+		}
+	}
+	return nil
+}
+
+// findCriticalPath walks back from the span that closed last, through the decision that scheduled each
+// span and the span whose completion scheduled each decision
+func findCriticalPath(spans []*timelineSpan, decisionsByCompletedID map[int64]*timelineSpan) []*timelineSpan {
+	var last *timelineSpan
+	for _, span := range spans {
+		if last == nil || !span.ClosedTime.Before(last.ClosedTime) {
+			last = span
+		}
+	}
+
+	var path []*timelineSpan
+	visited := make(map[*timelineSpan]bool)
+	for span := last; span != nil && !visited[span]; {
+		visited[span] = true
+		span.Critical = true
+		path = append(path, span)
+		if span.Type == timelineSpanDecision {
+			span = span.cause
+		} else {
+			span = decisionsByCompletedID[span.decisionTaskCompletedEventID]
+		}
+	}
+
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path
+}
+
+func eventTime(e *types.HistoryEvent) time.Time {
+	return time.Unix(0, e.GetTimestamp())
+}
+
+// printWorkflowTimeline prints the summary, the spans with a text Gantt chart and the critical path of a timeline
+func printWorkflowTimeline(timeline *workflowTimeline) {
+	total := timeline.EndTime.Sub(timeline.StartTime)
+	status := "Open"
+	if timeline.Closed {
+		status = "Closed"
+	}
+	fmt.Printf("Duration: %v (%v)\n", total, status)
+	fmt.Printf("Decisions: %v queued, %v running\n", timeline.Summary.DecisionQueueTime, timeline.Summary.DecisionRunTime)
+	fmt.Printf("Activities: %v queued, %v running, %v retries\n",
+		timeline.Summary.ActivityQueueTime, timeline.Summary.ActivityRunTime, timeline.Summary.ActivityRetries)
+	fmt.Printf("Timers: %v waiting\n", timeline.Summary.TimerWaitTime)
+	fmt.Printf("Child workflows: %v\n", timeline.Summary.ChildWorkflowTime)
+	fmt.Println()
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetColumnSeparator("")
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{"", "Event ID", "Type", "ID", "Name", "Start", "Queue", "Run", "Result", "Timeline"})
+	for _, span := range timeline.Spans {
+		critical := ""
+		if span.Critical {
+			critical = "*"
+		}
+		table.Append([]string{
+			critical,
+			strconv.FormatInt(span.ScheduledEventID, 10),
+			span.Type,
+			span.ID,
+			span.Name,
+			span.ScheduledTime.Sub(timeline.StartTime).String(),
+			span.QueueTime.String(),
+			span.RunTime.String(),
+			span.Result,
+			timelineChart(span, timeline.StartTime, total),
+		})
+	}
+	table.Render()
+	fmt.Println("Timeline: '.' queued, '#' running. Spans on the critical path are marked with '*'.")
+	fmt.Println()
+
+	fmt.Println("Critical path:")
+	for _, span := range timeline.CriticalPath {
+		label := span.Type + " " + span.ID
+		if span.Name != "" {
+			label += " " + span.Name
+		}
+		fmt.Printf("  %v (event %v): %v\n", label, span.ScheduledEventID, span.duration())
+	}
+}
+
+func timelineChart(span *timelineSpan, start time.Time, total time.Duration) string {
+	if total <= 0 {
+		return ""
+	}
+	position := func(t time.Time) int {
+		return int(int64(t.Sub(start)) * timelineChartWidth / int64(total))
+	}
+	clamp := func(value int, min int, max int) int {
+		if value < min {
+			return min
+		}
+		if value > max {
+			return max
+		}
+		return value
+	}
+	// always show at least one character
+	scheduled := clamp(position(span.ScheduledTime), 0, timelineChartWidth-1)
+	closed := clamp(position(span.ClosedTime), scheduled+1, timelineChartWidth)
+	started := closed
+	if !span.StartedTime.IsZero() {
+		started = clamp(position(span.StartedTime), scheduled, closed)
+	}
+	return strings.Repeat(" ", scheduled) +
+		strings.Repeat(".", started-scheduled) +
+		strings.Repeat("#", closed-started) +
+		strings.Repeat(" ", timelineChartWidth-closed)
+}
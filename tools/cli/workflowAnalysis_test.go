@@ -0,0 +1,114 @@
+// Copyright (c) 2017-2020 Uber Technologies Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/uber/cadence/common"
+	"github.com/uber/cadence/common/types"
+)
+
+func TestAnalyzeHistory(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	event := func(id int64, seconds int, eventType types.EventType) *types.HistoryEvent {
+		return &types.HistoryEvent{
+			EventID:   id,
+			Timestamp: common.Int64Ptr(start.Add(time.Duration(seconds) * time.Second).UnixNano()),
+			EventType: eventType.Ptr(),
+		}
+	}
+	decision := func(scheduledID int64, seconds int) []*types.HistoryEvent {
+		scheduled := event(scheduledID, seconds, types.EventTypeDecisionTaskScheduled)
+		started := event(scheduledID+1, seconds+1, types.EventTypeDecisionTaskStarted)
+		started.DecisionTaskStartedEventAttributes = &types.DecisionTaskStartedEventAttributes{ScheduledEventID: scheduledID}
+		completed := event(scheduledID+2, seconds+2, types.EventTypeDecisionTaskCompleted)
+		completed.DecisionTaskCompletedEventAttributes = &types.DecisionTaskCompletedEventAttributes{ScheduledEventID: scheduledID, StartedEventID: scheduledID + 1}
+		return []*types.HistoryEvent{scheduled, started, completed}
+	}
+
+	activityScheduled := event(5, 2, types.EventTypeActivityTaskScheduled)
+	activityScheduled.ActivityTaskScheduledEventAttributes = &types.ActivityTaskScheduledEventAttributes{
+		ActivityID:                   "0",
+		ActivityType:                 &types.ActivityType{Name: "activity"},
+		DecisionTaskCompletedEventID: 4,
+	}
+	timerStarted := event(6, 2, types.EventTypeTimerStarted)
+	timerStarted.TimerStartedEventAttributes = &types.TimerStartedEventAttributes{TimerID: "1", DecisionTaskCompletedEventID: 4}
+	activityStarted := event(7, 5, types.EventTypeActivityTaskStarted)
+	activityStarted.ActivityTaskStartedEventAttributes = &types.ActivityTaskStartedEventAttributes{ScheduledEventID: 5, Attempt: 1}
+	activityCompleted := event(8, 15, types.EventTypeActivityTaskCompleted)
+	activityCompleted.ActivityTaskCompletedEventAttributes = &types.ActivityTaskCompletedEventAttributes{ScheduledEventID: 5, StartedEventID: 7}
+	timerFired := event(12, 62, types.EventTypeTimerFired)
+	timerFired.TimerFiredEventAttributes = &types.TimerFiredEventAttributes{TimerID: "1", StartedEventID: 6}
+
+	events := []*types.HistoryEvent{event(1, 0, types.EventTypeWorkflowExecutionStarted)}
+	events = append(events, decision(2, 0)...)
+	events = append(events, activityScheduled, timerStarted, activityStarted, activityCompleted)
+	events = append(events, decision(9, 15)...)
+	events = append(events, timerFired)
+	events = append(events, decision(13, 62)...)
+	events = append(events, event(16, 64, types.EventTypeWorkflowExecutionCompleted))
+
+	timeline := analyzeHistory(events)
+	assert.True(t, timeline.Closed)
+	assert.Equal(t, 64*time.Second, timeline.EndTime.Sub(timeline.StartTime))
+	assert.Len(t, timeline.Spans, 5)
+	assert.Equal(t, timelineSummary{
+		DecisionQueueTime: 3 * time.Second,
+		DecisionRunTime:   3 * time.Second,
+		ActivityQueueTime: 3 * time.Second,
+		ActivityRunTime:   10 * time.Second,
+		ActivityRetries:   1,
+		TimerWaitTime:     60 * time.Second,
+	}, timeline.Summary)
+
+	var criticalPath []int64
+	for _, span := range timeline.CriticalPath {
+		criticalPath = append(criticalPath, span.ScheduledEventID)
+	}
+	assert.Equal(t, []int64{2, 6, 13}, criticalPath)
+}
+
+func TestAnalyzeHistory_Pending(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []*types.HistoryEvent{
+		{
+			EventID:   1,
+			Timestamp: common.Int64Ptr(start.UnixNano()),
+			EventType: types.EventTypeWorkflowExecutionStarted.Ptr(),
+		},
+		{
+			EventID:   2,
+			Timestamp: common.Int64Ptr(start.Add(time.Second).UnixNano()),
+			EventType: types.EventTypeDecisionTaskScheduled.Ptr(),
+		},
+	}
+
+	timeline := analyzeHistory(events)
+	assert.False(t, timeline.Closed)
+	assert.Len(t, timeline.Spans, 1)
+	assert.Equal(t, timelineResultPending, timeline.Spans[0].Result)
+	assert.Len(t, timeline.CriticalPath, 1)
+}
@@ -943,6 +943,28 @@ func DescribeWorkflowWithID(c *cli.Context) {
 	describeWorkflowHelper(c, wid, rid)
 }
 
+// AnalyzeWorkflow shows the timeline and critical path of a workflow execution derived from its history
+func AnalyzeWorkflow(c *cli.Context) {
+	wfClient := getWorkflowClient(c)
+	domain := getRequiredGlobalOption(c, FlagDomain)
+	wid := getRequiredOption(c, FlagWorkflowID)
+	rid := c.String(FlagRunID)
+
+	ctx, cancel := newContext(c)
+	defer cancel()
+	history, err := GetHistory(ctx, wfClient, domain, wid, rid)
+	if err != nil {
+		ErrorAndExit(fmt.Sprintf("Failed to get history on workflow id: %s, run id: %s.", wid, rid), err)
+	}
+
+	timeline := analyzeHistory(history.Events)
+	if c.Bool(FlagPrintJSON) {
+		prettyPrintJSONObject(timeline)
+		return
+	}
+	printWorkflowTimeline(timeline)
+}
+
 func describeWorkflowHelper(c *cli.Context, wid, rid string) {
 	frontendClient := cFactory.ServerFrontendClient(c)
 	domain := getRequiredGlobalOption(c, FlagDomain)